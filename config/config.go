@@ -23,9 +23,53 @@ type config struct {
 		PrivateKey string `yaml:"private_key"`
 	} `yaml:"mailgun"`
 
+	// Quotas are unlimited if zero
+	Quota struct {
+		AssetSize        int64 `yaml:"asset_size"`
+		ProjectAssetSize int64 `yaml:"project_asset_size"`
+		UserAssetSize    int64 `yaml:"user_asset_size"`
+		ProjectElements  int   `yaml:"project_elements"`
+	} `yaml:"quota"`
+
+	// Lifetimes of tokens in seconds
+	Token struct {
+		Lifetime        int `yaml:"lifetime"`
+		RefreshLifetime int `yaml:"refresh_lifetime"`
+	} `yaml:"token"`
+
+	// Rate limits of route groups. Store is memory or postgres.
+	// Requests are limited for each client IP. X-Forwarded-For is only read
+	// from trusted proxies, which are IPs or CIDR ranges.
+	RateLimit struct {
+		Store          string                    `yaml:"store"`
+		Groups         map[string]RateLimitGroup `yaml:"groups"`
+		TrustedProxies []string                  `yaml:"trusted_proxies"`
+	} `yaml:"rate_limit"`
+
+	// Accounts are locked for the duration (in seconds) after failed login
+	// attempts. It's disabled if attempts is zero.
+	Lockout struct {
+		Attempts int `yaml:"attempts"`
+		Duration int `yaml:"duration"`
+	} `yaml:"lockout"`
+
 	EmailActivation bool   `yaml:"email_activation"`
 	UploadDir       string `yaml:"upload_dir"`
 	AssetDir        string `yaml:"asset_dir"`
+	AssetStore      string `yaml:"asset_store"`
+}
+
+// RateLimitRule allows limit requests in the window (in seconds). It's
+// unlimited if limit is zero.
+type RateLimitRule struct {
+	Limit  int `yaml:"limit"`
+	Window int `yaml:"window"`
+}
+
+// RateLimitGroup contains the rules for each client IP and account.
+type RateLimitGroup struct {
+	IP      RateLimitRule `yaml:"ip"`
+	Account RateLimitRule `yaml:"account"`
 }
 
 const (
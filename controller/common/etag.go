@@ -0,0 +1,68 @@
+package common
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tkusd/server/util"
+)
+
+const (
+	headerETag    = "ETag"
+	headerIfMatch = "If-Match"
+)
+
+// ETag returns an ETag derived from the update time. The time is rounded to
+// microseconds because PostgreSQL doesn't store nanoseconds.
+func ETag(updatedAt time.Time) string {
+	usec := updatedAt.Round(time.Microsecond).UnixNano() / int64(time.Microsecond)
+	return strconv.Quote(strconv.FormatInt(usec, 36))
+}
+
+// SetETag adds the ETag of the update time to the response header.
+func SetETag(c *gin.Context, updatedAt time.Time) {
+	c.Header(headerETag, ETag(updatedAt))
+}
+
+// CheckIfMatch compares the If-Match header with the ETag of the update time.
+// It returns an error with status 412 if none of the ETags matches. Requests
+// without If-Match are always allowed.
+func CheckIfMatch(c *gin.Context, updatedAt time.Time) error {
+	header := c.Request.Header.Get(headerIfMatch)
+
+	if header == "" {
+		return nil
+	}
+
+	etag := ETag(updatedAt)
+
+	for _, s := range strings.Split(header, ",") {
+		s = strings.TrimSpace(s)
+
+		if s == "*" || s == etag {
+			return nil
+		}
+	}
+
+	return &util.APIError{
+		Code:    util.PreconditionFailedError,
+		Message: "The resource has been modified.",
+		Status:  http.StatusPreconditionFailed,
+	}
+}
+
+// IfMatch returns a precondition which checks the If-Match header with the
+// update time of the locked record, so the check and the change are atomic.
+// It returns nil if the header is not set.
+func IfMatch(c *gin.Context) func(updatedAt time.Time) error {
+	if c.Request.Header.Get(headerIfMatch) == "" {
+		return nil
+	}
+
+	return func(updatedAt time.Time) error {
+		return CheckIfMatch(c, updatedAt)
+	}
+}
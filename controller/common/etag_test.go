@@ -0,0 +1,20 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestETag(t *testing.T) {
+	now := time.Now()
+
+	Convey("Rounded to microseconds", t, func() {
+		So(ETag(now.Round(time.Microsecond)), ShouldEqual, ETag(now))
+	})
+
+	Convey("Changed when the time is changed", t, func() {
+		So(ETag(now.Add(time.Microsecond)), ShouldNotEqual, ETag(now))
+	})
+}
@@ -2,6 +2,10 @@ package common
 
 import "github.com/gin-gonic/gin"
 
+// TokenQueryParam is the query parameter of the token for requests which
+// can't set headers, e.g. EventSource.
+const TokenQueryParam = "access_token"
+
 func QueryExist(c *gin.Context, param string) bool {
 	if _, ok := c.Request.URL.Query()[param]; ok {
 		return true
@@ -9,3 +13,34 @@ func QueryExist(c *gin.Context, param string) bool {
 
 	return false
 }
+
+// PageURL returns the URL of the current request with the cursor. It returns
+// an empty string if the cursor is empty.
+func PageURL(c *gin.Context, cursor string) string {
+	if cursor == "" {
+		return ""
+	}
+
+	u := *c.Request.URL
+	query := u.Query()
+	query.Del("offset")
+	query.Set("cursor", cursor)
+	u.RawQuery = query.Encode()
+
+	return u.RequestURI()
+}
+
+// redactedURI returns the URI of the current request with the token in the
+// query string hidden, so it won't be written to logs.
+func redactedURI(c *gin.Context) string {
+	if !QueryExist(c, TokenQueryParam) {
+		return c.Request.RequestURI
+	}
+
+	u := *c.Request.URL
+	query := u.Query()
+	query.Set(TokenQueryParam, "REDACTED")
+	u.RawQuery = query.Encode()
+
+	return u.RequestURI()
+}
@@ -0,0 +1,25 @@
+package common
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRedactedURI(t *testing.T) {
+	newContext := func(uri string) *gin.Context {
+		req, _ := http.NewRequest("GET", uri, nil)
+		req.RequestURI = uri
+		return &gin.Context{Request: req}
+	}
+
+	Convey("Without token", t, func() {
+		So(redactedURI(newContext("/v1/projects?limit=10")), ShouldEqual, "/v1/projects?limit=10")
+	})
+
+	Convey("Token is hidden", t, func() {
+		So(redactedURI(newContext("/v1/projects/foo/stream?access_token=secret")), ShouldEqual, "/v1/projects/foo/stream?access_token=REDACTED")
+	})
+}
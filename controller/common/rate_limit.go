@@ -0,0 +1,148 @@
+package common
+
+import (
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tkusd/server/config"
+	"github.com/tkusd/server/util"
+)
+
+const (
+	headerRateLimitLimit     = "X-RateLimit-Limit"
+	headerRateLimitRemaining = "X-RateLimit-Remaining"
+	headerRateLimitReset     = "X-RateLimit-Reset"
+	headerRetryAfter         = "Retry-After"
+
+	// net/http doesn't define 429 before Go 1.6
+	statusTooManyRequests = 429
+)
+
+// RateLimitHeaders are the headers which should be exposed to browsers.
+var RateLimitHeaders = []string{
+	headerRateLimitLimit,
+	headerRateLimitRemaining,
+	headerRateLimitReset,
+	headerRetryAfter,
+}
+
+var rateLimitStore util.RateLimitStore = util.NewMemoryRateLimitStore()
+
+// SetRateLimitStore replaces the store of buckets.
+func SetRateLimitStore(store util.RateLimitStore) {
+	rateLimitStore = store
+}
+
+// CheckRateLimit takes a token from the bucket of the key and adds the rate
+// limit headers to the response. It returns an error with status 429 if the
+// bucket is empty. Errors of the store are logged and the request is allowed.
+func CheckRateLimit(c *gin.Context, key string, rule config.RateLimitRule) error {
+	if rule.Limit <= 0 || rule.Window <= 0 {
+		return nil
+	}
+
+	result, err := rateLimitStore.Take(key, rule.Limit, time.Duration(rule.Window)*time.Second)
+
+	if err != nil {
+		util.Log().Errorf("Failed to check the rate limit of %s: %v", key, err)
+		return nil
+	}
+
+	c.Header(headerRateLimitLimit, strconv.Itoa(result.Limit))
+	c.Header(headerRateLimitRemaining, strconv.Itoa(result.Remaining))
+	c.Header(headerRateLimitReset, strconv.FormatInt(result.Reset.Unix(), 10))
+
+	if result.Allowed {
+		return nil
+	}
+
+	c.Header(headerRetryAfter, strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds()))))
+
+	return &util.APIError{
+		Code:    util.RateLimitExceededError,
+		Message: "Rate limit exceeded.",
+		Status:  statusTooManyRequests,
+	}
+}
+
+// CheckAccountRateLimit checks the account rule of the group. The account is
+// identified by the name, e.g. the email.
+func CheckAccountRateLimit(c *gin.Context, group, name string) error {
+	rule := config.Config.RateLimit.Groups[group].Account
+	return CheckRateLimit(c, group+":account:"+name, rule)
+}
+
+// remoteIP returns the IP of the client. X-Forwarded-For is only read if the
+// request comes from a trusted proxy, because clients can set it to anything.
+// The header is read from the right and the first address which isn't a
+// trusted proxy is the client.
+func remoteIP(c *gin.Context) string {
+	ip, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+
+	if err != nil {
+		ip = c.Request.RemoteAddr
+	}
+
+	if !isTrustedProxy(ip) {
+		return ip
+	}
+
+	forwarded := strings.Split(c.Request.Header.Get("X-Forwarded-For"), ",")
+
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(forwarded[i])
+
+		if addr == "" {
+			continue
+		}
+
+		ip = addr
+
+		if !isTrustedProxy(addr) {
+			break
+		}
+	}
+
+	return ip
+}
+
+// isTrustedProxy returns true if the IP is in the trusted proxies.
+func isTrustedProxy(ip string) bool {
+	addr := net.ParseIP(ip)
+
+	if addr == nil {
+		return false
+	}
+
+	for _, proxy := range config.Config.RateLimit.TrustedProxies {
+		if _, network, err := net.ParseCIDR(proxy); err == nil {
+			if network.Contains(addr) {
+				return true
+			}
+		} else if addr.Equal(net.ParseIP(proxy)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RateLimit returns a middleware which limits requests of each client IP with
+// the IP rule of the group.
+func RateLimit(group string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rule := config.Config.RateLimit.Groups[group].IP
+
+		if err := CheckRateLimit(c, group+":ip:"+remoteIP(c), rule); err != nil {
+			HandleAPIError(c, err)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
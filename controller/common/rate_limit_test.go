@@ -0,0 +1,88 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tkusd/server/config"
+	"github.com/tkusd/server/util"
+)
+
+func TestRateLimit(t *testing.T) {
+	groups := config.Config.RateLimit.Groups
+	proxies := config.Config.RateLimit.TrustedProxies
+	defer func() {
+		config.Config.RateLimit.Groups = groups
+		config.Config.RateLimit.TrustedProxies = proxies
+		SetRateLimitStore(util.NewMemoryRateLimitStore())
+	}()
+
+	config.Config.RateLimit.Groups = map[string]config.RateLimitGroup{
+		"test": {IP: config.RateLimitRule{Limit: 2, Window: 60}},
+	}
+	config.Config.RateLimit.TrustedProxies = []string{"10.0.0.1", "172.16.0.0/12"}
+
+	router := gin.New()
+	router.GET("/", RateLimit("test"), func(c *gin.Context) {
+		c.Writer.WriteHeader(http.StatusNoContent)
+	})
+
+	serve := func(remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remoteAddr
+
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+
+		return res
+	}
+
+	Convey("Limit requests of each remote address", t, func() {
+		SetRateLimitStore(util.NewMemoryRateLimitStore())
+
+		r := serve("192.0.2.1:1234", "")
+		So(r.Code, ShouldEqual, http.StatusNoContent)
+		So(r.Header().Get("X-RateLimit-Limit"), ShouldEqual, "2")
+		So(r.Header().Get("X-RateLimit-Remaining"), ShouldEqual, "1")
+
+		// The port is ignored
+		So(serve("192.0.2.1:5678", "").Code, ShouldEqual, http.StatusNoContent)
+
+		r = serve("192.0.2.1:1234", "")
+		So(r.Code, ShouldEqual, statusTooManyRequests)
+		So(r.Header().Get("Retry-After"), ShouldNotBeEmpty)
+
+		// Other clients are not affected
+		So(serve("192.0.2.2:1234", "").Code, ShouldEqual, http.StatusNoContent)
+	})
+
+	Convey("X-Forwarded-For is ignored", t, func() {
+		SetRateLimitStore(util.NewMemoryRateLimitStore())
+
+		So(serve("192.0.2.1:1234", "198.51.100.1").Code, ShouldEqual, http.StatusNoContent)
+		So(serve("192.0.2.1:1234", "198.51.100.2").Code, ShouldEqual, http.StatusNoContent)
+		So(serve("192.0.2.1:1234", "198.51.100.3").Code, ShouldEqual, statusTooManyRequests)
+	})
+	Convey("X-Forwarded-For from trusted proxies", t, func() {
+		SetRateLimitStore(util.NewMemoryRateLimitStore())
+
+		So(serve("10.0.0.1:1234", "198.51.100.1").Code, ShouldEqual, http.StatusNoContent)
+		So(serve("10.0.0.1:1234", "198.51.100.1, 172.16.0.5").Code, ShouldEqual, http.StatusNoContent)
+		So(serve("10.0.0.1:1234", "198.51.100.1").Code, ShouldEqual, statusTooManyRequests)
+
+		// Addresses set by the client before the proxies are ignored
+		So(serve("10.0.0.1:1234", "203.0.113.1, 198.51.100.2").Code, ShouldEqual, http.StatusNoContent)
+		So(serve("10.0.0.1:1234", "203.0.113.2, 198.51.100.2").Code, ShouldEqual, http.StatusNoContent)
+		So(serve("10.0.0.1:1234", "203.0.113.3, 198.51.100.2").Code, ShouldEqual, statusTooManyRequests)
+
+		// The proxy itself is limited without the header
+		So(serve("10.0.0.1:1234", "").Code, ShouldEqual, http.StatusNoContent)
+	})
+}
@@ -1,18 +1,34 @@
 package controller
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/tkusd/server/config"
 	"github.com/tkusd/server/controller/common"
 	"github.com/tkusd/server/controller/v1"
+	"github.com/tkusd/server/model"
 	"github.com/tommy351/gin-cors"
 )
 
+// rateLimitExpiry is the time after which unused rate limit buckets are
+// deleted from the database.
+const rateLimitExpiry = 24 * time.Hour
+
 func Router() *gin.Engine {
 	g := gin.New()
 
+	if config.Config.RateLimit.Store == "postgres" {
+		common.SetRateLimitStore(model.NewRateLimitStore(rateLimitExpiry))
+	}
+
 	g.Use(common.Recovery)
 	g.Use(common.Logger)
-	g.Use(cors.Middleware(cors.Options{}))
+	g.Use(cors.Middleware(cors.Options{
+		AllowMethods:  []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"},
+		AllowHeaders:  []string{"Origin", "Accept", "Content-Type", "Authorization", "If-Match"},
+		ExposeHeaders: append([]string{"ETag"}, common.RateLimitHeaders...),
+	}))
 	g.GET("/", Home)
 	v1.Router(g.Group("/v1"))
 	g.NoRoute(common.NotFound)
@@ -5,14 +5,17 @@ import (
 	"bytes"
 	"crypto/sha1"
 	"encoding/base64"
+	"encoding/json"
 	"io"
 	"mime"
 	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"image"
@@ -38,6 +41,9 @@ const (
 	headerContentType     = "Content-Type"
 
 	defaultThumbSize = "medium"
+
+	assetManifestName    = "manifest.json"
+	assetManifestVersion = 1
 )
 
 var thumbSize = map[string]int{
@@ -54,17 +60,38 @@ func AssetList(c *gin.Context) error {
 		return err
 	}
 
-	if err := CheckProjectPermission(c, *projectID, false); err != nil {
+	if err := CheckAssetPermission(c, *projectID, types.PermissionRead); err != nil {
+		return err
+	}
+
+	option := &model.AssetQueryOption{
+		ProjectID: *projectID,
+	}
+
+	bindQueryOption(c, &option.QueryOption)
+
+	fields, err := model.AssetFields.ParseFields(c.Query("fields"))
+
+	if err != nil {
+		return err
+	}
+
+	list, err := model.GetAssetCollection(option)
+
+	if err != nil {
 		return err
 	}
 
-	list, err := model.GetAssetList(*projectID)
+	list.Next = common.PageURL(c, list.NextCursor)
+	list.Prev = common.PageURL(c, list.PrevCursor)
+
+	result, err := selectCollectionFields(list, fields)
 
 	if err != nil {
 		return err
 	}
 
-	return common.APIResponse(c, http.StatusOK, list)
+	return common.APIResponse(c, http.StatusOK, result)
 }
 
 type assetForm struct {
@@ -81,7 +108,80 @@ func (form *assetForm) FieldMap() binding.FieldMap {
 	}
 }
 
-func saveAsset(form *assetForm, asset *model.Asset) error {
+// readAssetData reads the file into a buffer.
+func readAssetData(r io.Reader) (*bytes.Buffer, error) {
+	// Read one more byte than the limit to know whether the file is too large
+	if limit := model.AssetSizeLimit(); limit > 0 {
+		r = io.LimitReader(r, limit+1)
+	}
+
+	buf := new(bytes.Buffer)
+
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// writeAssetData reads the file and writes it to the asset store. Quotas are
+// checked before the file is written.
+func writeAssetData(asset *model.Asset, filename string, r io.Reader) error {
+	buf, err := readAssetData(r)
+
+	if err != nil {
+		return err
+	}
+
+	if err := model.CheckAssetQuota(asset, int64(buf.Len())); err != nil {
+		return err
+	}
+
+	return storeAssetData(asset, filename, buf)
+}
+
+// storeAssetData writes the buffer as new content of the asset. The MIME type,
+// size, dimensions, hash and slug of the asset are updated. The old content is
+// kept, so it can still be used if the asset fails to be saved.
+func storeAssetData(asset *model.Asset, filename string, buf *bytes.Buffer) error {
+	var err error
+
+	// Detect the mime type
+	extname := filepath.Ext(filename)
+	asset.Type = mime.TypeByExtension(extname)
+	asset.Size = int64(buf.Len())
+
+	// Read the image dimensions
+	switch asset.Type {
+	case "image/png", "image/jpeg", "image/gif":
+		var img image.Image
+		reader := bytes.NewReader(buf.Bytes())
+
+		if img, _, err = image.Decode(reader); err != nil {
+			return err
+		}
+
+		size := img.Bounds().Size()
+		asset.Width = size.X
+		asset.Height = size.Y
+
+		break
+	}
+
+	// Write the file
+	hash := sha1.Sum(buf.Bytes())
+	asset.Hash = hash[:]
+	asset.Slug = types.NewRandomUUID().String() + extname
+
+	return asset.WriteAsset(buf)
+}
+
+// saveAsset updates the asset with the form and saves it. New content is
+// written before saving and the old content is deleted only after the asset is
+// saved. The new content is deleted instead if anything fails.
+func saveAsset(form *assetForm, asset *model.Asset, pre model.Precondition) error {
+	old := *asset
+
 	if form.Name != nil {
 		asset.Name = *form.Name
 	}
@@ -93,14 +193,6 @@ func saveAsset(form *assetForm, asset *model.Asset) error {
 	if form.Data != nil {
 		var err error
 
-		if asset.Slug != "" {
-			filepath := util.GetAssetFilePath(asset.Slug)
-
-			if err = os.Remove(filepath); err != nil {
-				return err
-			}
-		}
-
 		// Set the asset name
 		if form.Name == nil && !asset.ID.Valid() {
 			if asset.Name, err = url.QueryUnescape(form.Data.Filename); err != nil {
@@ -108,10 +200,6 @@ func saveAsset(form *assetForm, asset *model.Asset) error {
 			}
 		}
 
-		// Detect the mime type
-		extname := filepath.Ext(form.Data.Filename)
-		asset.Type = mime.TypeByExtension(extname)
-
 		// Open the multipart file stream
 		var fh io.ReadCloser
 
@@ -121,57 +209,31 @@ func saveAsset(form *assetForm, asset *model.Asset) error {
 
 		defer fh.Close()
 
-		// Read the file into a buffer
-		buf := bytes.Buffer{}
-
-		if asset.Size, err = buf.ReadFrom(fh); err != nil {
-			return err
-		}
-
-		// Read the image dimensions
-		switch asset.Type {
-		case "image/png", "image/jpeg", "image/gif":
-			var img image.Image
-			reader := bytes.NewReader(buf.Bytes())
-
-			if img, _, err = image.Decode(reader); err != nil {
-				return err
-			}
-
-			size := img.Bounds().Size()
-			asset.Width = size.X
-			asset.Height = size.Y
-
-			break
-		}
-
-		// Write the file
-		asset.Slug = types.NewRandomUUID().String() + extname
-		uploadPath := util.GetAssetFilePath(asset.Slug)
-		dir, _ := filepath.Split(uploadPath)
-
-		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		if err = writeAssetData(asset, form.Data.Filename, fh); err != nil {
 			return err
 		}
+	}
 
-		file, err := os.Create(uploadPath)
-		h := sha1.New()
-		writer := io.MultiWriter(file, h)
-
-		if err != nil {
-			return err
+	if err := asset.SaveIf(pre); err != nil {
+		if asset.Slug != old.Slug {
+			deleteAssetContent(*asset)
 		}
 
-		defer file.Close()
-
-		if _, err := buf.WriteTo(writer); err != nil {
-			return err
-		}
+		return err
+	}
 
-		asset.Hash = h.Sum(nil)
+	if old.Slug != "" && old.Slug != asset.Slug {
+		deleteAssetContent(old)
 	}
 
-	return asset.Save()
+	return nil
+}
+
+// deleteAssetContent deletes content which isn't referred by the saved record
+// of the asset. The copy is treated as an unsaved asset, so the hash store
+// keeps the content if the record still refers to the same hash.
+func deleteAssetContent(asset model.Asset) {
+	model.DeleteUnsavedAssets([]*model.Asset{&asset})
 }
 
 func AssetCreate(c *gin.Context) error {
@@ -181,7 +243,7 @@ func AssetCreate(c *gin.Context) error {
 		return err
 	}
 
-	if err := CheckUserPermission(c, project.UserID); err != nil {
+	if err := CheckAssetPermission(c, project.ID, types.PermissionWrite); err != nil {
 		return err
 	}
 
@@ -203,7 +265,7 @@ func AssetCreate(c *gin.Context) error {
 		ProjectID: project.ID,
 	}
 
-	if err := saveAsset(form, asset); err != nil {
+	if err := saveAsset(form, asset, nil); err != nil {
 		return err
 	}
 
@@ -217,10 +279,11 @@ func AssetShow(c *gin.Context) error {
 		return err
 	}
 
-	if err := CheckProjectPermission(c, asset.ProjectID, false); err != nil {
+	if err := CheckAssetPermission(c, asset.ProjectID, types.PermissionRead); err != nil {
 		return err
 	}
 
+	common.SetETag(c, asset.UpdatedAt.Time)
 	return common.APIResponse(c, http.StatusOK, asset)
 }
 
@@ -237,14 +300,20 @@ func AssetUpdate(c *gin.Context) error {
 		return err
 	}
 
-	if err := CheckProjectPermission(c, asset.ProjectID, true); err != nil {
+	if err := CheckAssetPermission(c, asset.ProjectID, types.PermissionWrite); err != nil {
 		return err
 	}
 
-	if err := saveAsset(form, asset); err != nil {
+	// Checked before the data is written as well
+	if err := common.CheckIfMatch(c, asset.UpdatedAt.Time); err != nil {
 		return err
 	}
 
+	if err := saveAsset(form, asset, common.IfMatch(c)); err != nil {
+		return err
+	}
+
+	common.SetETag(c, asset.UpdatedAt.Time)
 	return common.APIResponse(c, http.StatusOK, asset)
 }
 
@@ -255,11 +324,28 @@ func AssetDestroy(c *gin.Context) error {
 		return err
 	}
 
-	if err := CheckProjectPermission(c, asset.ProjectID, true); err != nil {
+	if err := CheckAssetPermission(c, asset.ProjectID, types.PermissionWrite); err != nil {
 		return err
 	}
 
-	if err := asset.Delete(); err != nil {
+	// Assets in use can only be deleted with force=true
+	if c.Query("force") != "true" {
+		usages, err := model.GetAssetUsages(asset.ID)
+
+		if err != nil {
+			return err
+		}
+
+		if len(usages) > 0 {
+			return &util.APIError{
+				Code:    util.AssetInUseError,
+				Message: "The asset is used by " + strconv.Itoa(len(usages)) + " element(s).",
+				Status:  http.StatusConflict,
+			}
+		}
+	}
+
+	if err := asset.DeleteIf(common.IfMatch(c)); err != nil {
 		return err
 	}
 
@@ -267,12 +353,57 @@ func AssetDestroy(c *gin.Context) error {
 	return nil
 }
 
-func shouldUseAssetCache(c *gin.Context, asset *model.Asset) bool {
-	if etag := c.Request.Header.Get(headerIfNoneMatch); etag != "" {
-		if unquotedEtag, err := strconv.Unquote(etag); err == nil {
-			if hash, err := base64.StdEncoding.DecodeString(unquotedEtag); err == nil {
-				return bytes.Compare(hash, asset.Hash) == 0
-			}
+// AssetUsages handles GET /assets/:asset_id/usages.
+func AssetUsages(c *gin.Context) error {
+	asset, err := GetAsset(c)
+
+	if err != nil {
+		return err
+	}
+
+	if err := CheckAssetPermission(c, asset.ProjectID, types.PermissionRead); err != nil {
+		return err
+	}
+
+	usages, err := model.GetAssetUsages(asset.ID)
+
+	if err != nil {
+		return err
+	}
+
+	return common.APIResponse(c, http.StatusOK, usages)
+}
+
+// getAssetThumbSize returns the thumbnail size in the query string. It returns
+// an empty string if the original file is requested.
+func getAssetThumbSize(c *gin.Context) string {
+	if !common.QueryExist(c, "size") {
+		return ""
+	}
+
+	size := c.Query("size")
+
+	if _, ok := thumbSize[size]; !ok {
+		size = defaultThumbSize
+	}
+
+	return size
+}
+
+func getAssetETag(asset *model.Asset, size string) string {
+	etag := base64.StdEncoding.EncodeToString(asset.Hash)
+
+	if size != "" {
+		etag += "-" + size
+	}
+
+	return etag
+}
+
+func shouldUseAssetCache(c *gin.Context, asset *model.Asset, etag string) bool {
+	if match := c.Request.Header.Get(headerIfNoneMatch); match != "" {
+		if unquotedEtag, err := strconv.Unquote(match); err == nil {
+			return unquotedEtag == etag
 		}
 	}
 
@@ -285,9 +416,7 @@ func shouldUseAssetCache(c *gin.Context, asset *model.Asset) bool {
 	return false
 }
 
-func addAssetBlobCacheHeader(c *gin.Context, asset *model.Asset) {
-	etag := base64.StdEncoding.EncodeToString(asset.Hash)
-
+func addAssetBlobCacheHeader(c *gin.Context, asset *model.Asset, etag string) {
 	c.Header(headerETag, strconv.Quote(etag))
 	c.Header(headerCacheControl, "private, must-revalidate, max-age=31536000") // 1 year
 	c.Header(headerLastModified, asset.UpdatedAt.UTC().Format(http.TimeFormat))
@@ -300,23 +429,144 @@ func AssetBlob(c *gin.Context) error {
 		return err
 	}
 
-	if err := CheckProjectPermission(c, asset.ProjectID, false); err != nil {
+	if err := CheckAssetPermission(c, asset.ProjectID, types.PermissionRead); err != nil {
 		return err
 	}
 
-	addAssetBlobCacheHeader(c, asset)
+	var size string
+
+	if asset.IsImage() {
+		size = getAssetThumbSize(c)
+	}
 
-	if shouldUseAssetCache(c, asset) {
+	etag := getAssetETag(asset, size)
+	addAssetBlobCacheHeader(c, asset, etag)
+
+	if shouldUseAssetCache(c, asset, etag) {
 		c.Writer.WriteHeader(http.StatusNotModified)
 		return nil
 	}
 
-	path := util.GetAssetFilePath(asset.Slug)
+	var file model.AssetFile
+
+	if size != "" {
+		file, err = asset.Thumbnail(thumbSize[size])
+	} else {
+		file, err = asset.OpenAsset()
+	}
+
+	if err != nil {
+		return err
+	}
 
-	http.ServeFile(c.Writer, c.Request, path)
+	defer file.Close()
+
+	if asset.Type != "" {
+		c.Header(headerContentType, asset.Type)
+	}
+
+	http.ServeContent(c.Writer, c.Request, asset.Name, asset.UpdatedAt.Time, file)
 	return nil
 }
 
+type assetManifest struct {
+	Version int                   `json:"version"`
+	Assets  []*assetManifestEntry `json:"assets"`
+}
+
+type assetManifestEntry struct {
+	ID          types.UUID `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Type        string     `json:"type"`
+	Size        int64      `json:"size"`
+	Width       int        `json:"width,omitempty"`
+	Height      int        `json:"height,omitempty"`
+	Hash        types.Hash `json:"hash"`
+}
+
+func parseAssetIDs(c *gin.Context) ([]types.UUID, error) {
+	var ids []types.UUID
+
+	for _, s := range util.SplitAndTrim(c.Query("ids"), ",") {
+		if s == "" {
+			continue
+		}
+
+		id := types.ParseUUID(s)
+
+		if !id.Valid() {
+			return nil, &util.APIError{
+				Field:   "ids",
+				Code:    util.UUIDError,
+				Message: "UUID is invalid.",
+			}
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func writeAssetManifest(w *zip.Writer, list []*model.Asset) error {
+	manifest := &assetManifest{
+		Version: assetManifestVersion,
+		Assets:  make([]*assetManifestEntry, len(list)),
+	}
+
+	for i, asset := range list {
+		manifest.Assets[i] = &assetManifestEntry{
+			ID:          asset.ID,
+			Name:        asset.Name,
+			Description: asset.Description,
+			Type:        asset.Type,
+			Size:        asset.Size,
+			Width:       asset.Width,
+			Height:      asset.Height,
+			Hash:        asset.Hash,
+		}
+	}
+
+	f, err := w.Create(assetManifestName)
+
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(f).Encode(manifest)
+}
+
+// writeAssetFile writes the content of the asset into the zip file with the
+// given name.
+func writeAssetFile(w *zip.Writer, name string, asset *model.Asset) error {
+	src, err := asset.OpenAsset()
+
+	if err != nil {
+		return err
+	}
+
+	defer src.Close()
+
+	header := &zip.FileHeader{
+		Name:   name,
+		Method: zip.Deflate,
+	}
+
+	header.SetModTime(asset.UpdatedAt.Time)
+
+	f, err := w.CreateHeader(header)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, src)
+	return err
+}
+
+// AssetArchive handles GET /projects/:project_id/assets/archive.
+// The zip file is streamed to the client directly.
 func AssetArchive(c *gin.Context) error {
 	projectID, err := GetIDParam(c, projectIDParam)
 
@@ -324,44 +574,226 @@ func AssetArchive(c *gin.Context) error {
 		return err
 	}
 
-	if err := CheckProjectPermission(c, *projectID, false); err != nil {
+	if err := CheckAssetPermission(c, *projectID, types.PermissionRead); err != nil {
 		return err
 	}
 
-	list, err := model.GetAssetList(*projectID)
+	ids, err := parseAssetIDs(c)
 
 	if err != nil {
 		return err
 	}
 
-	buf := new(bytes.Buffer)
-	w := zip.NewWriter(buf)
+	var list []*model.Asset
 
-	for _, asset := range list {
-		f, err := w.Create(asset.Name)
+	if len(ids) > 0 {
+		list, err = model.GetAssetListByIDs(*projectID, ids)
+	} else {
+		list, err = model.GetAssetList(*projectID)
+	}
 
-		if err != nil {
-			return err
-		}
+	if err != nil {
+		return err
+	}
 
-		var src *os.File
-		path := util.GetAssetFilePath(asset.Slug)
+	c.Header(headerContentType, "application/zip")
+	c.Header("Content-Disposition", "attachment; filename="+projectID.String()+".zip")
+	c.Writer.WriteHeader(http.StatusOK)
 
-		if src, err = os.Open(path); err != nil {
-			return err
-		}
+	// Errors can't be sent to the client once the response is started.
+	// Log them and leave the zip file incomplete.
+	w := zip.NewWriter(c.Writer)
 
-		if _, err = io.Copy(f, src); err != nil {
-			return err
+	if err := writeAssetManifest(w, list); err != nil {
+		util.Log().Errorf("Failed to write the asset manifest: %v", err)
+		return nil
+	}
+
+	for _, asset := range list {
+		if err := writeAssetFile(w, asset.Name, asset); err != nil {
+			util.Log().Errorf("Failed to write asset %s to the archive: %v", asset.ID.String(), err)
+			return nil
 		}
 	}
 
 	if err := w.Close(); err != nil {
+		util.Log().Errorf("Failed to close the asset archive: %v", err)
+	}
+
+	return nil
+}
+
+type assetImportForm struct {
+	Data *multipart.FileHeader `json:"data"`
+}
+
+func (form *assetImportForm) FieldMap() binding.FieldMap {
+	return binding.FieldMap{
+		&form.Data: "data",
+	}
+}
+
+type assetImportResult struct {
+	Name  string         `json:"name"`
+	Asset *model.Asset   `json:"asset,omitempty"`
+	Error *util.APIError `json:"error,omitempty"`
+}
+
+// openZipFile opens the uploaded file as a zip archive.
+func openZipFile(header *multipart.FileHeader) (*zip.Reader, io.Closer, error) {
+	file, err := header.Open()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	size, err := file.Seek(0, os.SEEK_END)
+
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	reader, err := zip.NewReader(file, size)
+
+	if err != nil {
+		file.Close()
+		return nil, nil, &util.APIError{
+			Field:   "data",
+			Code:    util.TypeError,
+			Message: "Data is not a valid zip file.",
+		}
+	}
+
+	return reader, file, nil
+}
+
+func readAssetManifest(f *zip.File) (*assetManifest, error) {
+	r, err := f.Open()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	manifest := new(assetManifest)
+
+	if err := json.NewDecoder(r).Decode(manifest); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// isArchiveAssetFile returns false for directories and files created by the
+// operating system, which should not be imported.
+func isArchiveAssetFile(f *zip.File) bool {
+	if f.FileInfo().IsDir() || strings.HasPrefix(f.Name, "__MACOSX/") {
+		return false
+	}
+
+	return !strings.HasPrefix(path.Base(f.Name), ".")
+}
+
+func importAssetFile(f *zip.File, asset *model.Asset) error {
+	r, err := f.Open()
+
+	if err != nil {
+		return err
+	}
+
+	defer r.Close()
+
+	if err := writeAssetData(asset, asset.Name, r); err != nil {
+		return err
+	}
+
+	if err := asset.Save(); err != nil {
+		asset.DeleteAsset()
 		return err
 	}
 
-	c.Header("Content-Type", "application/zip")
-	c.Header("Content-Disposition", "attachment; filename="+projectID.String()+".zip")
-	buf.WriteTo(c.Writer)
 	return nil
 }
+
+// AssetArchiveImport handles POST /projects/:project_id/assets/archive.
+// Each file in the zip archive is imported as an asset. Descriptions are
+// restored from the manifest if it exists.
+func AssetArchiveImport(c *gin.Context) error {
+	project, err := GetProject(c)
+
+	if err != nil {
+		return err
+	}
+
+	if err := CheckAssetPermission(c, project.ID, types.PermissionWrite); err != nil {
+		return err
+	}
+
+	form := new(assetImportForm)
+
+	if err := common.BindForm(c, form); err != nil {
+		return err
+	}
+
+	if form.Data == nil {
+		return &util.APIError{
+			Code:    util.RequiredError,
+			Field:   "data",
+			Message: "Data is required.",
+		}
+	}
+
+	reader, closer, err := openZipFile(form.Data)
+
+	if err != nil {
+		return err
+	}
+
+	defer closer.Close()
+
+	var files []*zip.File
+	descriptions := map[string]string{}
+
+	for _, f := range reader.File {
+		if f.Name == assetManifestName {
+			if manifest, err := readAssetManifest(f); err == nil {
+				for _, entry := range manifest.Assets {
+					descriptions[entry.Name] = entry.Description
+				}
+			}
+		} else if isArchiveAssetFile(f) {
+			files = append(files, f)
+		}
+	}
+
+	results := make([]*assetImportResult, len(files))
+
+	for i, f := range files {
+		asset := &model.Asset{
+			ProjectID:   project.ID,
+			Name:        path.Base(f.Name),
+			Description: descriptions[f.Name],
+		}
+
+		result := &assetImportResult{Name: f.Name}
+
+		if err := importAssetFile(f, asset); err != nil {
+			if e, ok := err.(*util.APIError); ok {
+				result.Error = e
+			} else {
+				result.Error = &util.APIError{
+					Code:    util.UnknownError,
+					Message: err.Error(),
+				}
+			}
+		} else {
+			result.Asset = asset
+		}
+
+		results[i] = result
+	}
+
+	return common.APIResponse(c, http.StatusOK, results)
+}
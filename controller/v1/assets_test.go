@@ -0,0 +1,555 @@
+package v1
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tkusd/server/controller/common"
+	"github.com/tkusd/server/model"
+	"github.com/tkusd/server/model/types"
+	"github.com/tkusd/server/util"
+)
+
+// uploadFile posts the file as the "data" field of a multipart form.
+func uploadFile(url string, token *model.Token, filename string, content []byte) *httptest.ResponseRecorder {
+	return sendFile("POST", url, token, filename, content, nil)
+}
+
+// sendFile sends the file as the "data" field of a multipart form with the
+// method and extra headers.
+func sendFile(method, url string, token *model.Token, filename string, content []byte, headers map[string]string) *httptest.ResponseRecorder {
+	body := new(bytes.Buffer)
+	w := multipart.NewWriter(body)
+	f, err := w.CreateFormFile("data", filename)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	f.Write(content)
+	w.Close()
+
+	req, _ := http.NewRequest(method, url, body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token.Secret.String())
+
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	return res
+}
+
+func createTestAsset(project *model.Project, token *model.Token, data interface{}, filename string, content []byte) *httptest.ResponseRecorder {
+	r := uploadFile("/projects/"+project.ID.String()+"/assets", token, filename, content)
+
+	if err := parseJSON(r.Body, data); err != nil {
+		log.Fatal(err)
+	}
+
+	return r
+}
+
+func createTestImage(width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	img.Set(0, 0, color.White)
+
+	buf := new(bytes.Buffer)
+
+	if err := png.Encode(buf, img); err != nil {
+		log.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestAssetBlob(t *testing.T) {
+	user := new(model.User)
+	createTestUser(user, fixtureUsers[0])
+	defer user.Delete()
+
+	token := new(model.Token)
+	createTestToken(token, fixtureUsers[0])
+	defer token.Delete()
+
+	project := new(model.Project)
+	createTestProject(user, token, project, fixtureProjects[0])
+	defer project.Delete()
+
+	asset := new(model.Asset)
+	createTestAsset(project, token, asset, "image.png", createTestImage(800, 400))
+	defer asset.Delete()
+
+	blobURL := "/assets/" + asset.ID.String() + "/blob"
+
+	Convey("Original", t, func() {
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    blobURL,
+		})
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		So(r.Header().Get("Content-Type"), ShouldEqual, "image/png")
+
+		img, _, err := image.Decode(r.Body)
+		So(err, ShouldBeNil)
+		So(img.Bounds().Dx(), ShouldEqual, 800)
+	})
+
+	Convey("Thumbnail", t, func() {
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    blobURL + "?size=small",
+		})
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		So(strings.HasSuffix(r.Header().Get("ETag"), `-small"`), ShouldBeTrue)
+
+		img, _, err := image.Decode(r.Body)
+		So(err, ShouldBeNil)
+		So(img.Bounds().Dx(), ShouldEqual, 160)
+		So(img.Bounds().Dy(), ShouldEqual, 80)
+	})
+
+	Convey("Cached thumbnail", t, func() {
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    blobURL + "?size=small",
+		})
+
+		r = request(&requestOptions{
+			Method: "GET",
+			URL:    blobURL + "?size=small",
+			Headers: map[string]string{
+				"If-None-Match": r.Header().Get("ETag"),
+			},
+		})
+
+		So(r.Code, ShouldEqual, http.StatusNotModified)
+	})
+}
+
+func readTestZip(body *bytes.Buffer) *zip.Reader {
+	reader, err := zip.NewReader(bytes.NewReader(body.Bytes()), int64(body.Len()))
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return reader
+}
+
+func TestAssetArchive(t *testing.T) {
+	user := new(model.User)
+	createTestUser(user, fixtureUsers[0])
+	defer user.Delete()
+
+	token := new(model.Token)
+	createTestToken(token, fixtureUsers[0])
+	defer token.Delete()
+
+	project := new(model.Project)
+	createTestProject(user, token, project, fixtureProjects[0])
+	defer project.Delete()
+
+	a1 := new(model.Asset)
+	createTestAsset(project, token, a1, "a.txt", []byte("foo"))
+	defer a1.Delete()
+
+	a2 := new(model.Asset)
+	createTestAsset(project, token, a2, "b.txt", []byte("bar"))
+	defer a2.Delete()
+
+	archiveURL := "/projects/" + project.ID.String() + "/assets/archive"
+
+	Convey("All assets", t, func() {
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    archiveURL,
+		})
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		So(r.Header().Get("Content-Type"), ShouldEqual, "application/zip")
+
+		reader := readTestZip(r.Body)
+		So(reader.File, ShouldHaveLength, 3)
+		So(reader.File[0].Name, ShouldEqual, "manifest.json")
+	})
+
+	Convey("Filter by IDs", t, func() {
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    archiveURL + "?ids=" + a2.ID.String(),
+		})
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+
+		reader := readTestZip(r.Body)
+		So(reader.File, ShouldHaveLength, 2)
+		So(reader.File[1].Name, ShouldEqual, "b.txt")
+	})
+
+	Convey("Invalid ID", t, func() {
+		err := new(util.APIError)
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    archiveURL + "?ids=foo",
+		})
+
+		So(r.Code, ShouldEqual, http.StatusBadRequest)
+		parseJSON(r.Body, err)
+		So(err, ShouldResemble, &util.APIError{
+			Field:   "ids",
+			Code:    util.UUIDError,
+			Message: "UUID is invalid.",
+		})
+	})
+}
+
+func createTestZip(files map[string]string) []byte {
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+
+	for name, content := range files {
+		f, err := w.Create(name)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		f.Write([]byte(content))
+	}
+
+	if err := w.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestAssetArchiveImport(t *testing.T) {
+	u1 := new(model.User)
+	createTestUser(u1, fixtureUsers[0])
+	defer u1.Delete()
+
+	u2 := new(model.User)
+	createTestUser(u2, fixtureUsers[1])
+	defer u2.Delete()
+
+	t1 := new(model.Token)
+	createTestToken(t1, fixtureUsers[0])
+	defer t1.Delete()
+
+	t2 := new(model.Token)
+	createTestToken(t2, fixtureUsers[1])
+	defer t2.Delete()
+
+	project := new(model.Project)
+	createTestProject(u1, t1, project, fixtureProjects[0])
+	defer project.Delete()
+
+	archiveURL := "/projects/" + project.ID.String() + "/assets/archive"
+
+	Convey("Success", t, func() {
+		var results []*assetImportResult
+		data := createTestZip(map[string]string{
+			"manifest.json":      `{"version": 1, "assets": [{"name": "a.txt", "description": "foo"}]}`,
+			"a.txt":              "foo",
+			"__MACOSX/._a.txt":   "",
+			"images/.DS_Store":   "",
+			"images/b.txt":       "bar",
+			"images/invalid.png": "not an image",
+		})
+
+		r := uploadFile(archiveURL, t1, "assets.zip", data)
+		So(r.Code, ShouldEqual, http.StatusOK)
+		parseJSON(r.Body, &results)
+		So(results, ShouldHaveLength, 3)
+
+		for _, result := range results {
+			switch result.Name {
+			case "a.txt":
+				So(result.Asset.Name, ShouldEqual, "a.txt")
+				So(result.Asset.Description, ShouldEqual, "foo")
+				So(result.Asset.Size, ShouldEqual, 3)
+			case "images/b.txt":
+				So(result.Asset.Name, ShouldEqual, "b.txt")
+			case "images/invalid.png":
+				So(result.Asset, ShouldBeNil)
+				So(result.Error, ShouldNotBeNil)
+			}
+
+			if result.Asset != nil {
+				result.Asset.Delete()
+			}
+		}
+	})
+
+	Convey("Not a zip file", t, func() {
+		err := new(util.APIError)
+		r := uploadFile(archiveURL, t1, "assets.zip", []byte("foo"))
+
+		So(r.Code, ShouldEqual, http.StatusBadRequest)
+		parseJSON(r.Body, err)
+		So(err, ShouldResemble, &util.APIError{
+			Field:   "data",
+			Code:    util.TypeError,
+			Message: "Data is not a valid zip file.",
+		})
+	})
+
+	Convey("Forbidden", t, func() {
+		r := uploadFile(archiveURL, t2, "assets.zip", createTestZip(nil))
+		So(r.Code, ShouldEqual, http.StatusForbidden)
+	})
+}
+
+func TestAssetUsages(t *testing.T) {
+	user := new(model.User)
+	createTestUser(user, fixtureUsers[0])
+	defer user.Delete()
+
+	token := new(model.Token)
+	createTestToken(token, fixtureUsers[0])
+	defer token.Delete()
+
+	project := new(model.Project)
+	createTestProject(user, token, project, fixtureProjects[0])
+	defer project.Delete()
+
+	screen := new(model.Element)
+	createTestElement(project, token, screen, fixtureElements[0])
+
+	asset := new(model.Asset)
+	createTestAsset(project, token, asset, "image.png", createTestImage(10, 10))
+
+	image := new(model.Element)
+	createTestChildElement(screen, token, image, map[string]interface{}{
+		"type":       types.ElementTypeImage,
+		"attributes": map[string]interface{}{"asset": asset.ID.String()},
+	})
+
+	assetURL := "/assets/" + asset.ID.String()
+	headers := map[string]string{
+		"Authorization": "Bearer " + token.Secret.String(),
+	}
+
+	Convey("List usages", t, func() {
+		var usages []*model.AssetUsage
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    assetURL + "/usages",
+		})
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		parseJSON(r.Body, &usages)
+		So(usages, ShouldHaveLength, 1)
+		So(usages[0].ElementID, ShouldResemble, image.ID)
+		So(usages[0].Field, ShouldEqual, "attributes.asset")
+	})
+
+	Convey("Assets in use can't be deleted", t, func() {
+		err := new(util.APIError)
+		r := request(&requestOptions{
+			Method:  "DELETE",
+			URL:     assetURL,
+			Headers: headers,
+		})
+
+		So(r.Code, ShouldEqual, http.StatusConflict)
+		parseJSON(r.Body, err)
+		So(err.Code, ShouldEqual, util.AssetInUseError)
+	})
+
+	Convey("Force delete removes the references", t, func() {
+		r := request(&requestOptions{
+			Method:  "DELETE",
+			URL:     assetURL + "?force=true",
+			Headers: headers,
+		})
+
+		So(r.Code, ShouldEqual, http.StatusNoContent)
+
+		e, _ := model.GetElement(image.ID)
+		So(e.Attributes, ShouldNotContainKey, "asset")
+	})
+}
+
+func TestAssetListPagination(t *testing.T) {
+	user := new(model.User)
+	createTestUser(user, fixtureUsers[0])
+	defer user.Delete()
+
+	token := new(model.Token)
+	createTestToken(token, fixtureUsers[0])
+	defer token.Delete()
+
+	project := new(model.Project)
+	createTestProject(user, token, project, fixtureProjects[0])
+	defer project.Delete()
+
+	var assets []*model.Asset
+
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		asset := new(model.Asset)
+		createTestAsset(project, token, asset, name, []byte(name))
+		assets = append(assets, asset)
+	}
+
+	listURL := "/projects/" + project.ID.String() + "/assets"
+
+	getList := func(url string) (*httptest.ResponseRecorder, *model.AssetCollection) {
+		list := new(model.AssetCollection)
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    url,
+		})
+
+		parseJSON(r.Body, list)
+		return r, list
+	}
+
+	Convey("Next and previous pages", t, func() {
+		r, list := getList(listURL + "?order=name&limit=2")
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		So(list.Data, ShouldHaveLength, 2)
+		So(list.Data[0].ID, ShouldResemble, assets[0].ID)
+		So(list.Data[1].ID, ShouldResemble, assets[1].ID)
+		So(*list.Count, ShouldEqual, 3)
+		So(list.HasMore, ShouldBeTrue)
+		So(list.Next, ShouldNotBeEmpty)
+
+		r, list = getList(list.Next)
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		So(list.Data, ShouldHaveLength, 1)
+		So(list.Data[0].ID, ShouldResemble, assets[2].ID)
+		So(list.Count, ShouldBeNil)
+		So(list.HasMore, ShouldBeFalse)
+		So(list.Next, ShouldBeEmpty)
+
+		r, list = getList(list.Prev)
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		So(list.Data, ShouldHaveLength, 2)
+		So(list.Data[0].ID, ShouldResemble, assets[0].ID)
+		So(list.Data[1].ID, ShouldResemble, assets[1].ID)
+	})
+
+	Convey("Descending order", t, func() {
+		r, list := getList(listURL + "?order=-name&limit=2")
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		So(list.Data[0].ID, ShouldResemble, assets[2].ID)
+
+		r, list = getList(list.Next)
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		So(list.Data, ShouldHaveLength, 1)
+		So(list.Data[0].ID, ShouldResemble, assets[0].ID)
+	})
+
+	Convey("Invalid cursor", t, func() {
+		err := new(util.APIError)
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    listURL + "?order=name&cursor=foo",
+		})
+
+		So(r.Code, ShouldEqual, http.StatusBadRequest)
+		parseJSON(r.Body, err)
+		So(err, ShouldResemble, &util.APIError{
+			Field:   "cursor",
+			Code:    util.ValueError,
+			Message: "Cursor is invalid.",
+		})
+	})
+
+	Convey("Cursor isn't supported by the order", t, func() {
+		err := new(util.APIError)
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    listURL + "?order=type&cursor=foo",
+		})
+
+		So(r.Code, ShouldEqual, http.StatusBadRequest)
+		parseJSON(r.Body, err)
+		So(err.Field, ShouldEqual, "order")
+	})
+}
+
+func TestAssetUpdateData(t *testing.T) {
+	user := new(model.User)
+	createTestUser(user, fixtureUsers[0])
+	defer user.Delete()
+
+	token := new(model.Token)
+	createTestToken(token, fixtureUsers[0])
+	defer token.Delete()
+
+	project := new(model.Project)
+	createTestProject(user, token, project, fixtureProjects[0])
+	defer project.Delete()
+
+	asset := new(model.Asset)
+	createTestAsset(project, token, asset, "image.png", createTestImage(800, 400))
+	defer asset.Delete()
+
+	assetURL := "/assets/" + asset.ID.String()
+
+	blobWidth := func() int {
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    assetURL + "/blob",
+		})
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+
+		img, _, err := image.Decode(r.Body)
+		So(err, ShouldBeNil)
+		return img.Bounds().Dx()
+	}
+
+	Convey("Invalid image keeps the old content", t, func() {
+		r := sendFile("PUT", assetURL, token, "image.png", []byte("not an image"), nil)
+		So(r.Code, ShouldNotEqual, http.StatusOK)
+		So(blobWidth(), ShouldEqual, 800)
+	})
+
+	Convey("Old content is deleted after saving", t, func() {
+		old, _ := model.GetAsset(asset.ID)
+		etag := common.ETag(old.UpdatedAt.Time)
+		r := sendFile("PUT", assetURL, token, "image.png", createTestImage(100, 100), map[string]string{
+			"If-Match": etag,
+		})
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		So(blobWidth(), ShouldEqual, 100)
+
+		_, err := old.OpenAsset()
+		So(err, ShouldNotBeNil)
+
+		Convey("Stale ETag keeps the current content", func() {
+			r := sendFile("PUT", assetURL, token, "image.png", createTestImage(200, 200), map[string]string{
+				"If-Match": etag,
+			})
+
+			So(r.Code, ShouldEqual, http.StatusPreconditionFailed)
+			So(blobWidth(), ShouldEqual, 100)
+		})
+	})
+}
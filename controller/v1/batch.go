@@ -0,0 +1,59 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mholt/binding"
+	"github.com/tkusd/server/controller/common"
+	"github.com/tkusd/server/model"
+	"github.com/tkusd/server/model/types"
+	"github.com/tkusd/server/util"
+)
+
+type batchForm struct {
+	Operations []*model.BatchOperation `json:"operations"`
+}
+
+func (form *batchForm) FieldMap() binding.FieldMap {
+	return binding.FieldMap{
+		&form.Operations: "operations",
+	}
+}
+
+// ProjectBatch handles POST /projects/:project_id/batch.
+// Operations are run in one transaction. Nothing is changed if any of them
+// fails.
+func ProjectBatch(c *gin.Context) error {
+	project, err := GetProject(c)
+
+	if err != nil {
+		return err
+	}
+
+	if err := CheckProjectPermission(c, project.ID, types.PermissionWrite); err != nil {
+		return err
+	}
+
+	form := new(batchForm)
+
+	if err := common.BindForm(c, form); err != nil {
+		return err
+	}
+
+	if len(form.Operations) == 0 {
+		return &util.APIError{
+			Field:   "operations",
+			Code:    util.RequiredError,
+			Message: "Operations are required.",
+		}
+	}
+
+	results, err := model.ExecuteBatch(project.ID, form.Operations)
+
+	if err != nil {
+		return err
+	}
+
+	return common.APIResponse(c, http.StatusOK, results)
+}
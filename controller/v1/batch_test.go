@@ -0,0 +1,95 @@
+package v1
+
+import (
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tkusd/server/model"
+	"github.com/tkusd/server/util"
+)
+
+func TestProjectBatch(t *testing.T) {
+	u1 := new(model.User)
+	createTestUser(u1, fixtureUsers[0])
+	defer u1.Delete()
+
+	u2 := new(model.User)
+	createTestUser(u2, fixtureUsers[1])
+	defer u2.Delete()
+
+	t1 := new(model.Token)
+	createTestToken(t1, fixtureUsers[0])
+	defer t1.Delete()
+
+	t2 := new(model.Token)
+	createTestToken(t2, fixtureUsers[1])
+	defer t2.Delete()
+
+	project := new(model.Project)
+	createTestProject(u1, t1, project, fixtureProjects[0])
+	defer project.Delete()
+
+	batch := func(token *model.Token, body interface{}, data interface{}) int {
+		r := request(&requestOptions{
+			Method: "POST",
+			URL:    "/projects/" + project.ID.String() + "/batch",
+			Body:   body,
+			Headers: map[string]string{
+				"Authorization": "Bearer " + token.Secret.String(),
+			},
+		})
+
+		parseJSON(r.Body, data)
+		return r.Code
+	}
+
+	Convey("Success", t, func() {
+		var results []*model.BatchResult
+		code := batch(t1, map[string]interface{}{
+			"operations": []map[string]interface{}{
+				{"op": "create", "type": "element", "id": "screen", "data": map[string]interface{}{"name": "Screen", "type": "screen"}},
+				{"op": "create", "type": "element", "data": map[string]interface{}{"element_id": "screen", "name": "Text", "type": "text"}},
+			},
+		}, &results)
+
+		So(code, ShouldEqual, http.StatusOK)
+		So(results, ShouldHaveLength, 2)
+		So(results[0].Op, ShouldEqual, "create")
+	})
+
+	Convey("Operations are required", t, func() {
+		err := new(util.APIError)
+		code := batch(t1, map[string]interface{}{"operations": []interface{}{}}, err)
+
+		So(code, ShouldEqual, http.StatusBadRequest)
+		So(err, ShouldResemble, &util.APIError{
+			Field:   "operations",
+			Code:    util.RequiredError,
+			Message: "Operations are required.",
+		})
+	})
+
+	Convey("Null operation", t, func() {
+		err := new(util.APIError)
+		code := batch(t1, map[string]interface{}{"operations": []interface{}{nil}}, err)
+
+		So(code, ShouldEqual, http.StatusBadRequest)
+		So(err, ShouldResemble, &util.APIError{
+			Field:   "operations.0",
+			Code:    util.RequiredError,
+			Message: "Operation is required.",
+		})
+	})
+
+	Convey("Forbidden", t, func() {
+		err := new(util.APIError)
+		code := batch(t2, map[string]interface{}{
+			"operations": []map[string]interface{}{
+				{"op": "create", "type": "element", "data": map[string]interface{}{"name": "Screen", "type": "screen"}},
+			},
+		}, err)
+
+		So(code, ShouldEqual, http.StatusForbidden)
+	})
+}
@@ -0,0 +1,219 @@
+package v1
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tkusd/server/controller/common"
+	"github.com/tkusd/server/model"
+	"github.com/tkusd/server/util"
+)
+
+const (
+	bundleProjectName = "project.json"
+	bundleAssetDir    = "assets/"
+)
+
+// ProjectExport handles GET /projects/:project_id/export.
+// The bundle is a zip file which contains the project data in project.json and
+// the content of assets in the assets directory.
+func ProjectExport(c *gin.Context) error {
+	project, err := getProjectWithOwner(c)
+
+	if err != nil {
+		return err
+	}
+
+	bundle, err := model.ExportProject(project)
+
+	if err != nil {
+		return err
+	}
+
+	c.Header(headerContentType, "application/zip")
+	c.Header("Content-Disposition", "attachment; filename="+project.ID.String()+".zip")
+	c.Writer.WriteHeader(http.StatusOK)
+
+	// Errors can't be sent to the client once the response is started.
+	// Log them and leave the zip file incomplete.
+	w := zip.NewWriter(c.Writer)
+	f, err := w.Create(bundleProjectName)
+
+	if err != nil {
+		util.Log().Errorf("Failed to write the project bundle: %v", err)
+		return nil
+	}
+
+	if err := json.NewEncoder(f).Encode(bundle); err != nil {
+		util.Log().Errorf("Failed to write the project bundle: %v", err)
+		return nil
+	}
+
+	for _, asset := range bundle.Assets {
+		if err := writeAssetFile(w, bundleAssetDir+asset.ID.String(), asset); err != nil {
+			util.Log().Errorf("Failed to write asset %s to the bundle: %v", asset.ID.String(), err)
+			return nil
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		util.Log().Errorf("Failed to close the project bundle: %v", err)
+	}
+
+	return nil
+}
+
+func readProjectBundle(files map[string]*zip.File) (*model.Bundle, error) {
+	f, ok := files[bundleProjectName]
+
+	if !ok {
+		return nil, &util.APIError{
+			Field:   "data",
+			Code:    util.TypeError,
+			Message: "project.json is not found in the bundle.",
+		}
+	}
+
+	r, err := f.Open()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	bundle := new(model.Bundle)
+
+	if err := json.NewDecoder(r).Decode(bundle); err != nil {
+		return nil, &util.APIError{
+			Field:   "data",
+			Code:    util.DeserializationError,
+			Message: "project.json is not a valid JSON file.",
+		}
+	}
+
+	return bundle, nil
+}
+
+// getBundleAssetFiles returns the files of assets in the bundle. Sizes of
+// assets are set to the sizes of files, so quotas can be checked before the
+// files are read. The zip reader fails if a file doesn't match its size.
+func getBundleAssetFiles(files map[string]*zip.File, assets []*model.Asset) ([]*zip.File, error) {
+	result := make([]*zip.File, len(assets))
+
+	for i, asset := range assets {
+		f, ok := files[bundleAssetDir+asset.ID.String()]
+
+		if !ok {
+			return nil, &util.APIError{
+				Field:   "data",
+				Code:    util.TypeError,
+				Message: "Asset " + asset.ID.String() + " is not found in the bundle.",
+			}
+		}
+
+		asset.Size = int64(f.UncompressedSize64)
+		result[i] = f
+	}
+
+	return result, nil
+}
+
+// writeBundleAssets writes the content of assets in the bundle to the asset
+// store. Quotas must be checked with model.CheckBundleQuota before.
+func writeBundleAssets(files []*zip.File, assets []*model.Asset) error {
+	for i, asset := range assets {
+		r, err := files[i].Open()
+
+		if err != nil {
+			return err
+		}
+
+		buf, err := readAssetData(r)
+		r.Close()
+
+		if err != nil {
+			return err
+		}
+
+		if err := storeAssetData(asset, asset.Name, buf); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ProjectImport handles POST /users/:user_id/projects/import.
+func ProjectImport(c *gin.Context) error {
+	userID, err := GetIDParam(c, userIDParam)
+
+	if err != nil {
+		return err
+	}
+
+	if err := CheckUserPermission(c, *userID); err != nil {
+		return err
+	}
+
+	// The bundle is uploaded in the same form as asset archives
+	form := new(assetImportForm)
+
+	if err := common.BindForm(c, form); err != nil {
+		return err
+	}
+
+	if form.Data == nil {
+		return &util.APIError{
+			Code:    util.RequiredError,
+			Field:   "data",
+			Message: "Data is required.",
+		}
+	}
+
+	reader, closer, err := openZipFile(form.Data)
+
+	if err != nil {
+		return err
+	}
+
+	defer closer.Close()
+
+	files := map[string]*zip.File{}
+
+	for _, f := range reader.File {
+		files[f.Name] = f
+	}
+
+	bundle, err := readProjectBundle(files)
+
+	if err != nil {
+		return err
+	}
+
+	assetFiles, err := getBundleAssetFiles(files, bundle.Assets)
+
+	if err != nil {
+		return err
+	}
+
+	if err := model.CheckBundleQuota(*userID, bundle); err != nil {
+		return err
+	}
+
+	if err := writeBundleAssets(assetFiles, bundle.Assets); err != nil {
+		model.DeleteUnsavedAssets(bundle.Assets)
+		return err
+	}
+
+	project, err := model.ImportBundle(*userID, bundle)
+
+	if err != nil {
+		model.DeleteUnsavedAssets(bundle.Assets)
+		return err
+	}
+
+	return common.APIResponse(c, http.StatusCreated, project)
+}
@@ -0,0 +1,102 @@
+package v1
+
+import (
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tkusd/server/config"
+	"github.com/tkusd/server/model"
+	"github.com/tkusd/server/util"
+)
+
+func TestProjectBundle(t *testing.T) {
+	quota := config.Config.Quota
+	defer func() { config.Config.Quota = quota }()
+
+	u1 := new(model.User)
+	createTestUser(u1, fixtureUsers[0])
+	defer u1.Delete()
+
+	u2 := new(model.User)
+	createTestUser(u2, fixtureUsers[1])
+	defer u2.Delete()
+
+	t1 := new(model.Token)
+	createTestToken(t1, fixtureUsers[0])
+	defer t1.Delete()
+
+	t2 := new(model.Token)
+	createTestToken(t2, fixtureUsers[1])
+	defer t2.Delete()
+
+	project := new(model.Project)
+	createTestProject(u1, t1, project, fixtureProjects[0])
+	defer project.Delete()
+
+	element := new(model.Element)
+	createTestElement(project, t1, element, fixtureElements[0])
+
+	asset := new(model.Asset)
+	createTestAsset(project, t1, asset, "a.txt", []byte("foo"))
+
+	exportBundle := func() []byte {
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    "/projects/" + project.ID.String() + "/export",
+		})
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		return r.Body.Bytes()
+	}
+
+	importURL := "/users/" + u2.ID.String() + "/projects/import"
+
+	Convey("Export", t, func() {
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    "/projects/" + project.ID.String() + "/export",
+		})
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		So(r.Header().Get("Content-Type"), ShouldEqual, "application/zip")
+
+		reader := readTestZip(r.Body)
+		So(reader.File, ShouldHaveLength, 2)
+		So(reader.File[0].Name, ShouldEqual, "project.json")
+		So(reader.File[1].Name, ShouldEqual, "assets/"+asset.ID.String())
+	})
+
+	Convey("Import", t, func() {
+		p := new(model.Project)
+		r := uploadFile(importURL, t2, "bundle.zip", exportBundle())
+		parseJSON(r.Body, p)
+		defer p.Delete()
+
+		So(r.Code, ShouldEqual, http.StatusCreated)
+		So(p.ID, ShouldNotResemble, project.ID)
+		So(p.UserID, ShouldResemble, u2.ID)
+		So(p.Title, ShouldEqual, project.Title)
+
+		assets, _ := model.GetAssetList(p.ID)
+		So(assets, ShouldHaveLength, 1)
+		So(assets[0].Size, ShouldEqual, 3)
+	})
+
+	Convey("Import into other's account", t, func() {
+		r := uploadFile(importURL, t1, "bundle.zip", exportBundle())
+		So(r.Code, ShouldEqual, http.StatusForbidden)
+	})
+
+	Convey("Quota of the importer", t, func() {
+		config.Config.Quota.UserAssetSize = 2
+		defer func() { config.Config.Quota.UserAssetSize = quota.UserAssetSize }()
+
+		err := new(util.APIError)
+		r := uploadFile(importURL, t2, "bundle.zip", exportBundle())
+		parseJSON(r.Body, err)
+
+		So(r.Code, ShouldEqual, http.StatusForbidden)
+		So(err.Code, ShouldEqual, util.QuotaExceededError)
+	})
+}
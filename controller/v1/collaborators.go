@@ -0,0 +1,171 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mholt/binding"
+	"github.com/tkusd/server/controller/common"
+	"github.com/tkusd/server/model"
+	"github.com/tkusd/server/model/types"
+	"github.com/tkusd/server/util"
+)
+
+// CollaboratorList handles GET /projects/:project_id/collaborators.
+func CollaboratorList(c *gin.Context) error {
+	projectID, err := GetIDParam(c, projectIDParam)
+
+	if err != nil {
+		return err
+	}
+
+	if err := CheckProjectPermission(c, *projectID, types.PermissionRead); err != nil {
+		return err
+	}
+
+	list, err := model.GetCollaboratorList(*projectID)
+
+	if err != nil {
+		return err
+	}
+
+	return common.APIResponse(c, http.StatusOK, list)
+}
+
+type collaboratorForm struct {
+	UserID     *types.UUID       `json:"user_id"`
+	Email      *string           `json:"email"`
+	Permission *types.Permission `json:"permission"`
+}
+
+func (form *collaboratorForm) FieldMap() binding.FieldMap {
+	return binding.FieldMap{
+		&form.UserID:     "user_id",
+		&form.Email:      "email",
+		&form.Permission: "permission",
+	}
+}
+
+// CollaboratorCreate handles POST /projects/:project_id/collaborators.
+func CollaboratorCreate(c *gin.Context) error {
+	project, err := GetProject(c)
+
+	if err != nil {
+		return err
+	}
+
+	if err := CheckProjectPermission(c, project.ID, types.PermissionAdmin); err != nil {
+		return err
+	}
+
+	form := new(collaboratorForm)
+
+	if err := common.BindForm(c, form); err != nil {
+		return err
+	}
+
+	var user *model.User
+
+	if form.UserID != nil {
+		user, _ = model.GetUser(*form.UserID)
+	} else if form.Email != nil {
+		user, _ = model.GetUserByEmail(*form.Email)
+	} else {
+		return &util.APIError{
+			Field:   "user_id",
+			Code:    util.RequiredError,
+			Message: "User ID or email is required.",
+		}
+	}
+
+	if user == nil {
+		return &util.APIError{
+			Field:   "user_id",
+			Code:    util.UserNotFoundError,
+			Message: "User does not exist.",
+		}
+	}
+
+	collaborator := &model.Collaborator{
+		ProjectID:  project.ID,
+		UserID:     user.ID,
+		Permission: types.PermissionRead,
+	}
+
+	if form.Permission != nil {
+		collaborator.Permission = *form.Permission
+	}
+
+	if err := collaborator.Save(); err != nil {
+		return err
+	}
+
+	return common.APIResponse(c, http.StatusCreated, collaborator)
+}
+
+// CollaboratorShow handles GET /collaborators/:collaborator_id.
+func CollaboratorShow(c *gin.Context) error {
+	collaborator, err := GetCollaborator(c)
+
+	if err != nil {
+		return err
+	}
+
+	if err := CheckProjectPermission(c, collaborator.ProjectID, types.PermissionRead); err != nil {
+		return err
+	}
+
+	return common.APIResponse(c, http.StatusOK, collaborator)
+}
+
+// CollaboratorUpdate handles PUT /collaborators/:collaborator_id.
+func CollaboratorUpdate(c *gin.Context) error {
+	form := new(collaboratorForm)
+
+	if err := common.BindForm(c, form); err != nil {
+		return err
+	}
+
+	collaborator, err := GetCollaborator(c)
+
+	if err != nil {
+		return err
+	}
+
+	if err := CheckProjectPermission(c, collaborator.ProjectID, types.PermissionAdmin); err != nil {
+		return err
+	}
+
+	if form.Permission != nil {
+		collaborator.Permission = *form.Permission
+	}
+
+	if err := collaborator.Save(); err != nil {
+		return err
+	}
+
+	return common.APIResponse(c, http.StatusOK, collaborator)
+}
+
+// CollaboratorDestroy handles DELETE /collaborators/:collaborator_id.
+// Collaborators can remove themselves from the project.
+func CollaboratorDestroy(c *gin.Context) error {
+	collaborator, err := GetCollaborator(c)
+
+	if err != nil {
+		return err
+	}
+
+	if err := CheckUserPermission(c, collaborator.UserID); err != nil {
+		if err := CheckProjectPermission(c, collaborator.ProjectID, types.PermissionAdmin); err != nil {
+			return err
+		}
+	}
+
+	if err := collaborator.Delete(); err != nil {
+		return err
+	}
+
+	c.Writer.WriteHeader(http.StatusNoContent)
+	return nil
+}
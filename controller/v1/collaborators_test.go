@@ -0,0 +1,141 @@
+package v1
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tkusd/server/model"
+	"github.com/tkusd/server/model/types"
+	"github.com/tkusd/server/util"
+)
+
+func createTestCollaborator(project *model.Project, token *model.Token, data interface{}, body interface{}) *httptest.ResponseRecorder {
+	r := request(&requestOptions{
+		Method: "POST",
+		URL:    "/projects/" + project.ID.String() + "/collaborators",
+		Body:   body,
+		Headers: map[string]string{
+			"Authorization": "Bearer " + token.Secret.String(),
+		},
+	})
+
+	if err := parseJSON(r.Body, data); err != nil {
+		log.Fatal(err)
+	}
+
+	return r
+}
+
+func TestCollaborator(t *testing.T) {
+	u1 := new(model.User)
+	createTestUser(u1, fixtureUsers[0])
+	defer u1.Delete()
+
+	u2 := new(model.User)
+	createTestUser(u2, fixtureUsers[1])
+	defer u2.Delete()
+
+	t1 := new(model.Token)
+	createTestToken(t1, fixtureUsers[0])
+	defer t1.Delete()
+
+	t2 := new(model.Token)
+	createTestToken(t2, fixtureUsers[1])
+	defer t2.Delete()
+
+	project := new(model.Project)
+	createTestProject(u1, t1, project, fixtureProjects[1])
+	defer project.Delete()
+
+	Convey("Only admins can add collaborators", t, func() {
+		err := new(util.APIError)
+		r := createTestCollaborator(project, t2, err, map[string]interface{}{
+			"user_id": u2.ID.String(),
+		})
+
+		So(r.Code, ShouldEqual, http.StatusForbidden)
+		So(err, ShouldResemble, &util.APIError{
+			Code:    util.UserForbiddenError,
+			Message: "You are forbidden to access.",
+		})
+	})
+
+	Convey("User does not exist", t, func() {
+		err := new(util.APIError)
+		r := createTestCollaborator(project, t1, err, map[string]interface{}{
+			"email": "nobody@abc.com",
+		})
+
+		So(r.Code, ShouldEqual, http.StatusBadRequest)
+		So(err, ShouldResemble, &util.APIError{
+			Field:   "user_id",
+			Code:    util.UserNotFoundError,
+			Message: "User does not exist.",
+		})
+	})
+
+	Convey("Add, update and remove a collaborator", t, func() {
+		collaborator := new(model.Collaborator)
+		r := createTestCollaborator(project, t1, collaborator, map[string]interface{}{
+			"email":      fixtureUsers[1].Email,
+			"permission": map[string]bool{"read": true},
+		})
+		defer collaborator.Delete()
+
+		So(r.Code, ShouldEqual, http.StatusCreated)
+		So(collaborator.ProjectID, ShouldResemble, project.ID)
+		So(collaborator.UserID, ShouldResemble, u2.ID)
+		So(collaborator.Permission, ShouldEqual, types.PermissionRead)
+
+		// The collaborator can read the private project now
+		r = request(&requestOptions{
+			Method: "GET",
+			URL:    "/projects/" + project.ID.String(),
+			Headers: map[string]string{
+				"Authorization": "Bearer " + t2.Secret.String(),
+			},
+		})
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+
+		// But can't manage collaborators
+		r = request(&requestOptions{
+			Method: "PUT",
+			URL:    "/collaborators/" + collaborator.ID.String(),
+			Body:   map[string]interface{}{"permission": map[string]bool{"admin": true}},
+			Headers: map[string]string{
+				"Authorization": "Bearer " + t2.Secret.String(),
+			},
+		})
+
+		So(r.Code, ShouldEqual, http.StatusForbidden)
+
+		var list []*model.Collaborator
+		r = request(&requestOptions{
+			Method: "GET",
+			URL:    "/projects/" + project.ID.String() + "/collaborators",
+			Headers: map[string]string{
+				"Authorization": "Bearer " + t1.Secret.String(),
+			},
+		})
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		parseJSON(r.Body, &list)
+		So(list, ShouldHaveLength, 1)
+		So(list[0].ID, ShouldResemble, collaborator.ID)
+
+		// Collaborators can remove themselves
+		r = request(&requestOptions{
+			Method: "DELETE",
+			URL:    "/collaborators/" + collaborator.ID.String(),
+			Headers: map[string]string{
+				"Authorization": "Bearer " + t2.Secret.String(),
+			},
+		})
+
+		So(r.Code, ShouldEqual, http.StatusNoContent)
+	})
+}
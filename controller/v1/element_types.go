@@ -0,0 +1,14 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tkusd/server/controller/common"
+	"github.com/tkusd/server/model"
+)
+
+// ElementTypeList handles GET /element_types.
+func ElementTypeList(c *gin.Context) error {
+	return common.APIResponse(c, http.StatusOK, model.GetElementTypeList())
+}
@@ -0,0 +1,26 @@
+package v1
+
+import (
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tkusd/server/model"
+	"github.com/tkusd/server/model/types"
+)
+
+func TestElementTypeList(t *testing.T) {
+	Convey("Success", t, func() {
+		var list []*model.ElementType
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    "/element_types",
+		})
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		parseJSON(r.Body, &list)
+		So(list, ShouldHaveLength, 7)
+		So(list[0].Name, ShouldEqual, types.ElementTypeButton)
+		So(list[0].Attributes.Properties, ShouldContainKey, "text")
+	})
+}
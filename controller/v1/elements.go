@@ -1,6 +1,9 @@
 package v1
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"mime"
 	"net/http"
 	"strconv"
 
@@ -9,9 +12,10 @@ import (
 	"github.com/tkusd/server/controller/common"
 	"github.com/tkusd/server/model"
 	"github.com/tkusd/server/model/types"
+	"github.com/tkusd/server/util"
 )
 
-func parseElementListQueryOption(c *gin.Context) *model.ElementQueryOption {
+func parseElementListQueryOption(c *gin.Context) (*model.ElementQueryOption, []string, error) {
 	var option model.ElementQueryOption
 
 	if common.QueryExist(c, "flat") {
@@ -28,13 +32,15 @@ func parseElementListQueryOption(c *gin.Context) *model.ElementQueryOption {
 		}
 	}
 
-	// This feature is disabled temporarily since I can't control the returned fields.
-	/*
-		if sel := query.Get("select"); sel != "" {
-			option.Select = util.SplitAndTrim(sel, ",")
-		}*/
+	fields, err := model.ElementFields.ParseFields(c.Query("fields"))
 
-	return &option
+	if err != nil {
+		return nil, nil, err
+	}
+
+	option.Select = elementColumns(fields)
+
+	return &option, fields, nil
 }
 
 // ElementList handles GET /projects/:project_id/elements.
@@ -45,10 +51,15 @@ func ElementList(c *gin.Context) error {
 		return err
 	}
 
-	option := parseElementListQueryOption(c)
+	option, fields, err := parseElementListQueryOption(c)
+
+	if err != nil {
+		return err
+	}
+
 	option.ProjectID = projectID
 
-	if err := CheckProjectPermission(c, *projectID, false); err != nil {
+	if err := CheckProjectPermission(c, *projectID, types.PermissionRead); err != nil {
 		return err
 	}
 
@@ -58,7 +69,13 @@ func ElementList(c *gin.Context) error {
 		return err
 	}
 
-	return common.APIResponse(c, http.StatusOK, list)
+	result, err := selectFields(list, fields)
+
+	if err != nil {
+		return err
+	}
+
+	return common.APIResponse(c, http.StatusOK, result)
 }
 
 func ChildElementList(c *gin.Context) error {
@@ -68,10 +85,15 @@ func ChildElementList(c *gin.Context) error {
 		return err
 	}
 
-	option := parseElementListQueryOption(c)
+	option, fields, err := parseElementListQueryOption(c)
+
+	if err != nil {
+		return err
+	}
+
 	option.ElementID = &element.ID
 
-	if err := CheckProjectPermission(c, element.ProjectID, false); err != nil {
+	if err := CheckProjectPermission(c, element.ProjectID, types.PermissionRead); err != nil {
 		return err
 	}
 
@@ -81,7 +103,13 @@ func ChildElementList(c *gin.Context) error {
 		return err
 	}
 
-	return common.APIResponse(c, http.StatusOK, list)
+	result, err := selectFields(list, fields)
+
+	if err != nil {
+		return err
+	}
+
+	return common.APIResponse(c, http.StatusOK, result)
 }
 
 type elementForm struct {
@@ -104,7 +132,7 @@ func (form *elementForm) FieldMap() binding.FieldMap {
 	}
 }
 
-func saveElement(form *elementForm, element *model.Element) error {
+func saveElement(form *elementForm, element *model.Element, pre model.Precondition) error {
 	if form.Name != nil {
 		element.Name = *form.Name
 	}
@@ -125,7 +153,7 @@ func saveElement(form *elementForm, element *model.Element) error {
 		element.IsVisible = *form.IsVisible
 	}
 
-	return element.Save()
+	return element.SaveIf(pre)
 }
 
 // ElementCreate handles POST /projects/:project_id/elements.
@@ -136,7 +164,11 @@ func ElementCreate(c *gin.Context) error {
 		return err
 	}
 
-	if err := CheckUserPermission(c, project.UserID); err != nil {
+	if err := CheckProjectPermission(c, project.ID, types.PermissionWrite); err != nil {
+		return err
+	}
+
+	if err := model.CheckElementQuota(project.ID, 1); err != nil {
 		return err
 	}
 
@@ -151,7 +183,7 @@ func ElementCreate(c *gin.Context) error {
 		IsVisible: true,
 	}
 
-	if err := saveElement(form, element); err != nil {
+	if err := saveElement(form, element, nil); err != nil {
 		return err
 	}
 
@@ -167,7 +199,11 @@ func ChildElementCreate(c *gin.Context) error {
 
 	projectID := model.GetProjectIDForElement(*parentID)
 
-	if err := CheckProjectPermission(c, projectID, true); err != nil {
+	if err := CheckProjectPermission(c, projectID, types.PermissionWrite); err != nil {
+		return err
+	}
+
+	if err := model.CheckElementQuota(projectID, 1); err != nil {
 		return err
 	}
 
@@ -183,7 +219,7 @@ func ChildElementCreate(c *gin.Context) error {
 		IsVisible: true,
 	}
 
-	if err := saveElement(form, element); err != nil {
+	if err := saveElement(form, element, nil); err != nil {
 		return err
 	}
 
@@ -198,10 +234,11 @@ func ElementShow(c *gin.Context) error {
 		return err
 	}
 
-	if err := CheckProjectPermission(c, element.ProjectID, false); err != nil {
+	if err := CheckProjectPermission(c, element.ProjectID, types.PermissionRead); err != nil {
 		return err
 	}
 
+	common.SetETag(c, element.UpdatedAt.Time)
 	return common.APIResponse(c, http.StatusOK, element)
 }
 
@@ -219,11 +256,11 @@ func ElementUpdate(c *gin.Context) error {
 		return err
 	}
 
-	if err := CheckProjectPermission(c, element.ProjectID, true); err != nil {
+	if err := CheckProjectPermission(c, element.ProjectID, types.PermissionWrite); err != nil {
 		return err
 	}
 
-	if err := saveElement(form, element); err != nil {
+	if err := saveElement(form, element, common.IfMatch(c)); err != nil {
 		return err
 	}
 
@@ -238,6 +275,7 @@ func ElementUpdate(c *gin.Context) error {
 		}
 	}
 
+	common.SetETag(c, element.UpdatedAt.Time)
 	return common.APIResponse(c, http.StatusOK, element)
 }
 
@@ -249,11 +287,11 @@ func ElementDestroy(c *gin.Context) error {
 		return err
 	}
 
-	if err := CheckProjectPermission(c, element.ProjectID, true); err != nil {
+	if err := CheckProjectPermission(c, element.ProjectID, types.PermissionWrite); err != nil {
 		return err
 	}
 
-	if err := element.Delete(); err != nil {
+	if err := element.DeleteIf(common.IfMatch(c)); err != nil {
 		return err
 	}
 
@@ -268,12 +306,17 @@ func ElementFull(c *gin.Context) error {
 		return err
 	}
 
-	option := parseElementListQueryOption(c)
+	option, fields, err := parseElementListQueryOption(c)
+
+	if err != nil {
+		return err
+	}
+
 	option.ElementID = &element.ID
 	option.ProjectID = &element.ProjectID
 	option.WithEvents = true
 
-	if err := CheckProjectPermission(c, element.ProjectID, false); err != nil {
+	if err := CheckProjectPermission(c, element.ProjectID, types.PermissionRead); err != nil {
 		return err
 	}
 
@@ -289,13 +332,186 @@ func ElementFull(c *gin.Context) error {
 		return err
 	}
 
+	// Fields are only applied to descendants
+	elements, err := selectFields(list, fields)
+
+	if err != nil {
+		return err
+	}
+
 	return common.APIResponse(c, http.StatusOK, struct {
 		*model.Element
-		Elements []*model.Element `json:"elements"`
-		Events   []*model.Event   `json:"events"`
+		Elements interface{}    `json:"elements"`
+		Events   []*model.Event `json:"events"`
 	}{
 		Element:  element,
-		Elements: list,
+		Elements: elements,
 		Events:   events,
 	})
 }
+
+type elementPositionForm struct {
+	ElementID *types.UUID `json:"element_id"`
+	Index     *int        `json:"index"`
+}
+
+func (form *elementPositionForm) FieldMap() binding.FieldMap {
+	return binding.FieldMap{
+		&form.ElementID: "element_id",
+		&form.Index:     "index",
+	}
+}
+
+func bindElementPosition(c *gin.Context) (*model.ElementPosition, error) {
+	form := new(elementPositionForm)
+
+	if err := common.BindForm(c, form); err != nil {
+		return nil, err
+	}
+
+	pos := new(model.ElementPosition)
+
+	if form.ElementID != nil {
+		pos.ElementID = *form.ElementID
+	}
+
+	if form.Index != nil {
+		pos.Index = *form.Index
+	}
+
+	return pos, nil
+}
+
+// ElementCopy handles POST /elements/:element_id/copy.
+func ElementCopy(c *gin.Context) error {
+	element, err := GetElement(c)
+
+	if err != nil {
+		return err
+	}
+
+	if err := CheckProjectPermission(c, element.ProjectID, types.PermissionWrite); err != nil {
+		return err
+	}
+
+	pos, err := bindElementPosition(c)
+
+	if err != nil {
+		return err
+	}
+
+	result, err := model.CopyElement(element, pos)
+
+	if err != nil {
+		return err
+	}
+
+	return common.APIResponse(c, http.StatusCreated, result)
+}
+
+// ElementMove handles POST /elements/:element_id/move.
+func ElementMove(c *gin.Context) error {
+	element, err := GetElement(c)
+
+	if err != nil {
+		return err
+	}
+
+	if err := CheckProjectPermission(c, element.ProjectID, types.PermissionWrite); err != nil {
+		return err
+	}
+
+	pos, err := bindElementPosition(c)
+
+	if err != nil {
+		return err
+	}
+
+	if err := model.MoveElement(element, pos); err != nil {
+		return err
+	}
+
+	return common.APIResponse(c, http.StatusOK, element)
+}
+
+const (
+	contentTypeJSONPatch  = "application/json-patch+json"
+	contentTypeMergePatch = "application/merge-patch+json"
+)
+
+// parseElementPatch returns a function which applies the patch in the request
+// body. The format is determined by Content-Type. For application/json, an
+// array is a JSON Patch and an object is a merge patch.
+func parseElementPatch(c *gin.Context) (func(doc interface{}) (interface{}, error), error) {
+	var body interface{}
+
+	data, err := ioutil.ReadAll(c.Request.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, &util.APIError{
+			Code:    util.DeserializationError,
+			Message: err.Error(),
+		}
+	}
+
+	contentType, _, _ := mime.ParseMediaType(c.Request.Header.Get(headerContentType))
+	_, isArray := body.([]interface{})
+
+	switch {
+	case contentType == contentTypeJSONPatch || (contentType != contentTypeMergePatch && isArray):
+		var ops []util.PatchOperation
+
+		if err := json.Unmarshal(data, &ops); err != nil {
+			return nil, &util.APIError{
+				Code:    util.DeserializationError,
+				Message: err.Error(),
+			}
+		}
+
+		return func(doc interface{}) (interface{}, error) {
+			return util.ApplyJSONPatch(doc, ops)
+		}, nil
+
+	case contentType == contentTypeMergePatch || contentType == "application/json":
+		return func(doc interface{}) (interface{}, error) {
+			return util.ApplyMergePatch(doc, body)
+		}, nil
+	}
+
+	return nil, &util.APIError{
+		Code:    util.ContentTypeError,
+		Message: "Content-Type must be " + contentTypeJSONPatch + " or " + contentTypeMergePatch + ".",
+		Status:  http.StatusUnsupportedMediaType,
+	}
+}
+
+// ElementPatch handles PATCH /elements/:element_id.
+// Only attributes and styles can be patched.
+func ElementPatch(c *gin.Context) error {
+	element, err := GetElement(c)
+
+	if err != nil {
+		return err
+	}
+
+	if err := CheckProjectPermission(c, element.ProjectID, types.PermissionWrite); err != nil {
+		return err
+	}
+
+	patch, err := parseElementPatch(c)
+
+	if err != nil {
+		return err
+	}
+
+	if element, err = model.PatchElement(element.ID, patch, common.IfMatch(c)); err != nil {
+		return err
+	}
+
+	common.SetETag(c, element.UpdatedAt.Time)
+	return common.APIResponse(c, http.StatusOK, element)
+}
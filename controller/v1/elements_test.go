@@ -10,6 +10,7 @@ import (
 	"code.google.com/p/go-uuid/uuid"
 
 	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tkusd/server/controller/common"
 	"github.com/tkusd/server/model"
 	"github.com/tkusd/server/model/types"
 	"github.com/tkusd/server/util"
@@ -21,7 +22,7 @@ func createTestElement(project *model.Project, token *model.Token, data interfac
 		URL:    "/projects/" + project.ID.String() + "/elements",
 		Body:   body,
 		Headers: map[string]string{
-			"Authorization": "Bearer " + token.ID.String(),
+			"Authorization": "Bearer " + token.Secret.String(),
 		},
 	})
 
@@ -78,7 +79,7 @@ func TestElementCreate(t *testing.T) {
 			Method: "POST",
 			URL:    "/projects/" + p1.ID.String() + "/elements",
 			Headers: map[string]string{
-				"Authorization": "Bearer " + t2.ID.String(),
+				"Authorization": "Bearer " + t2.Secret.String(),
 			},
 			Body: map[string]interface{}{},
 		})
@@ -146,7 +147,7 @@ func TestElementShow(t *testing.T) {
 			Method: "GET",
 			URL:    "/elements/" + e1.ID.String(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + t1.ID.String(),
+				"Authorization": "Bearer " + t1.Secret.String(),
 			},
 		})
 
@@ -167,7 +168,7 @@ func TestElementShow(t *testing.T) {
 			Method: "GET",
 			URL:    "/elements/" + e1.ID.String(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + t2.ID.String(),
+				"Authorization": "Bearer " + t2.Secret.String(),
 			},
 		})
 
@@ -182,7 +183,7 @@ func TestElementShow(t *testing.T) {
 			Method: "GET",
 			URL:    "/elements/" + e2.ID.String(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + t1.ID.String(),
+				"Authorization": "Bearer " + t1.Secret.String(),
 			},
 		})
 
@@ -248,7 +249,7 @@ func TestElementUpdate(t *testing.T) {
 			Method: "PUT",
 			URL:    "/elements/" + e1.ID.String(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + t1.ID.String(),
+				"Authorization": "Bearer " + t1.Secret.String(),
 			},
 			Body: map[string]interface{}{
 				"name":       newName,
@@ -273,7 +274,7 @@ func TestElementUpdate(t *testing.T) {
 			Method: "PUT",
 			URL:    "/elements/" + e1.ID.String(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + t2.ID.String(),
+				"Authorization": "Bearer " + t2.Secret.String(),
 			},
 			Body: map[string]interface{}{},
 		})
@@ -292,7 +293,7 @@ func TestElementUpdate(t *testing.T) {
 			Method: "PUT",
 			URL:    "/elements/" + uuid.New(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + t1.ID.String(),
+				"Authorization": "Bearer " + t1.Secret.String(),
 			},
 			Body: map[string]interface{}{},
 		})
@@ -336,7 +337,7 @@ func TestElementDestroy(t *testing.T) {
 			Method: "DELETE",
 			URL:    "/elements/" + e1.ID.String(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + t1.ID.String(),
+				"Authorization": "Bearer " + t1.Secret.String(),
 			},
 		})
 
@@ -354,7 +355,7 @@ func TestElementDestroy(t *testing.T) {
 			Method: "DELETE",
 			URL:    "/elements/" + e1.ID.String(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + t2.ID.String(),
+				"Authorization": "Bearer " + t2.Secret.String(),
 			},
 		})
 
@@ -372,7 +373,7 @@ func TestElementDestroy(t *testing.T) {
 			Method: "DELETE",
 			URL:    "/elements/" + uuid.New(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + t1.ID.String(),
+				"Authorization": "Bearer " + t1.Secret.String(),
 			},
 		})
 
@@ -384,3 +385,269 @@ func TestElementDestroy(t *testing.T) {
 		})
 	})
 }
+
+func createTestChildElement(parent *model.Element, token *model.Token, data interface{}, body interface{}) *httptest.ResponseRecorder {
+	r := request(&requestOptions{
+		Method: "POST",
+		URL:    "/elements/" + parent.ID.String() + "/elements",
+		Body:   body,
+		Headers: map[string]string{
+			"Authorization": "Bearer " + token.Secret.String(),
+		},
+	})
+
+	if err := parseJSON(r.Body, data); err != nil {
+		log.Fatal(err)
+	}
+
+	return r
+}
+
+func TestElementCopyMove(t *testing.T) {
+	u1 := new(model.User)
+	createTestUser(u1, fixtureUsers[0])
+	defer u1.Delete()
+
+	u2 := new(model.User)
+	createTestUser(u2, fixtureUsers[1])
+	defer u2.Delete()
+
+	t1 := new(model.Token)
+	createTestToken(t1, fixtureUsers[0])
+	defer t1.Delete()
+
+	t2 := new(model.Token)
+	createTestToken(t2, fixtureUsers[1])
+	defer t2.Delete()
+
+	project := new(model.Project)
+	createTestProject(u1, t1, project, fixtureProjects[0])
+	defer project.Delete()
+
+	s1 := new(model.Element)
+	createTestElement(project, t1, s1, fixtureElements[0])
+
+	s2 := new(model.Element)
+	createTestElement(project, t1, s2, fixtureElements[0])
+
+	text := new(model.Element)
+	createTestChildElement(s1, t1, text, map[string]interface{}{
+		"name": "Text",
+		"type": types.ElementTypeText,
+	})
+
+	position := func(element *model.Element, action string, token *model.Token, data interface{}, body interface{}) int {
+		r := request(&requestOptions{
+			Method: "POST",
+			URL:    "/elements/" + element.ID.String() + "/" + action,
+			Body:   body,
+			Headers: map[string]string{
+				"Authorization": "Bearer " + token.Secret.String(),
+			},
+		})
+
+		parseJSON(r.Body, data)
+		return r.Code
+	}
+
+	Convey("Copy a screen with its children", t, func() {
+		e := new(model.Element)
+		code := position(s1, "copy", t1, e, map[string]interface{}{"index": 1})
+		defer e.Delete()
+
+		So(code, ShouldEqual, http.StatusCreated)
+		So(e.ID, ShouldNotResemble, s1.ID)
+		So(e.Index, ShouldEqual, 1)
+		So(e.Elements, ShouldHaveLength, 1)
+		So(e.Elements[0].ID, ShouldNotResemble, text.ID)
+		So(e.Elements[0].ElementID, ShouldResemble, e.ID)
+	})
+
+	Convey("Move an element to another parent", t, func() {
+		e := new(model.Element)
+		code := position(text, "move", t1, e, map[string]interface{}{"element_id": s2.ID.String()})
+
+		So(code, ShouldEqual, http.StatusOK)
+		So(e.ID, ShouldResemble, text.ID)
+		So(e.ElementID, ShouldResemble, s2.ID)
+	})
+
+	Convey("Move an element into itself", t, func() {
+		err := new(util.APIError)
+		code := position(s2, "move", t1, err, map[string]interface{}{"element_id": text.ID.String()})
+
+		So(code, ShouldEqual, http.StatusBadRequest)
+		So(err, ShouldResemble, &util.APIError{
+			Field:   "element_id",
+			Code:    util.ElementCircularError,
+			Message: "The element can't be moved into itself or its descendants.",
+		})
+	})
+
+	Convey("Write permission is required", t, func() {
+		err := new(util.APIError)
+		So(position(s1, "copy", t2, err, nil), ShouldEqual, http.StatusForbidden)
+		So(position(s1, "move", t2, err, nil), ShouldEqual, http.StatusForbidden)
+	})
+}
+
+func TestElementIfMatch(t *testing.T) {
+	user := new(model.User)
+	createTestUser(user, fixtureUsers[0])
+	defer user.Delete()
+
+	token := new(model.Token)
+	createTestToken(token, fixtureUsers[0])
+	defer token.Delete()
+
+	project := new(model.Project)
+	createTestProject(user, token, project, fixtureProjects[0])
+	defer project.Delete()
+
+	element := new(model.Element)
+	createTestElement(project, token, element, fixtureElements[0])
+
+	// Times in JSON are rounded to seconds
+	current, _ := model.GetElement(element.ID)
+	etag := common.ETag(current.UpdatedAt.Time)
+	elementURL := "/elements/" + element.ID.String()
+
+	send := func(method, ifMatch, contentType string, body interface{}) *httptest.ResponseRecorder {
+		return request(&requestOptions{
+			Method: method,
+			URL:    elementURL,
+			Body:   body,
+			Headers: map[string]string{
+				"Authorization": "Bearer " + token.Secret.String(),
+				"Content-Type":  contentType,
+				"If-Match":      ifMatch,
+			},
+		})
+	}
+
+	Convey("Update with the current ETag", t, func() {
+		r := send("PUT", etag, "application/json", map[string]interface{}{"name": "foo"})
+		So(r.Code, ShouldEqual, http.StatusOK)
+		So(r.Header().Get("ETag"), ShouldNotEqual, etag)
+	})
+
+	Convey("Stale ETag", t, func() {
+		err := new(util.APIError)
+		r := send("PUT", etag, "application/json", map[string]interface{}{"name": "bar"})
+		parseJSON(r.Body, err)
+
+		So(r.Code, ShouldEqual, http.StatusPreconditionFailed)
+		So(err.Code, ShouldEqual, util.PreconditionFailedError)
+
+		r = send("PATCH", etag, contentTypeMergePatch, map[string]interface{}{"attributes": map[string]interface{}{}})
+		So(r.Code, ShouldEqual, http.StatusPreconditionFailed)
+
+		r = send("DELETE", etag, "application/json", nil)
+		So(r.Code, ShouldEqual, http.StatusPreconditionFailed)
+
+		current, _ := model.GetElement(element.ID)
+		So(current.Name, ShouldEqual, "foo")
+	})
+
+	Convey("Delete with the current ETag", t, func() {
+		current, _ := model.GetElement(element.ID)
+		r := send("DELETE", common.ETag(current.UpdatedAt.Time), "application/json", nil)
+		So(r.Code, ShouldEqual, http.StatusNoContent)
+	})
+}
+
+func TestElementPatch(t *testing.T) {
+	u1 := new(model.User)
+	createTestUser(u1, fixtureUsers[0])
+	defer u1.Delete()
+
+	u2 := new(model.User)
+	createTestUser(u2, fixtureUsers[1])
+	defer u2.Delete()
+
+	t1 := new(model.Token)
+	createTestToken(t1, fixtureUsers[0])
+	defer t1.Delete()
+
+	t2 := new(model.Token)
+	createTestToken(t2, fixtureUsers[1])
+	defer t2.Delete()
+
+	project := new(model.Project)
+	createTestProject(u1, t1, project, fixtureProjects[0])
+	defer project.Delete()
+
+	element := new(model.Element)
+	createTestElement(project, t1, element, map[string]interface{}{
+		"name":       "Screen",
+		"type":       "screen",
+		"attributes": map[string]interface{}{"foo": "a", "bar": "b"},
+	})
+
+	patch := func(token *model.Token, contentType string, body interface{}, data interface{}) int {
+		r := request(&requestOptions{
+			Method: "PATCH",
+			URL:    "/elements/" + element.ID.String(),
+			Body:   body,
+			Headers: map[string]string{
+				"Authorization": "Bearer " + token.Secret.String(),
+				"Content-Type":  contentType,
+			},
+		})
+
+		parseJSON(r.Body, data)
+		return r.Code
+	}
+
+	Convey("JSON Patch", t, func() {
+		e := new(model.Element)
+		code := patch(t1, contentTypeJSONPatch, []map[string]interface{}{
+			{"op": "remove", "path": "/attributes/bar"},
+			{"op": "add", "path": "/styles/color", "value": "red"},
+		}, e)
+
+		So(code, ShouldEqual, http.StatusOK)
+		So(e.Attributes, ShouldResemble, types.JSONObject{"foo": "a"})
+		So(e.Styles, ShouldResemble, types.JSONObject{"color": "red"})
+	})
+
+	Convey("Merge patch", t, func() {
+		e := new(model.Element)
+		code := patch(t1, contentTypeMergePatch, map[string]interface{}{
+			"attributes": map[string]interface{}{"foo": nil, "baz": "c"},
+		}, e)
+
+		So(code, ShouldEqual, http.StatusOK)
+		So(e.Attributes, ShouldResemble, types.JSONObject{"baz": "c"})
+		So(e.Styles, ShouldResemble, types.JSONObject{"color": "red"})
+	})
+
+	Convey("Invalid path", t, func() {
+		err := new(util.APIError)
+		code := patch(t1, contentTypeJSONPatch, []map[string]interface{}{
+			{"op": "remove", "path": "/attributes/foo"},
+		}, err)
+
+		So(code, ShouldEqual, http.StatusBadRequest)
+		So(err, ShouldResemble, &util.APIError{
+			Field:   "/attributes/foo",
+			Code:    util.PatchError,
+			Message: "Path does not exist.",
+		})
+	})
+
+	Convey("Unsupported content type", t, func() {
+		err := new(util.APIError)
+		code := patch(t1, "text/plain", map[string]interface{}{}, err)
+
+		So(code, ShouldEqual, http.StatusUnsupportedMediaType)
+		So(err.Code, ShouldEqual, util.ContentTypeError)
+	})
+
+	Convey("Forbidden", t, func() {
+		err := new(util.APIError)
+		code := patch(t2, contentTypeMergePatch, map[string]interface{}{}, err)
+
+		So(code, ShouldEqual, http.StatusForbidden)
+	})
+}
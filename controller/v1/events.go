@@ -7,6 +7,7 @@ import (
 	"github.com/mholt/binding"
 	"github.com/tkusd/server/controller/common"
 	"github.com/tkusd/server/model"
+	"github.com/tkusd/server/model/types"
 )
 
 func EventList(c *gin.Context) error {
@@ -18,17 +19,35 @@ func EventList(c *gin.Context) error {
 
 	projectID := model.GetProjectIDForElement(*elementID)
 
-	if err := CheckProjectPermission(c, projectID, false); err != nil {
+	if err := CheckProjectPermission(c, projectID, types.PermissionRead); err != nil {
 		return err
 	}
 
-	list, err := model.GetEventList(*elementID)
+	fields, err := model.EventFields.ParseFields(c.Query("fields"))
 
 	if err != nil {
 		return err
 	}
 
-	return common.APIResponse(c, http.StatusOK, list)
+	order := c.Query("order")
+
+	if order == "" {
+		order = "created_at"
+	}
+
+	list, err := model.GetSortedEventList(*elementID, order)
+
+	if err != nil {
+		return err
+	}
+
+	result, err := selectFields(list, fields)
+
+	if err != nil {
+		return err
+	}
+
+	return common.APIResponse(c, http.StatusOK, result)
 }
 
 type eventForm struct {
@@ -43,7 +62,7 @@ func (form *eventForm) FieldMap() binding.FieldMap {
 	}
 }
 
-func saveEvent(form *eventForm, event *model.Event) error {
+func saveEvent(form *eventForm, event *model.Event, pre model.Precondition) error {
 	if form.Event != nil {
 		event.Event = *form.Event
 	}
@@ -52,7 +71,7 @@ func saveEvent(form *eventForm, event *model.Event) error {
 		event.Workspace = *form.Workspace
 	}
 
-	return event.Save()
+	return event.SaveIf(pre)
 }
 
 func EventCreate(c *gin.Context) error {
@@ -64,7 +83,7 @@ func EventCreate(c *gin.Context) error {
 
 	projectID := model.GetProjectIDForElement(*elementID)
 
-	if err := CheckProjectPermission(c, projectID, true); err != nil {
+	if err := CheckProjectPermission(c, projectID, types.PermissionWrite); err != nil {
 		return err
 	}
 
@@ -78,7 +97,7 @@ func EventCreate(c *gin.Context) error {
 		ElementID: *elementID,
 	}
 
-	if err := saveEvent(form, event); err != nil {
+	if err := saveEvent(form, event, nil); err != nil {
 		return err
 	}
 
@@ -94,10 +113,11 @@ func EventShow(c *gin.Context) error {
 
 	projectID := model.GetProjectIDForElement(event.ElementID)
 
-	if err := CheckProjectPermission(c, projectID, false); err != nil {
+	if err := CheckProjectPermission(c, projectID, types.PermissionRead); err != nil {
 		return err
 	}
 
+	common.SetETag(c, event.UpdatedAt.Time)
 	return common.APIResponse(c, http.StatusOK, event)
 }
 
@@ -116,14 +136,15 @@ func EventUpdate(c *gin.Context) error {
 
 	projectID := model.GetProjectIDForElement(event.ElementID)
 
-	if err := CheckProjectPermission(c, projectID, true); err != nil {
+	if err := CheckProjectPermission(c, projectID, types.PermissionWrite); err != nil {
 		return err
 	}
 
-	if err := saveEvent(form, event); err != nil {
+	if err := saveEvent(form, event, common.IfMatch(c)); err != nil {
 		return err
 	}
 
+	common.SetETag(c, event.UpdatedAt.Time)
 	return common.APIResponse(c, http.StatusOK, event)
 }
 
@@ -136,11 +157,11 @@ func EventDestroy(c *gin.Context) error {
 
 	projectID := model.GetProjectIDForElement(event.ElementID)
 
-	if err := CheckProjectPermission(c, projectID, true); err != nil {
+	if err := CheckProjectPermission(c, projectID, types.PermissionWrite); err != nil {
 		return err
 	}
 
-	if err := event.Delete(); err != nil {
+	if err := event.DeleteIf(common.IfMatch(c)); err != nil {
 		return err
 	}
 
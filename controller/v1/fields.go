@@ -0,0 +1,123 @@
+package v1
+
+import (
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tkusd/server/util"
+)
+
+// elementVirtualFields are the fields of elements which are not columns.
+var elementVirtualFields = []string{"elements", "events"}
+
+// hasInclude returns true if the name is in the comma-separated include
+// parameter.
+func hasInclude(c *gin.Context, name string) bool {
+	for _, s := range util.SplitAndTrim(c.Query("include"), ",") {
+		if s == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func toJSONValue(v interface{}) (interface{}, error) {
+	var result interface{}
+	data, err := json.Marshal(v)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// filterFields keeps only the fields in objects. Arrays and child elements are
+// filtered recursively.
+func filterFields(doc interface{}, fields []string) interface{} {
+	switch v := doc.(type) {
+	case []interface{}:
+		for i, item := range v {
+			v[i] = filterFields(item, fields)
+		}
+
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+
+		for _, field := range fields {
+			if val, ok := v[field]; ok {
+				if field == "elements" {
+					val = filterFields(val, fields)
+				}
+
+				result[field] = val
+			}
+		}
+
+		return result
+	}
+
+	return doc
+}
+
+// selectFields returns the object or array with only the fields. The value is
+// returned as it is if fields is empty.
+func selectFields(v interface{}, fields []string) (interface{}, error) {
+	if len(fields) == 0 {
+		return v, nil
+	}
+
+	doc, err := toJSONValue(v)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return filterFields(doc, fields), nil
+}
+
+// selectCollectionFields is like selectFields but only filters the data of the
+// collection.
+func selectCollectionFields(v interface{}, fields []string) (interface{}, error) {
+	if len(fields) == 0 {
+		return v, nil
+	}
+
+	doc, err := toJSONValue(v)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if obj, ok := doc.(map[string]interface{}); ok {
+		obj["data"] = filterFields(obj["data"], fields)
+	}
+
+	return doc, nil
+}
+
+// elementColumns returns the columns to select for the fields of elements.
+func elementColumns(fields []string) []string {
+	var columns []string
+
+	for _, field := range fields {
+		isVirtual := false
+
+		for _, virtual := range elementVirtualFields {
+			if field == virtual {
+				isVirtual = true
+			}
+		}
+
+		if !isVirtual {
+			columns = append(columns, field)
+		}
+	}
+
+	return columns
+}
@@ -0,0 +1,120 @@
+package v1
+
+import (
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tkusd/server/model"
+	"github.com/tkusd/server/model/types"
+	"github.com/tkusd/server/util"
+)
+
+func TestListFields(t *testing.T) {
+	user := new(model.User)
+	createTestUser(user, fixtureUsers[0])
+	defer user.Delete()
+
+	token := new(model.Token)
+	createTestToken(token, fixtureUsers[0])
+	defer token.Delete()
+
+	p1 := new(model.Project)
+	createTestProject(user, token, p1, fixtureProjects[0])
+	defer p1.Delete()
+
+	p2 := new(model.Project)
+	createTestProject(user, token, p2, fixtureProjects[1])
+	defer p2.Delete()
+
+	screen := new(model.Element)
+	createTestElement(p1, token, screen, fixtureElements[0])
+
+	child := new(model.Element)
+	createTestChildElement(screen, token, child, map[string]interface{}{
+		"name": "Label",
+		"type": types.ElementTypeText,
+	})
+
+	projectsURL := "/users/" + user.ID.String() + "/projects"
+	headers := map[string]string{
+		"Authorization": "Bearer " + token.Secret.String(),
+	}
+
+	Convey("Select fields of projects", t, func() {
+		var list struct {
+			Data []map[string]interface{} `json:"data"`
+		}
+
+		r := request(&requestOptions{
+			Method:  "GET",
+			URL:     projectsURL + "?fields=title&order=-title",
+			Headers: headers,
+		})
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		parseJSON(r.Body, &list)
+		So(list.Data, ShouldResemble, []map[string]interface{}{
+			{"id": p2.ID.String(), "title": p2.Title},
+			{"id": p1.ID.String(), "title": p1.Title},
+		})
+	})
+
+	Convey("Unknown field", t, func() {
+		err := new(util.APIError)
+		r := request(&requestOptions{
+			Method:  "GET",
+			URL:     projectsURL + "?fields=title,password",
+			Headers: headers,
+		})
+
+		So(r.Code, ShouldEqual, http.StatusBadRequest)
+		parseJSON(r.Body, err)
+		So(err, ShouldResemble, &util.APIError{
+			Field:   "fields",
+			Code:    util.ValueError,
+			Message: "Unknown field password.",
+		})
+	})
+
+	Convey("Unsortable field", t, func() {
+		err := new(util.APIError)
+		r := request(&requestOptions{
+			Method:  "GET",
+			URL:     projectsURL + "?order=description",
+			Headers: headers,
+		})
+
+		So(r.Code, ShouldEqual, http.StatusBadRequest)
+		parseJSON(r.Body, err)
+		So(err, ShouldResemble, &util.APIError{
+			Field:   "order",
+			Code:    util.ValueError,
+			Message: "Can't sort by description.",
+		})
+	})
+
+	Convey("Select fields of child elements", t, func() {
+		var list []map[string]interface{}
+		r := request(&requestOptions{
+			Method:  "GET",
+			URL:     "/projects/" + p1.ID.String() + "/elements?fields=name,elements",
+			Headers: headers,
+		})
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		parseJSON(r.Body, &list)
+		So(list, ShouldResemble, []map[string]interface{}{
+			{
+				"id":   screen.ID.String(),
+				"name": screen.Name,
+				"elements": []interface{}{
+					map[string]interface{}{
+						"id":   child.ID.String(),
+						"name": child.Name,
+					},
+				},
+			},
+		})
+	})
+}
@@ -15,28 +15,45 @@ const (
 	eventIDParam         = "event_id"
 	activationIDParam    = "activation_id"
 	passwordResetIDParam = "password_reset_id"
+	collaboratorIDParam  = "collaborator_id"
+	revisionIDParam      = "revision_id"
 )
 
 // URL patterns
 const (
 	userCollectionURL = "/users"
 	userSingularURL   = "/users/:" + userIDParam
+	userTokenURL      = userSingularURL + "/tokens"
+	personalTokenURL  = userSingularURL + "/personal_tokens"
 
 	projectCollectionURL = userSingularURL + "/projects"
+	projectSearchURL     = "/projects"
 	projectSingularURL   = "/projects/:" + projectIDParam
 	projectFullURL       = projectSingularURL + "/full"
+	projectExportURL     = projectSingularURL + "/export"
+	projectImportURL     = projectCollectionURL + "/import"
+	projectForkURL       = projectSingularURL + "/fork"
+	projectStreamURL     = projectSingularURL + "/stream"
+	projectBatchURL      = projectSingularURL + "/batch"
+	projectStatsURL      = projectSingularURL + "/stats"
 
 	elementCollectionURL      = projectSingularURL + "/elements"
 	elementSingularURL        = "/elements/:" + elementIDParam
 	elementFullURL            = elementSingularURL + "/full"
 	childElementCollectionURL = elementSingularURL + "/elements"
+	elementCopyURL            = elementSingularURL + "/copy"
+	elementMoveURL            = elementSingularURL + "/move"
+
+	elementTypeCollectionURL = "/element_types"
 
 	tokenCollectionURL = "/tokens"
 	tokenSingularURL   = "/tokens/:" + tokenIDParam
+	tokenRefreshURL    = "/tokens/refresh"
 
 	assetCollectionURL = projectSingularURL + "/assets"
 	assetSingularURL   = "/assets/:" + assetIDParam
 	assetBlobURL       = assetSingularURL + "/blob"
+	assetUsageURL      = assetSingularURL + "/usages"
 	assetArchiveURL    = projectSingularURL + "/assets/archive"
 
 	eventCollectionURL = elementSingularURL + "/events"
@@ -46,33 +63,65 @@ const (
 	passwordResetSingularURL = passwordResetURL + "/:" + passwordResetIDParam
 
 	activationSingularURL = "/activation/:" + activationIDParam
+
+	collaboratorCollectionURL = projectSingularURL + "/collaborators"
+	collaboratorSingularURL   = "/collaborators/:" + collaboratorIDParam
+
+	revisionCollectionURL = projectSingularURL + "/revisions"
+	revisionSingularURL   = "/revisions/:" + revisionIDParam
+	revisionRestoreURL    = revisionSingularURL + "/restore"
+)
+
+// Rate limit groups
+const (
+	apiRateLimitGroup  = "api"
+	authRateLimitGroup = "auth"
 )
 
 // Router returns a http.Handler.
 func Router(r *gin.RouterGroup) {
+	r.Use(common.RateLimit(apiRateLimitGroup))
+
 	r.POST(userCollectionURL, common.Wrap(UserCreate))
 	r.GET(userSingularURL, common.Wrap(UserShow))
 	r.PUT(userSingularURL, common.Wrap(UserUpdate))
 	r.DELETE(userSingularURL, common.Wrap(UserDestroy))
+	r.GET(userTokenURL, common.Wrap(UserTokenList))
+	r.DELETE(userTokenURL, common.Wrap(UserTokenDestroy))
+	r.GET(personalTokenURL, common.Wrap(PersonalTokenList))
+	r.POST(personalTokenURL, common.Wrap(PersonalTokenCreate))
 
 	r.GET(projectCollectionURL, CheckUserExist, common.Wrap(ProjectList))
 	r.POST(projectCollectionURL, CheckUserExist, common.Wrap(ProjectCreate))
+	r.GET(projectSearchURL, common.Wrap(ProjectSearch))
 	r.GET(projectSingularURL, common.Wrap(ProjectShow))
 	r.PUT(projectSingularURL, common.Wrap(ProjectUpdate))
 	r.DELETE(projectSingularURL, common.Wrap(ProjectDestroy))
 	r.GET(projectFullURL, common.Wrap(ProjectFull))
+	r.GET(projectExportURL, common.Wrap(ProjectExport))
+	r.POST(projectImportURL, CheckUserExist, common.Wrap(ProjectImport))
+	r.POST(projectForkURL, common.Wrap(ProjectFork))
+	r.GET(projectStreamURL, CheckProjectExist, common.Wrap(ProjectStream))
+	r.POST(projectBatchURL, common.Wrap(ProjectBatch))
+	r.GET(projectStatsURL, common.Wrap(ProjectStats))
 
 	r.GET(elementCollectionURL, CheckProjectExist, common.Wrap(ElementList))
 	r.POST(elementCollectionURL, common.Wrap(ElementCreate))
 	r.GET(elementSingularURL, common.Wrap(ElementShow))
 	r.PUT(elementSingularURL, common.Wrap(ElementUpdate))
+	r.PATCH(elementSingularURL, common.Wrap(ElementPatch))
 	r.DELETE(elementSingularURL, common.Wrap(ElementDestroy))
 	r.GET(elementFullURL, common.Wrap(ElementFull))
+	r.POST(elementCopyURL, common.Wrap(ElementCopy))
+	r.POST(elementMoveURL, common.Wrap(ElementMove))
 
 	r.GET(childElementCollectionURL, common.Wrap(ChildElementList))
 	r.POST(childElementCollectionURL, common.Wrap(ChildElementCreate))
 
-	r.POST(tokenCollectionURL, common.Wrap(TokenCreate))
+	r.GET(elementTypeCollectionURL, common.Wrap(ElementTypeList))
+
+	r.POST(tokenCollectionURL, common.RateLimit(authRateLimitGroup), common.Wrap(TokenCreate))
+	r.POST(tokenRefreshURL, common.RateLimit(authRateLimitGroup), common.Wrap(TokenRefresh))
 	r.GET(tokenSingularURL, common.Wrap(TokenShow))
 	r.DELETE(tokenSingularURL, common.Wrap(TokenDestroy))
 
@@ -82,7 +131,9 @@ func Router(r *gin.RouterGroup) {
 	r.PUT(assetSingularURL, common.Wrap(AssetUpdate))
 	r.DELETE(assetSingularURL, common.Wrap(AssetDestroy))
 	r.GET(assetBlobURL, common.Wrap(AssetBlob))
+	r.GET(assetUsageURL, common.Wrap(AssetUsages))
 	r.GET(assetArchiveURL, CheckProjectExist, common.Wrap(AssetArchive))
+	r.POST(assetArchiveURL, CheckProjectExist, common.Wrap(AssetArchiveImport))
 
 	r.GET(eventCollectionURL, CheckElementExist, common.Wrap(EventList))
 	r.POST(eventCollectionURL, CheckElementExist, common.Wrap(EventCreate))
@@ -90,8 +141,18 @@ func Router(r *gin.RouterGroup) {
 	r.PUT(eventSingularURL, common.Wrap(EventUpdate))
 	r.DELETE(eventSingularURL, common.Wrap(EventDestroy))
 
-	r.POST(passwordResetURL, common.Wrap(PasswordResetCreate))
-	r.POST(passwordResetSingularURL, common.Wrap(PasswordResetUpdate))
+	r.POST(passwordResetURL, common.RateLimit(authRateLimitGroup), common.Wrap(PasswordResetCreate))
+	r.POST(passwordResetSingularURL, common.RateLimit(authRateLimitGroup), common.Wrap(PasswordResetUpdate))
 
 	r.POST(activationSingularURL, common.Wrap(ActivateUser))
+
+	r.GET(collaboratorCollectionURL, CheckProjectExist, common.Wrap(CollaboratorList))
+	r.POST(collaboratorCollectionURL, common.Wrap(CollaboratorCreate))
+	r.GET(collaboratorSingularURL, common.Wrap(CollaboratorShow))
+	r.PUT(collaboratorSingularURL, common.Wrap(CollaboratorUpdate))
+	r.DELETE(collaboratorSingularURL, common.Wrap(CollaboratorDestroy))
+
+	r.GET(revisionCollectionURL, CheckProjectExist, common.Wrap(RevisionList))
+	r.GET(revisionSingularURL, common.Wrap(RevisionShow))
+	r.POST(revisionRestoreURL, common.Wrap(RevisionRestore))
 }
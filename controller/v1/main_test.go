@@ -3,6 +3,9 @@ package v1
 import (
 	"io"
 
+	"github.com/gin-gonic/gin"
+	"github.com/tkusd/server/config"
+	"github.com/tkusd/server/controller/common"
 	"github.com/tkusd/server/model/types"
 
 	"bytes"
@@ -40,9 +43,9 @@ var fixtureProjects = []struct {
 }
 
 var fixtureElements = []struct {
-	Name       string            `json:"name"`
-	Type       types.ElementType `json:"type"`
-	Attributes types.JSONObject  `json:"attributes"`
+	Name       string           `json:"name"`
+	Type       string           `json:"type"`
+	Attributes types.JSONObject `json:"attributes"`
 }{
 	{
 		Name: "Text",
@@ -54,7 +57,13 @@ var fixtureElements = []struct {
 }
 
 func init() {
-	router = Router()
+	// Rate limits are tested separately
+	config.Config.RateLimit.Groups = nil
+
+	g := gin.New()
+	Router(g.Group(""))
+	g.NoRoute(common.NotFound)
+	router = g
 }
 
 func request(options *requestOptions) *httptest.ResponseRecorder {
@@ -54,8 +54,39 @@ func GetToken(c *gin.Context) (*model.Token, error) {
 	}
 }
 
+// acceptTokenQuery uses the token in the query string if the Authorization
+// header is not set. It's only used on routes for clients which can't set
+// headers, since URLs are more likely to be leaked than headers.
+func acceptTokenQuery(c *gin.Context) {
+	if c.Request.Header.Get("Authorization") != "" {
+		return
+	}
+
+	if key := c.Query(common.TokenQueryParam); key != "" {
+		c.Request.Header.Set("Authorization", "Bearer "+key)
+	}
+}
+
 // CheckToken checks the Authorization header and gets the token from the database.
+// Expired tokens are rejected.
 func CheckToken(c *gin.Context) (*model.Token, error) {
+	token, err := findToken(c)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The session slides when the token is used
+	if err := token.Touch(); err != nil {
+		util.Log().Errorf("Failed to update the token %s: %v", token.ID.String(), err)
+	}
+
+	return token, nil
+}
+
+// findToken is like CheckToken but the token isn't touched, so checking it
+// again doesn't extend the session.
+func findToken(c *gin.Context) (*model.Token, error) {
 	authHeader := c.Request.Header.Get("Authorization")
 
 	if authHeader == "" || !rToken.MatchString(authHeader) {
@@ -77,6 +108,14 @@ func CheckToken(c *gin.Context) (*model.Token, error) {
 		}
 	}
 
+	if token.IsExpired() {
+		return nil, &util.APIError{
+			Code:    util.TokenExpiredError,
+			Message: "Token has expired.",
+			Status:  http.StatusUnauthorized,
+		}
+	}
+
 	return token, nil
 }
 
@@ -99,7 +138,16 @@ func GetUser(c *gin.Context) (*model.User, error) {
 	}
 }
 
+func tokenScopeError() error {
+	return &util.APIError{
+		Code:    util.TokenScopeError,
+		Message: "The token does not have the required scope.",
+		Status:  http.StatusForbidden,
+	}
+}
+
 // CheckUserPermission checks whether the current token matching user ID.
+// Only tokens with the "all" scope can access the account.
 func CheckUserPermission(c *gin.Context, userID types.UUID) error {
 	token, err := CheckToken(c)
 
@@ -107,15 +155,19 @@ func CheckUserPermission(c *gin.Context, userID types.UUID) error {
 		return err
 	}
 
-	if token.UserID.Equal(userID) {
-		return nil
+	if !token.UserID.Equal(userID) {
+		return &util.APIError{
+			Code:    util.UserForbiddenError,
+			Message: "You are forbidden to access.",
+			Status:  http.StatusForbidden,
+		}
 	}
 
-	return &util.APIError{
-		Code:    util.UserForbiddenError,
-		Message: "You are forbidden to access.",
-		Status:  http.StatusForbidden,
+	if !token.AllowsUser() {
+		return tokenScopeError()
 	}
+
+	return nil
 }
 
 // CheckUserExist checks whether the user exists or not.
@@ -202,11 +254,27 @@ func GetElement(c *gin.Context) (*model.Element, error) {
 	}
 }
 
-// CheckProjectPermission checks whether the current user is able to edit the project.
-func CheckProjectPermission(c *gin.Context, projectID types.UUID, strict bool) error {
+// CheckProjectPermission checks whether the current user has the permission on the project.
+// Public projects can be read without a token.
+func CheckProjectPermission(c *gin.Context, projectID types.UUID, perm types.Permission) error {
+	return checkProjectPermission(c, projectID, perm, false)
+}
+
+// CheckAssetPermission is the same as CheckProjectPermission, but it's used
+// for assets, so tokens with the "assets" scope are allowed.
+func CheckAssetPermission(c *gin.Context, projectID types.UUID, perm types.Permission) error {
+	return checkProjectPermission(c, projectID, perm, true)
+}
+
+func checkProjectPermission(c *gin.Context, projectID types.UUID, perm types.Permission, assets bool) error {
 	token, err := CheckToken(c)
+	return checkTokenProjectPermission(token, err, projectID, perm, assets)
+}
 
-	if strict && err != nil {
+// checkTokenProjectPermission checks the permission of the token on the
+// project. err is the error returned when the token is checked.
+func checkTokenProjectPermission(token *model.Token, err error, projectID types.UUID, perm types.Permission, assets bool) error {
+	if perm != types.PermissionRead && err != nil {
 		return err
 	}
 
@@ -216,7 +284,15 @@ func CheckProjectPermission(c *gin.Context, projectID types.UUID, strict bool) e
 		return nil
 	}
 
-	if (token != nil && project.UserID.Equal(token.UserID)) || (!strict && !project.IsPrivate) {
+	if perm == types.PermissionRead && !project.IsPrivate {
+		return nil
+	}
+
+	if token != nil && model.GetProjectPermission(project, token.UserID).Has(perm) {
+		if !token.AllowsProject(project.ID, perm, assets) {
+			return tokenScopeError()
+		}
+
 		return nil
 	}
 
@@ -306,3 +382,40 @@ func GetEvent(c *gin.Context) (*model.Event, error) {
 		Status:  http.StatusNotFound,
 	}
 }
+
+func GetCollaborator(c *gin.Context) (*model.Collaborator, error) {
+	id, err := GetIDParam(c, collaboratorIDParam)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if collaborator, err := model.GetCollaborator(*id); err == nil {
+		return collaborator, nil
+	}
+
+	return nil, &util.APIError{
+		Code:    util.CollaboratorNotFound,
+		Message: "Collaborator not found.",
+		Status:  http.StatusNotFound,
+	}
+}
+
+// GetRevision parses revision_id in the URL and gets the revision data from the database.
+func GetRevision(c *gin.Context) (*model.Revision, error) {
+	id, err := GetIDParam(c, revisionIDParam)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if revision, err := model.GetRevision(*id); err == nil {
+		return revision, nil
+	}
+
+	return nil, &util.APIError{
+		Code:    util.RevisionNotFound,
+		Message: "Revision not found.",
+		Status:  http.StatusNotFound,
+	}
+}
@@ -8,7 +8,7 @@ import (
 	"encoding/base64"
 
 	"code.google.com/p/go-uuid/uuid"
-	"github.com/julienschmidt/httprouter"
+	"github.com/gin-gonic/gin"
 	. "github.com/smartystreets/goconvey/convey"
 	"github.com/tkusd/server/controller/common"
 	"github.com/tkusd/server/model"
@@ -16,6 +16,22 @@ import (
 	"github.com/tkusd/server/util"
 )
 
+// serveContext serves the request with a router which runs the handlers on
+// the path.
+func serveContext(path string, req *http.Request, handlers ...gin.HandlerFunc) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.GET(path, handlers...)
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	return res
+}
+
+func noContent(c *gin.Context) {
+	c.Writer.WriteHeader(http.StatusNoContent)
+}
+
 func TestCheckToken(t *testing.T) {
 	Convey("Success", t, func() {
 		user := new(model.User)
@@ -26,22 +42,26 @@ func TestCheckToken(t *testing.T) {
 		createTestToken(token, fixtureUsers[0])
 		defer token.Delete()
 
-		res := httptest.NewRecorder()
+		var t *model.Token
 		req, _ := http.NewRequest("GET", "/", nil)
-		req.Header.Set("Authorization", "Bearer "+token.ID.String())
+		req.Header.Set("Authorization", "Bearer "+token.Secret.String())
 
-		t, _ := CheckToken(res, req)
+		serveContext("/", req, func(c *gin.Context) {
+			t, _ = CheckToken(c)
+		})
 		So(t.ID, ShouldResemble, token.ID)
 		So(t.UserID, ShouldResemble, token.UserID)
 	})
 
 	Convey("Token not found", t, func() {
-		res := httptest.NewRecorder()
+		var err error
 		req, _ := http.NewRequest("GET", "/", nil)
 		fakeKey := base64.StdEncoding.EncodeToString(uuid.NewRandom())
 		req.Header.Set("Authorization", "Bearer "+fakeKey)
 
-		_, err := CheckToken(res, req)
+		serveContext("/", req, func(c *gin.Context) {
+			_, err = CheckToken(c)
+		})
 		So(err, ShouldResemble, &util.APIError{
 			Code:    util.TokenInvalidError,
 			Message: "Token is invalid.",
@@ -50,11 +70,13 @@ func TestCheckToken(t *testing.T) {
 	})
 
 	Convey("Wrong header format", t, func() {
-		res := httptest.NewRecorder()
+		var err error
 		req, _ := http.NewRequest("GET", "/", nil)
 		req.Header.Set("Authorization", uuid.New())
 
-		_, err := CheckToken(res, req)
+		serveContext("/", req, func(c *gin.Context) {
+			_, err = CheckToken(c)
+		})
 		So(err, ShouldResemble, &util.APIError{
 			Code:    util.TokenRequiredError,
 			Message: "Token is required.",
@@ -63,10 +85,12 @@ func TestCheckToken(t *testing.T) {
 	})
 
 	Convey("Authorization header does not exist", t, func() {
-		res := httptest.NewRecorder()
+		var err error
 		req, _ := http.NewRequest("GET", "/", nil)
 
-		_, err := CheckToken(res, req)
+		serveContext("/", req, func(c *gin.Context) {
+			_, err = CheckToken(c)
+		})
 		So(err, ShouldResemble, &util.APIError{
 			Code:    util.TokenRequiredError,
 			Message: "Token is required.",
@@ -80,14 +104,16 @@ func TestGetUser(t *testing.T) {
 	createTestUser(user, fixtureUsers[0])
 	defer user.Delete()
 
-	router := httprouter.New()
+	router := gin.New()
+
+	router.GET(userSingularURL, common.Wrap(func(c *gin.Context) error {
+		user, err := GetUser(c)
 
-	router.GET(userSingularURL, common.WrapHandlerFunc(func(res http.ResponseWriter, req *http.Request) {
-		if user, err := GetUser(res, req); err != nil {
-			common.HandleAPIError(res, req, err)
-		} else {
-			common.APIResponse(res, req, http.StatusOK, user)
+		if err != nil {
+			return err
 		}
+
+		return common.APIResponse(c, http.StatusOK, user)
 	}))
 
 	Convey("Success", t, func() {
@@ -128,19 +154,23 @@ func TestCheckUserPermission(t *testing.T) {
 	defer token.Delete()
 
 	Convey("Success", t, func() {
-		res := httptest.NewRecorder()
+		var err error
 		req, _ := http.NewRequest("GET", "/", nil)
-		req.Header.Set("Authorization", "Bearer "+token.ID.String())
+		req.Header.Set("Authorization", "Bearer "+token.Secret.String())
 
-		err := CheckUserPermission(res, req, user.ID)
+		serveContext("/", req, func(c *gin.Context) {
+			err = CheckUserPermission(c, user.ID)
+		})
 		So(err, ShouldBeNil)
 	})
 
 	Convey("Token not found", t, func() {
-		res := httptest.NewRecorder()
+		var err error
 		req, _ := http.NewRequest("GET", "/", nil)
 
-		err := CheckUserPermission(res, req, user.ID)
+		serveContext("/", req, func(c *gin.Context) {
+			err = CheckUserPermission(c, user.ID)
+		})
 		So(err, ShouldResemble, &util.APIError{
 			Code:    util.TokenRequiredError,
 			Message: "Token is required.",
@@ -149,11 +179,13 @@ func TestCheckUserPermission(t *testing.T) {
 	})
 
 	Convey("User ID does not match", t, func() {
-		res := httptest.NewRecorder()
+		var err error
 		req, _ := http.NewRequest("GET", "/", nil)
-		req.Header.Set("Authorization", "Bearer "+token.ID.String())
+		req.Header.Set("Authorization", "Bearer "+token.Secret.String())
 
-		err := CheckUserPermission(res, req, types.NewRandomUUID())
+		serveContext("/", req, func(c *gin.Context) {
+			err = CheckUserPermission(c, types.NewRandomUUID())
+		})
 		So(err, ShouldResemble, &util.APIError{
 			Code:    util.UserForbiddenError,
 			Message: "You are forbidden to access.",
@@ -167,10 +199,8 @@ func TestCheckUserExist(t *testing.T) {
 	createTestUser(user, fixtureUsers[0])
 	defer user.Delete()
 
-	router := httprouter.New()
-	router.GET(userSingularURL, common.ChainHandler(CheckUserExist, func(res http.ResponseWriter, req *http.Request) {
-		res.WriteHeader(http.StatusNoContent)
-	}))
+	router := gin.New()
+	router.GET(userSingularURL, CheckUserExist, noContent)
 
 	Convey("Success", t, func() {
 		res := httptest.NewRecorder()
@@ -210,13 +240,16 @@ func TestGetProject(t *testing.T) {
 	createTestProject(user, token, project, fixtureProjects[0])
 	defer project.Delete()
 
-	router := httprouter.New()
-	router.GET(projectSingularURL, common.WrapHandlerFunc(func(res http.ResponseWriter, req *http.Request) {
-		if project, err := GetProject(res, req); err != nil {
-			common.HandleAPIError(res, req, err)
-		} else {
-			common.APIResponse(res, req, http.StatusOK, project)
+	router := gin.New()
+
+	router.GET(projectSingularURL, common.Wrap(func(c *gin.Context) error {
+		project, err := GetProject(c)
+
+		if err != nil {
+			return err
 		}
+
+		return common.APIResponse(c, http.StatusOK, project)
 	}))
 
 	Convey("Success", t, func() {
@@ -260,10 +293,8 @@ func TestCheckProjectExist(t *testing.T) {
 	createTestProject(user, token, project, fixtureProjects[0])
 	defer project.Delete()
 
-	router := httprouter.New()
-	router.GET(projectSingularURL, common.ChainHandler(CheckProjectExist, func(res http.ResponseWriter, req *http.Request) {
-		res.WriteHeader(http.StatusNoContent)
-	}))
+	router := gin.New()
+	router.GET(projectSingularURL, CheckProjectExist, noContent)
 
 	Convey("Success", t, func() {
 		res := httptest.NewRecorder()
@@ -307,13 +338,16 @@ func TestGetElement(t *testing.T) {
 	createTestElement(project, token, element, fixtureElements[0])
 	defer element.Delete()
 
-	router := httprouter.New()
-	router.GET(elementSingularURL, common.WrapHandlerFunc(func(res http.ResponseWriter, req *http.Request) {
-		if element, err := GetElement(res, req); err != nil {
-			common.HandleAPIError(res, req, err)
-		} else {
-			common.APIResponse(res, req, http.StatusOK, element)
+	router := gin.New()
+
+	router.GET(elementSingularURL, common.Wrap(func(c *gin.Context) error {
+		element, err := GetElement(c)
+
+		if err != nil {
+			return err
 		}
+
+		return common.APIResponse(c, http.StatusOK, element)
 	}))
 
 	Convey("Success", t, func() {
@@ -370,29 +404,35 @@ func TestCheckProjectPermission(t *testing.T) {
 	defer p2.Delete()
 
 	Convey("Owner", t, func() {
-		res := httptest.NewRecorder()
+		var err error
 		req, _ := http.NewRequest("GET", "/", nil)
-		req.Header.Set("Authorization", "Bearer "+t1.ID.String())
+		req.Header.Set("Authorization", "Bearer "+t1.Secret.String())
 
-		err := CheckProjectPermission(res, req, p1.ID, false)
+		serveContext("/", req, func(c *gin.Context) {
+			err = CheckProjectPermission(c, p1.ID, types.PermissionRead)
+		})
 		So(err, ShouldBeNil)
 	})
 
 	Convey("Others + Public", t, func() {
-		res := httptest.NewRecorder()
+		var err error
 		req, _ := http.NewRequest("GET", "/", nil)
-		req.Header.Set("Authorization", "Bearer "+t2.ID.String())
+		req.Header.Set("Authorization", "Bearer "+t2.Secret.String())
 
-		err := CheckProjectPermission(res, req, p1.ID, false)
+		serveContext("/", req, func(c *gin.Context) {
+			err = CheckProjectPermission(c, p1.ID, types.PermissionRead)
+		})
 		So(err, ShouldBeNil)
 	})
 
 	Convey("Others + Public + Strict", t, func() {
-		res := httptest.NewRecorder()
+		var err error
 		req, _ := http.NewRequest("GET", "/", nil)
-		req.Header.Set("Authorization", "Bearer "+t2.ID.String())
+		req.Header.Set("Authorization", "Bearer "+t2.Secret.String())
 
-		err := CheckProjectPermission(res, req, p1.ID, true)
+		serveContext("/", req, func(c *gin.Context) {
+			err = CheckProjectPermission(c, p1.ID, types.PermissionWrite)
+		})
 		So(err, ShouldResemble, &util.APIError{
 			Code:    util.UserForbiddenError,
 			Message: "You are forbidden to access.",
@@ -401,11 +441,13 @@ func TestCheckProjectPermission(t *testing.T) {
 	})
 
 	Convey("Others + Private", t, func() {
-		res := httptest.NewRecorder()
+		var err error
 		req, _ := http.NewRequest("GET", "/", nil)
-		req.Header.Set("Authorization", "Bearer "+t1.ID.String())
+		req.Header.Set("Authorization", "Bearer "+t1.Secret.String())
 
-		err := CheckProjectPermission(res, req, p2.ID, false)
+		serveContext("/", req, func(c *gin.Context) {
+			err = CheckProjectPermission(c, p2.ID, types.PermissionRead)
+		})
 		So(err, ShouldResemble, &util.APIError{
 			Code:    util.UserForbiddenError,
 			Message: "You are forbidden to access.",
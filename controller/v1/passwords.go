@@ -2,6 +2,7 @@ package v1
 
 import (
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -37,6 +38,10 @@ func PasswordResetCreate(c *gin.Context) error {
 		}
 	}
 
+	if err := common.CheckAccountRateLimit(c, authRateLimitGroup, strings.ToLower(form.Email)); err != nil {
+		return err
+	}
+
 	user, err := model.GetUserByEmail(form.Email)
 
 	if err != nil {
@@ -119,6 +124,15 @@ func PasswordResetUpdate(c *gin.Context) error {
 		return err
 	}
 
+	// The account is unlocked after the password is reset
+	if err := user.Unlock(); err != nil {
+		return err
+	}
+
+	if err := revokeOtherTokens(c, user.ID); err != nil {
+		return err
+	}
+
 	c.Writer.WriteHeader(http.StatusNoContent)
 	return nil
 }
@@ -3,6 +3,7 @@ package v1
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mholt/binding"
@@ -12,6 +13,24 @@ import (
 	"github.com/tkusd/server/util"
 )
 
+// bindQueryOption parses limit, offset, order and cursor in the query string.
+func bindQueryOption(c *gin.Context, option *model.QueryOption) {
+	if limit := c.Query("limit"); limit != "" {
+		if i, err := strconv.Atoi(limit); err == nil {
+			option.Limit = i
+		}
+	}
+
+	if offset := c.Query("offset"); offset != "" {
+		if i, err := strconv.Atoi(offset); err == nil {
+			option.Offset = i
+		}
+	}
+
+	option.Order = c.Query("order")
+	option.Cursor = c.Query("cursor")
+}
+
 // ProjectList handles GET /users/:user_id/projects.
 func ProjectList(c *gin.Context) error {
 	userID, err := GetIDParam(c, userIDParam)
@@ -24,26 +43,66 @@ func ProjectList(c *gin.Context) error {
 		UserID: userID,
 	}
 
-	if limit := c.Query("limit"); limit != "" {
-		if i, err := strconv.Atoi(limit); err == nil {
-			option.Limit = i
-		}
+	bindQueryOption(c, &option.QueryOption)
+
+	if option.Order == "" {
+		option.Order = "-created_at"
 	}
 
-	if offset := c.Query("offset"); offset != "" {
-		if i, err := strconv.Atoi(offset); err == nil {
-			option.Offset = i
+	// Private projects are included if the token can read all projects
+	if token, err := CheckToken(c); err == nil && token.UserID.Equal(*userID) && token.AllowsProject(types.UUID{}, types.PermissionRead, false) {
+		option.Private = true
+	}
+
+	fields, err := model.ProjectFields.ParseFields(c.Query("fields"))
+
+	if err != nil {
+		return err
+	}
+
+	list, err := model.GetProjectList(option)
+
+	if err != nil {
+		return err
+	}
+
+	if hasInclude(c, "stats") {
+		if err := model.LoadProjectStats(list.Data); err != nil {
+			return err
 		}
 	}
 
-	if order := c.Query("order"); order != "" {
-		option.Order = order
-	} else {
-		option.Order = "-created_at"
+	list.Next = common.PageURL(c, list.NextCursor)
+	list.Prev = common.PageURL(c, list.PrevCursor)
+
+	result, err := selectCollectionFields(list, fields)
+
+	if err != nil {
+		return err
 	}
 
-	if err := CheckUserPermission(c, *userID); err == nil {
-		option.Private = true
+	return common.APIResponse(c, http.StatusOK, result)
+}
+
+// ProjectSearch handles GET /projects.
+func ProjectSearch(c *gin.Context) error {
+	option := &model.ProjectQueryOption{
+		Query: strings.TrimSpace(c.Query("q")),
+		Theme: c.Query("theme"),
+	}
+
+	bindQueryOption(c, &option.QueryOption)
+
+	// Private projects of the current user are included if the token can read
+	// all projects
+	if token, err := CheckToken(c); err == nil && token.AllowsProject(types.UUID{}, types.PermissionRead, false) {
+		option.ViewerID = &token.UserID
+	}
+
+	fields, err := model.ProjectFields.ParseFields(c.Query("fields"))
+
+	if err != nil {
+		return err
 	}
 
 	list, err := model.GetProjectList(option)
@@ -52,7 +111,22 @@ func ProjectList(c *gin.Context) error {
 		return err
 	}
 
-	return common.APIResponse(c, http.StatusOK, list)
+	if hasInclude(c, "stats") {
+		if err := model.LoadProjectStats(list.Data); err != nil {
+			return err
+		}
+	}
+
+	list.Next = common.PageURL(c, list.NextCursor)
+	list.Prev = common.PageURL(c, list.PrevCursor)
+
+	result, err := selectCollectionFields(list, fields)
+
+	if err != nil {
+		return err
+	}
+
+	return common.APIResponse(c, http.StatusOK, result)
 }
 
 type projectForm struct {
@@ -75,7 +149,7 @@ func (form *projectForm) FieldMap() binding.FieldMap {
 	}
 }
 
-func saveProject(form *projectForm, project *model.Project) error {
+func saveProject(form *projectForm, project *model.Project, pre model.Precondition) error {
 	if form.Title != nil {
 		project.Title = *form.Title
 	}
@@ -92,7 +166,7 @@ func saveProject(form *projectForm, project *model.Project) error {
 		project.Theme = *form.Theme
 	}
 
-	return project.Save()
+	return project.SaveIf(pre)
 }
 
 // ProjectCreate handles POST /users/:user_id/projects.
@@ -115,7 +189,7 @@ func ProjectCreate(c *gin.Context) error {
 
 	project := &model.Project{UserID: *userID}
 
-	if err := saveProject(form, project); err != nil {
+	if err := saveProject(form, project, nil); err != nil {
 		return err
 	}
 
@@ -143,14 +217,8 @@ func getProjectWithOwner(c *gin.Context) (*model.Project, error) {
 		}
 	}
 
-	token, _ := CheckToken(c)
-
-	if project.IsPrivate && !project.UserID.Equal(token.UserID) {
-		return nil, &util.APIError{
-			Code:    util.UserForbiddenError,
-			Message: "You are forbidden to access this project.",
-			Status:  http.StatusForbidden,
-		}
+	if err := CheckProjectPermission(c, project.ID, types.PermissionRead); err != nil {
+		return nil, err
 	}
 
 	return project, nil
@@ -164,9 +232,37 @@ func ProjectShow(c *gin.Context) error {
 		return err
 	}
 
+	if hasInclude(c, "stats") {
+		if err := model.LoadProjectStats([]*model.Project{project}); err != nil {
+			return err
+		}
+	}
+
+	common.SetETag(c, project.UpdatedAt.Time)
 	return common.APIResponse(c, http.StatusOK, project)
 }
 
+// ProjectStats handles GET /projects/:project_id/stats.
+func ProjectStats(c *gin.Context) error {
+	project, err := GetProject(c)
+
+	if err != nil {
+		return err
+	}
+
+	if err := CheckProjectPermission(c, project.ID, types.PermissionRead); err != nil {
+		return err
+	}
+
+	stats, err := model.GetProjectDetailStats(project.ID)
+
+	if err != nil {
+		return err
+	}
+
+	return common.APIResponse(c, http.StatusOK, stats)
+}
+
 // ProjectUpdate handles PUT /projects/:project_id.
 func ProjectUpdate(c *gin.Context) error {
 	form := new(projectForm)
@@ -181,15 +277,22 @@ func ProjectUpdate(c *gin.Context) error {
 		return err
 	}
 
-	if err := CheckUserPermission(c, project.UserID); err != nil {
+	if err := CheckProjectPermission(c, project.ID, types.PermissionWrite); err != nil {
 		return err
 	}
 
+	// Only admins can change the visibility of the project
+	if form.IsPrivate != nil && *form.IsPrivate != project.IsPrivate {
+		if err := CheckProjectPermission(c, project.ID, types.PermissionAdmin); err != nil {
+			return err
+		}
+	}
+
 	if form.MainScreen != nil {
 		project.MainScreen = *form.MainScreen
 	}
 
-	if err := saveProject(form, project); err != nil {
+	if err := saveProject(form, project, common.IfMatch(c)); err != nil {
 		return err
 	}
 
@@ -201,8 +304,14 @@ func ProjectUpdate(c *gin.Context) error {
 		if err := model.UpdateElementOrder(option, *form.Elements); err != nil {
 			return err
 		}
+
+		// The update time is changed by the trigger of elements
+		if project, err = model.GetProjectWithOwner(project.ID); err != nil {
+			return err
+		}
 	}
 
+	common.SetETag(c, project.UpdatedAt.Time)
 	return common.APIResponse(c, http.StatusOK, project)
 }
 
@@ -218,7 +327,7 @@ func ProjectDestroy(c *gin.Context) error {
 		return err
 	}
 
-	if err := project.Delete(); err != nil {
+	if err := project.DeleteIf(common.IfMatch(c)); err != nil {
 		return err
 	}
 
@@ -233,11 +342,23 @@ func ProjectFull(c *gin.Context) error {
 		return err
 	}
 
-	option := parseElementListQueryOption(c)
+	option, fields, err := parseElementListQueryOption(c)
+
+	if err != nil {
+		return err
+	}
+
 	option.ProjectID = &project.ID
 	option.WithEvents = true
 
-	elements, err := model.GetElementList(option)
+	list, err := model.GetElementList(option)
+
+	if err != nil {
+		return err
+	}
+
+	// Fields are only applied to elements
+	elements, err := selectFields(list, fields)
 
 	if err != nil {
 		return err
@@ -251,11 +372,48 @@ func ProjectFull(c *gin.Context) error {
 
 	return common.APIResponse(c, http.StatusOK, struct {
 		*model.Project
-		Elements []*model.Element `json:"elements"`
-		Assets   []*model.Asset   `json:"assets"`
+		Elements interface{}    `json:"elements"`
+		Assets   []*model.Asset `json:"assets"`
 	}{
 		Project:  project,
 		Elements: elements,
 		Assets:   assets,
 	})
 }
+
+// ProjectFork handles POST /projects/:project_id/fork.
+// Public projects can be forked by any user. Private projects can only be
+// forked by the owner.
+func ProjectFork(c *gin.Context) error {
+	project, err := GetProject(c)
+
+	if err != nil {
+		return err
+	}
+
+	token, err := CheckToken(c)
+
+	if err != nil {
+		return err
+	}
+
+	// Forking creates a project in the account of the user. Private projects
+	// can only be forked by their owners.
+	userID := token.UserID
+
+	if project.IsPrivate {
+		userID = project.UserID
+	}
+
+	if err := CheckUserPermission(c, userID); err != nil {
+		return err
+	}
+
+	result, err := model.ForkProject(project, userID)
+
+	if err != nil {
+		return err
+	}
+
+	return common.APIResponse(c, http.StatusCreated, result)
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
@@ -21,7 +22,7 @@ func createTestProject(user *model.User, token *model.Token, data interface{}, b
 		URL:    "/users/" + user.ID.String() + "/projects",
 		Body:   body,
 		Headers: map[string]string{
-			"Authorization": "Bearer " + token.ID.String(),
+			"Authorization": "Bearer " + token.Secret.String(),
 		},
 	})
 
@@ -63,7 +64,7 @@ func TestProjectList(t *testing.T) {
 			Method: "GET",
 			URL:    "/users/" + u1.ID.String() + "/projects",
 			Headers: map[string]string{
-				"Authorization": "Bearer " + t1.ID.String(),
+				"Authorization": "Bearer " + t1.Secret.String(),
 			},
 		})
 
@@ -83,7 +84,7 @@ func TestProjectList(t *testing.T) {
 				Method: "GET",
 				URL:    "/users/" + u2.ID.String() + "/projects",
 				Headers: map[string]string{
-					"Authorization": "Bearer " + t2.ID.String(),
+					"Authorization": "Bearer " + t2.Secret.String(),
 				},
 			})
 
@@ -99,7 +100,7 @@ func TestProjectList(t *testing.T) {
 				Method: "GET",
 				URL:    "/users/" + u2.ID.String() + "/projects",
 				Headers: map[string]string{
-					"Authorization": "Bearer " + t1.ID.String(),
+					"Authorization": "Bearer " + t1.Secret.String(),
 				},
 			})
 
@@ -124,6 +125,86 @@ func TestProjectList(t *testing.T) {
 		})*/
 }
 
+func TestProjectSearch(t *testing.T) {
+	u1 := new(model.User)
+	createTestUser(u1, fixtureUsers[0])
+	defer u1.Delete()
+
+	u2 := new(model.User)
+	createTestUser(u2, fixtureUsers[1])
+	defer u2.Delete()
+
+	t1 := new(model.Token)
+	createTestToken(t1, fixtureUsers[0])
+	defer t1.Delete()
+
+	t2 := new(model.Token)
+	createTestToken(t2, fixtureUsers[1])
+	defer t2.Delete()
+
+	p1 := new(model.Project)
+	createTestProject(u1, t1, p1, fixtureProjects[0])
+	defer p1.Delete()
+
+	p2 := new(model.Project)
+	createTestProject(u2, t2, p2, fixtureProjects[1])
+	defer p2.Delete()
+
+	search := func(query string, token *model.Token) *httptest.ResponseRecorder {
+		headers := map[string]string{}
+
+		if token != nil {
+			headers["Authorization"] = "Bearer " + token.Secret.String()
+		}
+
+		return request(&requestOptions{
+			Method:  "GET",
+			URL:     "/projects?q=" + url.QueryEscape(query),
+			Headers: headers,
+		})
+	}
+
+	Convey("Search public projects", t, func() {
+		list := new(model.ProjectCollection)
+		r := search("hello", nil)
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		parseJSON(r.Body, list)
+		So(list.Data, ShouldHaveLength, 1)
+		So(list.Data[0].ID, ShouldResemble, p1.ID)
+		So(*list.Count, ShouldEqual, 1)
+	})
+
+	Convey("Hide private projects to others", t, func() {
+		list := new(model.ProjectCollection)
+		r := search("world", t1)
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		parseJSON(r.Body, list)
+		So(list.Data, ShouldHaveLength, 0)
+	})
+
+	Convey("Include private projects of the owner", t, func() {
+		list := new(model.ProjectCollection)
+		r := search("world", t2)
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		parseJSON(r.Body, list)
+		So(list.Data, ShouldHaveLength, 1)
+		So(list.Data[0].ID, ShouldResemble, p2.ID)
+	})
+
+	Convey("Quotes in the query", t, func() {
+		list := new(model.ProjectCollection)
+		r := search("hello'); DROP TABLE projects; --", nil)
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		parseJSON(r.Body, list)
+		So(list.Data, ShouldHaveLength, 0)
+		So(p1.Exists(), ShouldBeTrue)
+	})
+}
+
 func TestProjectCreate(t *testing.T) {
 	u1 := new(model.User)
 	createTestUser(u1, fixtureUsers[0])
@@ -162,7 +243,7 @@ func TestProjectCreate(t *testing.T) {
 			Method: "POST",
 			URL:    "/users/" + uuid.New() + "/projects",
 			Headers: map[string]string{
-				"Authorization": "Bearer " + t1.ID.String(),
+				"Authorization": "Bearer " + t1.Secret.String(),
 			},
 		})
 
@@ -180,7 +261,7 @@ func TestProjectCreate(t *testing.T) {
 			Method: "POST",
 			URL:    "/users/" + u1.ID.String() + "/projects",
 			Headers: map[string]string{
-				"Authorization": "Bearer " + t2.ID.String(),
+				"Authorization": "Bearer " + t2.Secret.String(),
 			},
 		})
 
@@ -224,7 +305,7 @@ func TestProjectShow(t *testing.T) {
 			Method: "GET",
 			URL:    "/projects/" + p1.ID.String(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + t1.ID.String(),
+				"Authorization": "Bearer " + t1.Secret.String(),
 			},
 		})
 
@@ -248,7 +329,7 @@ func TestProjectShow(t *testing.T) {
 			Method: "GET",
 			URL:    "/projects/" + p1.ID.String(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + t2.ID.String(),
+				"Authorization": "Bearer " + t2.Secret.String(),
 			},
 		})
 
@@ -263,7 +344,7 @@ func TestProjectShow(t *testing.T) {
 			Method: "GET",
 			URL:    "/projects/" + p2.ID.String(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + t1.ID.String(),
+				"Authorization": "Bearer " + t1.Secret.String(),
 			},
 		})
 
@@ -321,7 +402,7 @@ func TestProjectUpdate(t *testing.T) {
 			Method: "PUT",
 			URL:    "/projects/" + p1.ID.String(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + t1.ID.String(),
+				"Authorization": "Bearer " + t1.Secret.String(),
 			},
 			Body: map[string]interface{}{
 				"title":       newTitle,
@@ -346,7 +427,7 @@ func TestProjectUpdate(t *testing.T) {
 			Method: "PUT",
 			URL:    "/projects/" + p1.ID.String(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + t2.ID.String(),
+				"Authorization": "Bearer " + t2.Secret.String(),
 			},
 			Body: map[string]interface{}{},
 		})
@@ -365,7 +446,7 @@ func TestProjectUpdate(t *testing.T) {
 			Method: "PUT",
 			URL:    "/projects/" + uuid.New(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + t1.ID.String(),
+				"Authorization": "Bearer " + t1.Secret.String(),
 			},
 			Body: map[string]interface{}{},
 		})
@@ -405,7 +486,7 @@ func TestProjectDestroy(t *testing.T) {
 			Method: "DELETE",
 			URL:    "/projects/" + p1.ID.String(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + t1.ID.String(),
+				"Authorization": "Bearer " + t1.Secret.String(),
 			},
 		})
 
@@ -423,7 +504,7 @@ func TestProjectDestroy(t *testing.T) {
 			Method: "DELETE",
 			URL:    "/projects/" + p1.ID.String(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + t2.ID.String(),
+				"Authorization": "Bearer " + t2.Secret.String(),
 			},
 		})
 
@@ -441,7 +522,7 @@ func TestProjectDestroy(t *testing.T) {
 			Method: "DELETE",
 			URL:    "/projects/" + uuid.New(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + t1.ID.String(),
+				"Authorization": "Bearer " + t1.Secret.String(),
 			},
 		})
 
@@ -453,3 +534,160 @@ func TestProjectDestroy(t *testing.T) {
 		})
 	})
 }
+
+func TestProjectFork(t *testing.T) {
+	u1 := new(model.User)
+	createTestUser(u1, fixtureUsers[0])
+	defer u1.Delete()
+
+	u2 := new(model.User)
+	createTestUser(u2, fixtureUsers[1])
+	defer u2.Delete()
+
+	t1 := new(model.Token)
+	createTestToken(t1, fixtureUsers[0])
+	defer t1.Delete()
+
+	t2 := new(model.Token)
+	createTestToken(t2, fixtureUsers[1])
+	defer t2.Delete()
+
+	p1 := new(model.Project)
+	createTestProject(u1, t1, p1, fixtureProjects[0])
+	defer p1.Delete()
+
+	p2 := new(model.Project)
+	createTestProject(u1, t1, p2, fixtureProjects[1])
+	defer p2.Delete()
+
+	fork := func(project *model.Project, token *model.Token, data interface{}) int {
+		headers := map[string]string{}
+
+		if token != nil {
+			headers["Authorization"] = "Bearer " + token.Secret.String()
+		}
+
+		r := request(&requestOptions{
+			Method:  "POST",
+			URL:     "/projects/" + project.ID.String() + "/fork",
+			Headers: headers,
+		})
+
+		parseJSON(r.Body, data)
+		return r.Code
+	}
+
+	Convey("Fork a public project", t, func() {
+		p := new(model.Project)
+		code := fork(p1, t2, p)
+		defer p.Delete()
+
+		So(code, ShouldEqual, http.StatusCreated)
+		So(p.ID, ShouldNotResemble, p1.ID)
+		So(p.UserID, ShouldResemble, u2.ID)
+		So(p.Title, ShouldEqual, p1.Title)
+	})
+
+	Convey("Fork own private project", t, func() {
+		p := new(model.Project)
+		code := fork(p2, t1, p)
+		defer p.Delete()
+
+		So(code, ShouldEqual, http.StatusCreated)
+		So(p.UserID, ShouldResemble, u1.ID)
+	})
+
+	Convey("Fork other's private project", t, func() {
+		err := new(util.APIError)
+		So(fork(p2, t2, err), ShouldEqual, http.StatusForbidden)
+		So(err, ShouldResemble, &util.APIError{
+			Code:    util.UserForbiddenError,
+			Message: "You are forbidden to access.",
+		})
+	})
+
+	Convey("Token is required", t, func() {
+		err := new(util.APIError)
+		So(fork(p1, nil, err), ShouldEqual, http.StatusUnauthorized)
+		So(err.Code, ShouldEqual, util.TokenRequiredError)
+	})
+}
+
+func TestProjectStats(t *testing.T) {
+	u1 := new(model.User)
+	createTestUser(u1, fixtureUsers[0])
+	defer u1.Delete()
+
+	u2 := new(model.User)
+	createTestUser(u2, fixtureUsers[1])
+	defer u2.Delete()
+
+	t1 := new(model.Token)
+	createTestToken(t1, fixtureUsers[0])
+	defer t1.Delete()
+
+	t2 := new(model.Token)
+	createTestToken(t2, fixtureUsers[1])
+	defer t2.Delete()
+
+	project := new(model.Project)
+	createTestProject(u1, t1, project, fixtureProjects[1])
+	defer project.Delete()
+
+	screen := new(model.Element)
+	createTestElement(project, t1, screen, fixtureElements[0])
+
+	asset := new(model.Asset)
+	createTestAsset(project, t1, asset, "a.txt", []byte("foo"))
+
+	statsURL := "/projects/" + project.ID.String() + "/stats"
+
+	Convey("Success", t, func() {
+		stats := new(model.ProjectDetailStats)
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    statsURL,
+			Headers: map[string]string{
+				"Authorization": "Bearer " + t1.Secret.String(),
+			},
+		})
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		parseJSON(r.Body, stats)
+		So(stats.ElementCount, ShouldEqual, 1)
+		So(stats.ElementTypes, ShouldResemble, map[string]int{screen.Type: 1})
+		So(stats.AssetCount, ShouldEqual, 1)
+		So(stats.AssetSize, ShouldEqual, 3)
+		So(stats.LastEditedAt.IsZero(), ShouldBeFalse)
+	})
+
+	Convey("Include stats in the project", t, func() {
+		p := new(model.Project)
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    "/projects/" + project.ID.String() + "?include=stats",
+			Headers: map[string]string{
+				"Authorization": "Bearer " + t1.Secret.String(),
+			},
+		})
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		parseJSON(r.Body, p)
+		So(p.Stats, ShouldResemble, &model.ProjectStats{
+			ElementCount: 1,
+			AssetCount:   1,
+		})
+	})
+
+	Convey("Forbidden", t, func() {
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    statsURL,
+			Headers: map[string]string{
+				"Authorization": "Bearer " + t2.Secret.String(),
+			},
+		})
+
+		So(r.Code, ShouldEqual, http.StatusForbidden)
+	})
+}
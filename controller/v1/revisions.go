@@ -0,0 +1,67 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tkusd/server/controller/common"
+	"github.com/tkusd/server/model"
+	"github.com/tkusd/server/model/types"
+)
+
+// RevisionList handles GET /projects/:project_id/revisions.
+func RevisionList(c *gin.Context) error {
+	projectID, err := GetIDParam(c, projectIDParam)
+
+	if err != nil {
+		return err
+	}
+
+	if err := CheckProjectPermission(c, *projectID, types.PermissionRead); err != nil {
+		return err
+	}
+
+	list, err := model.GetRevisionList(*projectID)
+
+	if err != nil {
+		return err
+	}
+
+	return common.APIResponse(c, http.StatusOK, list)
+}
+
+// RevisionShow handles GET /revisions/:revision_id.
+func RevisionShow(c *gin.Context) error {
+	revision, err := GetRevision(c)
+
+	if err != nil {
+		return err
+	}
+
+	if err := CheckProjectPermission(c, revision.ProjectID, types.PermissionRead); err != nil {
+		return err
+	}
+
+	return common.APIResponse(c, http.StatusOK, revision)
+}
+
+// RevisionRestore handles POST /revisions/:revision_id/restore.
+func RevisionRestore(c *gin.Context) error {
+	revision, err := GetRevision(c)
+
+	if err != nil {
+		return err
+	}
+
+	if err := CheckProjectPermission(c, revision.ProjectID, types.PermissionWrite); err != nil {
+		return err
+	}
+
+	project, err := model.RestoreRevision(revision)
+
+	if err != nil {
+		return err
+	}
+
+	return common.APIResponse(c, http.StatusOK, project)
+}
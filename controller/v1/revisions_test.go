@@ -0,0 +1,122 @@
+package v1
+
+import (
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tkusd/server/model"
+)
+
+func TestRevision(t *testing.T) {
+	u1 := new(model.User)
+	createTestUser(u1, fixtureUsers[0])
+	defer u1.Delete()
+
+	u2 := new(model.User)
+	createTestUser(u2, fixtureUsers[1])
+	defer u2.Delete()
+
+	t1 := new(model.Token)
+	createTestToken(t1, fixtureUsers[0])
+	defer t1.Delete()
+
+	t2 := new(model.Token)
+	createTestToken(t2, fixtureUsers[1])
+	defer t2.Delete()
+
+	project := new(model.Project)
+	createTestProject(u1, t1, project, fixtureProjects[1])
+	defer project.Delete()
+
+	element := new(model.Element)
+	createTestElement(project, t1, element, fixtureElements[0])
+
+	var list []*model.Revision
+
+	Convey("List", t, func() {
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    "/projects/" + project.ID.String() + "/revisions",
+			Headers: map[string]string{
+				"Authorization": "Bearer " + t1.Secret.String(),
+			},
+		})
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		parseJSON(r.Body, &list)
+
+		// The new project is kept as the baseline
+		So(list, ShouldHaveLength, 2)
+		So(list[0].Snapshot, ShouldBeNil)
+	})
+
+	Convey("Show", t, func() {
+		revision := new(model.Revision)
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    "/revisions/" + list[0].ID.String(),
+			Headers: map[string]string{
+				"Authorization": "Bearer " + t1.Secret.String(),
+			},
+		})
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		parseJSON(r.Body, revision)
+		So(revision.ProjectID, ShouldResemble, project.ID)
+		So(revision.Snapshot, ShouldNotBeNil)
+	})
+
+	Convey("Forbidden", t, func() {
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    "/revisions/" + list[0].ID.String(),
+			Headers: map[string]string{
+				"Authorization": "Bearer " + t2.Secret.String(),
+			},
+		})
+
+		So(r.Code, ShouldEqual, http.StatusForbidden)
+	})
+
+	Convey("Restore", t, func() {
+		restore := func(id string) int {
+			r := request(&requestOptions{
+				Method: "POST",
+				URL:    "/revisions/" + id + "/restore",
+				Headers: map[string]string{
+					"Authorization": "Bearer " + t1.Secret.String(),
+				},
+			})
+
+			return r.Code
+		}
+
+		listRevisions := func() []*model.Revision {
+			var revisions []*model.Revision
+			r := request(&requestOptions{
+				Method: "GET",
+				URL:    "/projects/" + project.ID.String() + "/revisions",
+				Headers: map[string]string{
+					"Authorization": "Bearer " + t1.Secret.String(),
+				},
+			})
+
+			parseJSON(r.Body, &revisions)
+			return revisions
+		}
+
+		// Restore the baseline before the element is created
+		So(restore(list[1].ID.String()), ShouldEqual, http.StatusOK)
+		So(element.Exists(), ShouldBeFalse)
+
+		revisions := listRevisions()
+		So(revisions, ShouldHaveLength, 3)
+
+		Convey("Undo", func() {
+			So(restore(revisions[1].ID.String()), ShouldEqual, http.StatusOK)
+			So(element.Exists(), ShouldBeTrue)
+			So(listRevisions(), ShouldHaveLength, 4)
+		})
+	})
+}
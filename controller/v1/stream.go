@@ -0,0 +1,93 @@
+package v1
+
+import (
+	"io"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tkusd/server/model"
+	"github.com/tkusd/server/model/types"
+)
+
+// streamHeartbeat is the interval of heartbeat events, which keep the
+// connection alive through proxies.
+const streamHeartbeat = 30 * time.Second
+
+// ProjectStream handles GET /projects/:project_id/stream.
+// Changes of the project are pushed as Server-Sent Events. The event name is
+// the type and the action of the change, e.g. "element.update". The token can
+// be sent in the query string since EventSource can't set headers.
+//
+// The permission is checked again before each change and heartbeat, and the
+// stream is closed if the token is revoked or expired, or the user can't read
+// the project anymore.
+func ProjectStream(c *gin.Context) error {
+	projectID, err := GetIDParam(c, projectIDParam)
+
+	if err != nil {
+		return err
+	}
+
+	acceptTokenQuery(c)
+
+	if err := CheckProjectPermission(c, *projectID, types.PermissionRead); err != nil {
+		return err
+	}
+
+	sub := model.Subscribe(*projectID)
+	defer sub.Close()
+
+	ticker := time.NewTicker(streamHeartbeat)
+	defer ticker.Stop()
+
+	gone := c.Writer.CloseNotify()
+
+	// The token isn't touched, so an open stream won't keep the session alive
+	allowed := func() bool {
+		token, err := findToken(c)
+		return checkTokenProjectPermission(token, err, *projectID, types.PermissionRead, false) == nil
+	}
+
+	c.Header(headerCacheControl, "no-cache")
+	c.Header("Connection", "keep-alive")
+
+	// Send the headers before the first event
+	c.Writer.WriteHeaderNow()
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case change, ok := <-sub.C:
+			if !ok || !allowed() {
+				return false
+			}
+
+			c.SSEvent(change.Type+"."+change.Action, change)
+
+			// Stop streaming if the project is deleted
+			return !(change.Type == model.ChangeTypeProject && change.Action == model.ChangeDelete)
+
+		case <-sub.Resync:
+			// Pending changes are stale since some were dropped
+			for n := len(sub.C); n > 0; n-- {
+				<-sub.C
+			}
+
+			c.SSEvent("resync", time.Now().UTC())
+			return true
+
+		case <-ticker.C:
+			if !allowed() {
+				return false
+			}
+
+			c.SSEvent("heartbeat", time.Now().UTC())
+			return true
+
+		case <-gone:
+			return false
+		}
+	})
+
+	return nil
+}
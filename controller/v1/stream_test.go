@@ -0,0 +1,101 @@
+package v1
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tkusd/server/model"
+	"github.com/tkusd/server/util"
+)
+
+func TestProjectStream(t *testing.T) {
+	u1 := new(model.User)
+	createTestUser(u1, fixtureUsers[0])
+	defer u1.Delete()
+
+	u2 := new(model.User)
+	createTestUser(u2, fixtureUsers[1])
+	defer u2.Delete()
+
+	t1 := new(model.Token)
+	createTestToken(t1, fixtureUsers[0])
+	defer t1.Delete()
+
+	t2 := new(model.Token)
+	createTestToken(t2, fixtureUsers[1])
+	defer t2.Delete()
+
+	project := new(model.Project)
+	createTestProject(u1, t1, project, fixtureProjects[1])
+	defer project.Delete()
+
+	streamURL := "/projects/" + project.ID.String() + "/stream"
+
+	Convey("Token is required for private projects", t, func() {
+		err := new(util.APIError)
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    streamURL,
+		})
+
+		So(r.Code, ShouldEqual, http.StatusUnauthorized)
+		parseJSON(r.Body, err)
+		So(err.Code, ShouldEqual, util.TokenRequiredError)
+	})
+
+	Convey("Invalid token in the query string", t, func() {
+		err := new(util.APIError)
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    streamURL + "?access_token=foo",
+		})
+
+		So(r.Code, ShouldEqual, http.StatusUnauthorized)
+		parseJSON(r.Body, err)
+		So(err.Code, ShouldEqual, util.TokenInvalidError)
+	})
+
+	Convey("Token in the query string", t, func() {
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    streamURL + "?access_token=" + t2.Secret.String(),
+		})
+
+		So(r.Code, ShouldEqual, http.StatusForbidden)
+	})
+	Convey("Stream is closed when the token is revoked", t, func() {
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		token := new(model.Token)
+		createTestToken(token, fixtureUsers[0])
+
+		res, err := http.Get(server.URL + streamURL + "?access_token=" + token.Secret.String())
+		So(err, ShouldBeNil)
+		defer res.Body.Close()
+		So(res.StatusCode, ShouldEqual, http.StatusOK)
+
+		done := make(chan []byte, 1)
+
+		go func() {
+			body, _ := ioutil.ReadAll(res.Body)
+			done <- body
+		}()
+
+		token.Delete()
+		project.Title = "Revoked"
+		So(project.Save(), ShouldBeNil)
+
+		select {
+		case body := <-done:
+			// The change isn't sent to the revoked token
+			So(string(body), ShouldNotContainSubstring, "Revoked")
+		case <-time.After(5 * time.Second):
+			So("the stream is still open", ShouldBeEmpty)
+		}
+	})
+}
@@ -2,12 +2,15 @@ package v1
 
 import (
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/gin-gonic/gin"
 	"github.com/mholt/binding"
 	"github.com/tkusd/server/controller/common"
 	"github.com/tkusd/server/model"
+	"github.com/tkusd/server/model/types"
 	"github.com/tkusd/server/util"
 )
 
@@ -49,6 +52,10 @@ func TokenCreate(c *gin.Context) error {
 		}
 	}
 
+	if err := common.CheckAccountRateLimit(c, authRateLimitGroup, strings.ToLower(form.Email)); err != nil {
+		return err
+	}
+
 	if user, _ = model.GetUserByEmail(form.Email); user == nil {
 		return &util.APIError{
 			Field:   "email",
@@ -61,7 +68,11 @@ func TokenCreate(c *gin.Context) error {
 		return err
 	}
 
-	token := &model.Token{UserID: user.ID}
+	token := &model.Token{
+		UserID:    user.ID,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
 
 	if err := token.Save(); err != nil {
 		return err
@@ -71,6 +82,62 @@ func TokenCreate(c *gin.Context) error {
 	return common.APIResponse(c, http.StatusCreated, token)
 }
 
+type tokenRefreshForm struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (form *tokenRefreshForm) FieldMap() binding.FieldMap {
+	return binding.FieldMap{
+		&form.RefreshToken: "refresh_token",
+	}
+}
+
+// TokenRefresh handles POST /tokens/refresh.
+// New secrets are issued and the old ones become invalid.
+func TokenRefresh(c *gin.Context) error {
+	form := new(tokenRefreshForm)
+
+	if err := common.BindForm(c, form); err != nil {
+		return err
+	}
+
+	if form.RefreshToken == "" {
+		return &util.APIError{
+			Field:   "refresh_token",
+			Code:    util.RequiredError,
+			Message: "Refresh token is required.",
+		}
+	}
+
+	token, err := model.GetTokenByRefreshSecret(form.RefreshToken)
+
+	// Personal tokens can't be refreshed
+	if err != nil || token.Kind != model.TokenKindSession {
+		return &util.APIError{
+			Field:   "refresh_token",
+			Code:    util.TokenInvalidError,
+			Message: "Refresh token is invalid.",
+			Status:  http.StatusUnauthorized,
+		}
+	}
+
+	if token.IsRefreshExpired() {
+		return &util.APIError{
+			Field:   "refresh_token",
+			Code:    util.TokenExpiredError,
+			Message: "Refresh token has expired.",
+			Status:  http.StatusUnauthorized,
+		}
+	}
+
+	if err := token.Refresh(); err != nil {
+		return err
+	}
+
+	common.NoCacheHeader(c)
+	return common.APIResponse(c, http.StatusOK, token)
+}
+
 // TokenShow handles GET /tokens/:key
 func TokenShow(c *gin.Context) error {
 	token, err := GetToken(c)
@@ -79,6 +146,10 @@ func TokenShow(c *gin.Context) error {
 		return err
 	}
 
+	if err := CheckUserPermission(c, token.UserID); err != nil {
+		return err
+	}
+
 	common.NoCacheHeader(c)
 	return common.APIResponse(c, http.StatusOK, token.WithoutSecret())
 }
@@ -91,6 +162,10 @@ func TokenDestroy(c *gin.Context) error {
 		return err
 	}
 
+	if err := CheckUserPermission(c, token.UserID); err != nil {
+		return err
+	}
+
 	if err := token.Delete(); err != nil {
 		return err
 	}
@@ -98,3 +173,188 @@ func TokenDestroy(c *gin.Context) error {
 	c.Writer.WriteHeader(http.StatusNoContent)
 	return nil
 }
+
+// UserTokenList handles GET /users/:user_id/tokens.
+func UserTokenList(c *gin.Context) error {
+	userID, err := GetIDParam(c, userIDParam)
+
+	if err != nil {
+		return err
+	}
+
+	if err := CheckUserPermission(c, *userID); err != nil {
+		return err
+	}
+
+	current, err := CheckToken(c)
+
+	if err != nil {
+		return err
+	}
+
+	list, err := model.GetUserTokens(*userID)
+
+	if err != nil {
+		return err
+	}
+
+	result := make([]map[string]interface{}, len(list))
+
+	for i, token := range list {
+		result[i] = token.WithoutSecret()
+		result[i]["is_current"] = token.ID.Equal(current.ID)
+	}
+
+	common.NoCacheHeader(c)
+	return common.APIResponse(c, http.StatusOK, result)
+}
+
+// UserTokenDestroy handles DELETE /users/:user_id/tokens.
+// All session tokens except the current one are deleted.
+func UserTokenDestroy(c *gin.Context) error {
+	userID, err := GetIDParam(c, userIDParam)
+
+	if err != nil {
+		return err
+	}
+
+	if err := CheckUserPermission(c, *userID); err != nil {
+		return err
+	}
+
+	current, err := CheckToken(c)
+
+	if err != nil {
+		return err
+	}
+
+	if err := model.DeleteUserSessions(*userID, current.ID); err != nil {
+		return err
+	}
+
+	c.Writer.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// revokeOtherTokens deletes the tokens of the user, including personal tokens,
+// except the one used in the request, if any.
+func revokeOtherTokens(c *gin.Context, userID types.UUID) error {
+	var except types.UUID
+
+	if token, err := CheckToken(c); err == nil && token.UserID.Equal(userID) {
+		except = token.ID
+	}
+
+	return model.DeleteUserTokens(userID, except)
+}
+
+type personalTokenForm struct {
+	Name      *string     `json:"name"`
+	Scopes    *[]string   `json:"scopes"`
+	ProjectID *types.UUID `json:"project_id"`
+	ExpiresAt *string     `json:"expires_at"`
+}
+
+func (form *personalTokenForm) FieldMap() binding.FieldMap {
+	return binding.FieldMap{
+		&form.Name:      "name",
+		&form.Scopes:    "scopes",
+		&form.ProjectID: "project_id",
+		&form.ExpiresAt: "expires_at",
+	}
+}
+
+// PersonalTokenList handles GET /users/:user_id/personal_tokens.
+func PersonalTokenList(c *gin.Context) error {
+	userID, err := GetIDParam(c, userIDParam)
+
+	if err != nil {
+		return err
+	}
+
+	if err := CheckUserPermission(c, *userID); err != nil {
+		return err
+	}
+
+	list, err := model.GetPersonalTokens(*userID)
+
+	if err != nil {
+		return err
+	}
+
+	result := make([]map[string]interface{}, len(list))
+
+	for i, token := range list {
+		result[i] = token.WithoutSecret()
+	}
+
+	common.NoCacheHeader(c)
+	return common.APIResponse(c, http.StatusOK, result)
+}
+
+// PersonalTokenCreate handles POST /users/:user_id/personal_tokens.
+// The secret is only returned once.
+func PersonalTokenCreate(c *gin.Context) error {
+	userID, err := GetIDParam(c, userIDParam)
+
+	if err != nil {
+		return err
+	}
+
+	if err := CheckUserPermission(c, *userID); err != nil {
+		return err
+	}
+
+	form := new(personalTokenForm)
+
+	if err := common.BindForm(c, form); err != nil {
+		return err
+	}
+
+	token := &model.Token{
+		UserID:    *userID,
+		Kind:      model.TokenKindPersonal,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+
+	if form.Name != nil {
+		token.Name = *form.Name
+	}
+
+	if form.Scopes != nil {
+		token.Scopes = types.Scopes(*form.Scopes)
+	}
+
+	if form.ProjectID != nil && form.ProjectID.Valid() {
+		if err := CheckProjectPermission(c, *form.ProjectID, types.PermissionRead); err != nil {
+			return err
+		}
+
+		token.ProjectID = *form.ProjectID
+	}
+
+	if form.ExpiresAt != nil && *form.ExpiresAt != "" {
+		var t time.Time
+
+		if t, err = types.ParseISOTime(*form.ExpiresAt); err != nil {
+			return &util.APIError{
+				Field:   "expires_at",
+				Code:    util.TypeError,
+				Message: "Expiry time must be in ISO 8601 format.",
+			}
+		}
+
+		token.ExpiresAt = types.NullTime{Time: t}
+	}
+
+	if err := token.Save(); err != nil {
+		return err
+	}
+
+	result := token.WithoutSecret()
+	result["secret"] = token.Secret
+
+	common.NoCacheHeader(c)
+	return common.APIResponse(c, http.StatusCreated, result)
+}
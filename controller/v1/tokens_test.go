@@ -105,6 +105,66 @@ func TestTokenCreate(t *testing.T) {
 	})
 }
 
+func TestTokenRefresh(t *testing.T) {
+	user := new(model.User)
+	createTestUser(user, fixtureUsers[0])
+	defer user.Delete()
+
+	token := new(model.Token)
+	createTestToken(token, fixtureUsers[0])
+	defer token.Delete()
+
+	refresh := func(secret string, data interface{}) int {
+		r := request(&requestOptions{
+			Method: "POST",
+			URL:    "/tokens/refresh",
+			Body:   map[string]string{"refresh_token": secret},
+		})
+
+		if err := parseJSON(r.Body, data); err != nil {
+			log.Fatal(err)
+		}
+
+		return r.Code
+	}
+
+	Convey("Success", t, func() {
+		result := new(model.Token)
+		So(refresh(token.RefreshSecret.String(), result), ShouldEqual, http.StatusOK)
+		So(result.ID, ShouldResemble, token.ID)
+		So(result.Secret.Hash, ShouldHaveLength, 32)
+		So(result.Secret, ShouldNotResemble, token.Secret)
+		So(result.RefreshSecret, ShouldNotResemble, token.RefreshSecret)
+
+		// The old refresh token becomes invalid
+		err := new(util.APIError)
+		So(refresh(token.RefreshSecret.String(), err), ShouldEqual, http.StatusUnauthorized)
+		So(err, ShouldResemble, &util.APIError{
+			Field:   "refresh_token",
+			Code:    util.TokenInvalidError,
+			Message: "Refresh token is invalid.",
+		})
+
+		// The old secret becomes invalid
+		_, e := model.GetTokenBySecret(token.Secret.String())
+		So(e, ShouldNotBeNil)
+
+		found, e := model.GetTokenBySecret(result.Secret.String())
+		So(e, ShouldBeNil)
+		So(found.ID, ShouldResemble, token.ID)
+	})
+
+	Convey("Refresh token is required", t, func() {
+		err := new(util.APIError)
+		So(refresh("", err), ShouldEqual, http.StatusBadRequest)
+		So(err, ShouldResemble, &util.APIError{
+			Field:   "refresh_token",
+			Code:    util.RequiredError,
+			Message: "Refresh token is required.",
+		})
+	})
+}
+
 func TestTokenDestroy(t *testing.T) {
 	user := new(model.User)
 	createTestUser(user, fixtureUsers[0])
@@ -116,6 +176,9 @@ func TestTokenDestroy(t *testing.T) {
 		r := request(&requestOptions{
 			Method: "DELETE",
 			URL:    "/tokens/" + token.ID.String(),
+			Headers: map[string]string{
+				"Authorization": "Bearer " + token.Secret.String(),
+			},
 		})
 
 		So(r.Code, ShouldEqual, http.StatusNoContent)
@@ -124,6 +187,54 @@ func TestTokenDestroy(t *testing.T) {
 		So(err, ShouldNotBeNil)
 	})
 
+	Convey("Token is required", t, func() {
+		token := new(model.Token)
+		createTestToken(token, fixtureUsers[0])
+		defer token.Delete()
+
+		r := request(&requestOptions{
+			Method: "DELETE",
+			URL:    "/tokens/" + token.ID.String(),
+		})
+
+		So(r.Code, ShouldEqual, http.StatusUnauthorized)
+
+		_, err := model.GetToken(token.ID)
+		So(err, ShouldBeNil)
+	})
+
+	Convey("Forbidden", t, func() {
+		other := new(model.User)
+		createTestUser(other, fixtureUsers[1])
+		defer other.Delete()
+
+		t1 := new(model.Token)
+		createTestToken(t1, fixtureUsers[0])
+		defer t1.Delete()
+
+		t2 := new(model.Token)
+		createTestToken(t2, fixtureUsers[1])
+		defer t2.Delete()
+
+		for _, method := range []string{"GET", "DELETE"} {
+			err := new(util.APIError)
+			r := request(&requestOptions{
+				Method: method,
+				URL:    "/tokens/" + t1.ID.String(),
+				Headers: map[string]string{
+					"Authorization": "Bearer " + t2.Secret.String(),
+				},
+			})
+
+			So(r.Code, ShouldEqual, http.StatusForbidden)
+			parseJSON(r.Body, err)
+			So(err.Code, ShouldEqual, util.UserForbiddenError)
+		}
+
+		_, err := model.GetToken(t1.ID)
+		So(err, ShouldBeNil)
+	})
+
 	Convey("Token not found", t, func() {
 		err := new(util.APIError)
 		r := request(&requestOptions{
@@ -143,3 +254,262 @@ func TestTokenDestroy(t *testing.T) {
 		})
 	})
 }
+
+func TestUserTokens(t *testing.T) {
+	u1 := new(model.User)
+	createTestUser(u1, fixtureUsers[0])
+	defer u1.Delete()
+
+	u2 := new(model.User)
+	createTestUser(u2, fixtureUsers[1])
+	defer u2.Delete()
+
+	t1 := new(model.Token)
+	createTestToken(t1, fixtureUsers[0])
+	defer t1.Delete()
+
+	t2 := new(model.Token)
+	createTestToken(t2, fixtureUsers[0])
+	defer t2.Delete()
+
+	t3 := new(model.Token)
+	createTestToken(t3, fixtureUsers[1])
+	defer t3.Delete()
+
+	tokensURL := "/users/" + u1.ID.String() + "/tokens"
+
+	Convey("List sessions", t, func() {
+		var list []map[string]interface{}
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    tokensURL,
+			Headers: map[string]string{
+				"Authorization": "Bearer " + t1.Secret.String(),
+			},
+		})
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		parseJSON(r.Body, &list)
+		So(list, ShouldHaveLength, 2)
+
+		for _, item := range list {
+			So(item, ShouldNotContainKey, "secret")
+			So(item, ShouldNotContainKey, "refresh_secret")
+			So(item["is_current"], ShouldEqual, item["id"] == t1.ID.String())
+		}
+	})
+
+	Convey("Sessions of others", t, func() {
+		for _, method := range []string{"GET", "DELETE"} {
+			r := request(&requestOptions{
+				Method: method,
+				URL:    tokensURL,
+				Headers: map[string]string{
+					"Authorization": "Bearer " + t3.Secret.String(),
+				},
+			})
+
+			So(r.Code, ShouldEqual, http.StatusForbidden)
+		}
+	})
+
+	Convey("Sign out other sessions", t, func() {
+		r := request(&requestOptions{
+			Method: "DELETE",
+			URL:    tokensURL,
+			Headers: map[string]string{
+				"Authorization": "Bearer " + t1.Secret.String(),
+			},
+		})
+
+		So(r.Code, ShouldEqual, http.StatusNoContent)
+
+		_, err := model.GetToken(t1.ID)
+		So(err, ShouldBeNil)
+
+		_, err = model.GetToken(t2.ID)
+		So(err, ShouldNotBeNil)
+
+		// Sessions of other users are kept
+		_, err = model.GetToken(t3.ID)
+		So(err, ShouldBeNil)
+	})
+}
+
+func TestPersonalToken(t *testing.T) {
+	u1 := new(model.User)
+	createTestUser(u1, fixtureUsers[0])
+	defer u1.Delete()
+
+	u2 := new(model.User)
+	createTestUser(u2, fixtureUsers[1])
+	defer u2.Delete()
+
+	t1 := new(model.Token)
+	createTestToken(t1, fixtureUsers[0])
+	defer t1.Delete()
+
+	t2 := new(model.Token)
+	createTestToken(t2, fixtureUsers[1])
+	defer t2.Delete()
+
+	p1 := new(model.Project)
+	createTestProject(u1, t1, p1, fixtureProjects[1])
+	defer p1.Delete()
+
+	p2 := new(model.Project)
+	createTestProject(u1, t1, p2, fixtureProjects[1])
+	defer p2.Delete()
+
+	tokensURL := "/users/" + u1.ID.String() + "/personal_tokens"
+
+	createPersonalToken := func(token *model.Token, data interface{}, body interface{}) int {
+		r := request(&requestOptions{
+			Method: "POST",
+			URL:    tokensURL,
+			Body:   body,
+			Headers: map[string]string{
+				"Authorization": "Bearer " + token.Secret.String(),
+			},
+		})
+
+		if err := parseJSON(r.Body, data); err != nil {
+			log.Fatal(err)
+		}
+
+		return r.Code
+	}
+
+	Convey("Create, use and delete a personal token", t, func() {
+		token := new(model.Token)
+		code := createPersonalToken(t1, token, map[string]interface{}{
+			"name":       "CI",
+			"scopes":     []string{"read"},
+			"project_id": p1.ID.String(),
+		})
+
+		So(code, ShouldEqual, http.StatusCreated)
+		So(token.Kind, ShouldEqual, model.TokenKindPersonal)
+		So(token.Name, ShouldEqual, "CI")
+		So(token.ProjectID, ShouldResemble, p1.ID)
+		So(token.Secret.Hash, ShouldNotBeEmpty)
+
+		bearer := map[string]string{
+			"Authorization": "Bearer " + token.Secret.String(),
+		}
+
+		// The secret isn't listed
+		var list []map[string]interface{}
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    tokensURL,
+			Headers: map[string]string{
+				"Authorization": "Bearer " + t1.Secret.String(),
+			},
+		})
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		parseJSON(r.Body, &list)
+		So(list, ShouldHaveLength, 1)
+		So(list[0]["id"], ShouldEqual, token.ID.String())
+		So(list[0], ShouldNotContainKey, "secret")
+
+		// The project can be read
+		r = request(&requestOptions{
+			Method:  "GET",
+			URL:     "/projects/" + p1.ID.String(),
+			Headers: bearer,
+		})
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+
+		// But not updated
+		err := new(util.APIError)
+		r = request(&requestOptions{
+			Method:  "PUT",
+			URL:     "/projects/" + p1.ID.String(),
+			Body:    map[string]interface{}{"title": "foo"},
+			Headers: bearer,
+		})
+
+		So(r.Code, ShouldEqual, http.StatusForbidden)
+		parseJSON(r.Body, err)
+		So(err.Code, ShouldEqual, util.TokenScopeError)
+
+		// Other projects can't be read
+		r = request(&requestOptions{
+			Method:  "GET",
+			URL:     "/projects/" + p2.ID.String(),
+			Headers: bearer,
+		})
+
+		So(r.Code, ShouldEqual, http.StatusForbidden)
+
+		// Personal tokens can't manage tokens
+		err = new(util.APIError)
+		So(createPersonalToken(token, err, map[string]interface{}{
+			"name":   "Another",
+			"scopes": []string{"all"},
+		}), ShouldEqual, http.StatusForbidden)
+		So(err.Code, ShouldEqual, util.TokenScopeError)
+
+		r = request(&requestOptions{
+			Method: "DELETE",
+			URL:    "/tokens/" + token.ID.String(),
+			Headers: map[string]string{
+				"Authorization": "Bearer " + t1.Secret.String(),
+			},
+		})
+
+		So(r.Code, ShouldEqual, http.StatusNoContent)
+
+		r = request(&requestOptions{
+			Method:  "GET",
+			URL:     "/projects/" + p1.ID.String(),
+			Headers: bearer,
+		})
+
+		So(r.Code, ShouldEqual, http.StatusUnauthorized)
+	})
+
+	Convey("Scopes are required", t, func() {
+		err := new(util.APIError)
+		code := createPersonalToken(t1, err, map[string]interface{}{
+			"name": "CI",
+		})
+
+		So(code, ShouldEqual, http.StatusBadRequest)
+		So(err, ShouldResemble, &util.APIError{
+			Field:   "scopes",
+			Code:    util.RequiredError,
+			Message: "Scopes are required.",
+		})
+	})
+
+	Convey("Expiry time must be in the future", t, func() {
+		err := new(util.APIError)
+		code := createPersonalToken(t1, err, map[string]interface{}{
+			"name":       "CI",
+			"scopes":     []string{"read"},
+			"expires_at": "2015-01-01T00:00:00Z",
+		})
+
+		So(code, ShouldEqual, http.StatusBadRequest)
+		So(err, ShouldResemble, &util.APIError{
+			Field:   "expires_at",
+			Code:    util.ValueError,
+			Message: "Expiry time must be in the future.",
+		})
+	})
+
+	Convey("Personal tokens of others", t, func() {
+		err := new(util.APIError)
+		code := createPersonalToken(t2, err, map[string]interface{}{
+			"name":   "CI",
+			"scopes": []string{"read"},
+		})
+
+		So(code, ShouldEqual, http.StatusForbidden)
+		So(err.Code, ShouldEqual, util.UserForbiddenError)
+	})
+}
@@ -84,7 +84,18 @@ func UserShow(c *gin.Context) error {
 		return err
 	}
 
+	if hasInclude(c, "stats") {
+		if user.Stats, err = model.GetUserStats(user.ID); err != nil {
+			return err
+		}
+	}
+
+	// The usage is only shown to the user
 	if err := CheckUserPermission(c, user.ID); err == nil {
+		if user.Usage, err = model.GetUserUsage(user.ID); err != nil {
+			return err
+		}
+
 		return common.APIResponse(c, http.StatusOK, user)
 	}
 
@@ -123,6 +134,10 @@ func UserUpdate(c *gin.Context) error {
 		}
 
 		if err := user.Authenticate(*form.OldPassword); err != nil {
+			if e, ok := err.(*util.APIError); ok && e.Code == util.UserLockedError {
+				return err
+			}
+
 			return &util.APIError{
 				Field:   "old_password",
 				Code:    util.WrongPasswordError,
@@ -143,6 +158,13 @@ func UserUpdate(c *gin.Context) error {
 		return err
 	}
 
+	// Other sessions are logged out when the password is changed
+	if form.Password != nil {
+		if err := revokeOtherTokens(c, user.ID); err != nil {
+			return err
+		}
+	}
+
 	return common.APIResponse(c, http.StatusOK, user)
 }
 
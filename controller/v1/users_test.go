@@ -121,7 +121,7 @@ func TestUserShow(t *testing.T) {
 			Method: "GET",
 			URL:    "/users/" + user.ID.String(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + token.ID.String(),
+				"Authorization": "Bearer " + token.Secret.String(),
 			},
 		})
 
@@ -150,12 +150,35 @@ func TestUserShow(t *testing.T) {
 		So(u.Email, ShouldBeEmpty)
 	})
 
+	Convey("Include stats", t, func() {
+		p1 := new(model.Project)
+		createTestProject(user, token, p1, fixtureProjects[0])
+		defer p1.Delete()
+
+		p2 := new(model.Project)
+		createTestProject(user, token, p2, fixtureProjects[1])
+		defer p2.Delete()
+
+		u := new(model.User)
+		r := request(&requestOptions{
+			Method: "GET",
+			URL:    "/users/" + user.ID.String() + "?include=stats",
+		})
+
+		So(r.Code, ShouldEqual, http.StatusOK)
+		parseJSON(r.Body, u)
+		So(u.Stats, ShouldResemble, &model.UserStats{
+			TotalProjectCount:  2,
+			PublicProjectCount: 1,
+		})
+	})
+
 	Convey("User not found", t, func() {
 		r := request(&requestOptions{
 			Method: "GET",
 			URL:    "/users/" + uuid.New(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + token.ID.String(),
+				"Authorization": "Bearer " + token.Secret.String(),
 			},
 		})
 
@@ -193,7 +216,7 @@ func TestUserUpdate(t *testing.T) {
 			Method: "PUT",
 			URL:    "/users/" + user.ID.String(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + token.ID.String(),
+				"Authorization": "Bearer " + token.Secret.String(),
 			},
 			Body: map[string]interface{}{},
 		})
@@ -220,7 +243,7 @@ func TestUserUpdate(t *testing.T) {
 			Method: "PUT",
 			URL:    "/users/" + user.ID.String(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + token.ID.String(),
+				"Authorization": "Bearer " + token.Secret.String(),
 			},
 			Body: map[string]interface{}{
 				"name": newName,
@@ -242,7 +265,7 @@ func TestUserUpdate(t *testing.T) {
 			Method: "PUT",
 			URL:    "/users/" + user.ID.String(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + token.ID.String(),
+				"Authorization": "Bearer " + token.Secret.String(),
 			},
 			Body: map[string]interface{}{
 				"email": newEmail,
@@ -264,7 +287,7 @@ func TestUserUpdate(t *testing.T) {
 			Method: "PUT",
 			URL:    "/users/" + user.ID.String(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + token.ID.String(),
+				"Authorization": "Bearer " + token.Secret.String(),
 			},
 			Body: map[string]interface{}{
 				"password":     newPassword,
@@ -287,7 +310,7 @@ func TestUserUpdate(t *testing.T) {
 			Method: "PUT",
 			URL:    "/users/" + user.ID.String(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + token.ID.String(),
+				"Authorization": "Bearer " + token.Secret.String(),
 			},
 			Body: map[string]interface{}{
 				"password": fixtureUsers[0].Password,
@@ -309,7 +332,7 @@ func TestUserUpdate(t *testing.T) {
 			Method: "PUT",
 			URL:    "/users/" + user.ID.String(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + token.ID.String(),
+				"Authorization": "Bearer " + token.Secret.String(),
 			},
 			Body: map[string]interface{}{
 				"password":     fixtureUsers[0].Password,
@@ -332,7 +355,7 @@ func TestUserUpdate(t *testing.T) {
 			Method: "PUT",
 			URL:    "/users/" + user.ID.String(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + token2.ID.String(),
+				"Authorization": "Bearer " + token2.Secret.String(),
 			},
 			Body: map[string]interface{}{},
 		})
@@ -351,7 +374,7 @@ func TestUserUpdate(t *testing.T) {
 			Method: "PUT",
 			URL:    "/users/" + uuid.New(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + token.ID.String(),
+				"Authorization": "Bearer " + token.Secret.String(),
 			},
 			Body: map[string]interface{}{},
 		})
@@ -387,7 +410,7 @@ func TestUserDestroy(t *testing.T) {
 			Method: "DELETE",
 			URL:    "/users/" + user.ID.String(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + token.ID.String(),
+				"Authorization": "Bearer " + token.Secret.String(),
 			},
 		})
 
@@ -406,7 +429,7 @@ func TestUserDestroy(t *testing.T) {
 			Method: "DELETE",
 			URL:    "/users/" + user.ID.String(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + token2.ID.String(),
+				"Authorization": "Bearer " + token2.Secret.String(),
 			},
 		})
 
@@ -424,7 +447,7 @@ func TestUserDestroy(t *testing.T) {
 			Method: "DELETE",
 			URL:    "/users/" + uuid.New(),
 			Headers: map[string]string{
-				"Authorization": "Bearer " + token.ID.String(),
+				"Authorization": "Bearer " + token.Secret.String(),
 			},
 		})
 
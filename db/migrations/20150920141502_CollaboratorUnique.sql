@@ -0,0 +1,7 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE collaborators ADD CONSTRAINT collaborators_project_id_user_id_key UNIQUE (project_id, user_id);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE collaborators DROP CONSTRAINT IF EXISTS collaborators_project_id_user_id_key;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS element_assets (
+	element_id UUID NOT NULL REFERENCES elements(id) ON DELETE CASCADE ON UPDATE CASCADE,
+	asset_id UUID NOT NULL REFERENCES assets(id) ON DELETE CASCADE ON UPDATE CASCADE,
+	field VARCHAR(255) NOT NULL,
+	PRIMARY KEY (element_id, field)
+);
+
+CREATE INDEX element_assets_asset_id_idx ON element_assets (asset_id);
+
+-- Record existing references
+INSERT INTO element_assets (element_id, asset_id, field)
+SELECT elements.id, assets.id, 'attributes.asset'
+FROM elements
+JOIN assets ON assets.project_id = elements.project_id AND assets.id::text = lower(elements.attributes->>'asset')
+WHERE elements.type = 'image';
+
+INSERT INTO element_assets (element_id, asset_id, field)
+SELECT elements.id, assets.id, 'styles.background_image'
+FROM elements
+JOIN assets ON assets.project_id = elements.project_id AND assets.id::text = lower(elements.styles->>'background_image');
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS element_assets;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE INDEX projects_search_idx ON projects USING gin(to_tsvector('simple', title || ' ' || coalesce(description, '')));
+CREATE INDEX projects_is_private_created_at_idx ON projects (is_private, created_at);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX IF EXISTS projects_search_idx;
+DROP INDEX IF EXISTS projects_is_private_created_at_idx;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- Columns are listed explicitly, so the view won't block changes of the users table
+DROP VIEW IF EXISTS users_extended;
+CREATE VIEW users_extended AS
+	SELECT
+		users.id,
+		(SELECT count(id) FROM projects WHERE user_id = users.id) AS total_project_count,
+		(SELECT count(id) FROM projects WHERE user_id = users.id AND is_private = FALSE) AS public_project_count
+	FROM users;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP VIEW IF EXISTS users_extended;
+CREATE VIEW users_extended AS
+	SELECT
+		users.*,
+		(SELECT count(id) FROM projects WHERE user_id = users.id) AS total_project_count,
+		(SELECT count(id) FROM projects WHERE user_id = users.id AND is_private = FALSE) as public_project_count
+	FROM users;
@@ -0,0 +1,25 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+ALTER TABLE tokens ADD refresh_secret CHAR(64);
+ALTER TABLE tokens ADD expires_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE tokens ADD refresh_expires_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE tokens ADD last_used_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- Existing tokens get the default lifetimes from now
+UPDATE tokens SET
+	refresh_secret = encode(gen_random_bytes(32), 'hex'),
+	expires_at = now() + interval '1 hour',
+	refresh_expires_at = now() + interval '30 days';
+
+ALTER TABLE tokens ALTER refresh_secret SET NOT NULL;
+ALTER TABLE tokens ADD UNIQUE (refresh_secret);
+ALTER TABLE tokens ALTER expires_at SET NOT NULL;
+ALTER TABLE tokens ALTER refresh_expires_at SET NOT NULL;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE tokens DROP COLUMN last_used_at;
+ALTER TABLE tokens DROP COLUMN refresh_expires_at;
+ALTER TABLE tokens DROP COLUMN expires_at;
+ALTER TABLE tokens DROP COLUMN refresh_secret;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE tokens ADD ip VARCHAR(45) NOT NULL DEFAULT '';
+ALTER TABLE tokens ADD user_agent TEXT NOT NULL DEFAULT '';
+CREATE INDEX tokens_user_id_idx ON tokens (user_id);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX IF EXISTS tokens_user_id_idx;
+ALTER TABLE tokens DROP COLUMN user_agent;
+ALTER TABLE tokens DROP COLUMN ip;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE tokens ADD kind VARCHAR(20) NOT NULL DEFAULT 'session';
+ALTER TABLE tokens ADD name VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE tokens ADD scopes VARCHAR(255) NOT NULL DEFAULT 'all';
+ALTER TABLE tokens ADD project_id UUID REFERENCES projects(id) ON DELETE CASCADE ON UPDATE CASCADE;
+ALTER TABLE tokens ALTER COLUMN expires_at DROP NOT NULL;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DELETE FROM tokens WHERE expires_at IS NULL;
+ALTER TABLE tokens ALTER COLUMN expires_at SET NOT NULL;
+ALTER TABLE tokens DROP COLUMN project_id;
+ALTER TABLE tokens DROP COLUMN scopes;
+ALTER TABLE tokens DROP COLUMN name;
+ALTER TABLE tokens DROP COLUMN kind;
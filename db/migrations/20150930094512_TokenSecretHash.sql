@@ -0,0 +1,15 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+ALTER TABLE tokens RENAME secret TO secret_hash;
+ALTER TABLE tokens RENAME refresh_secret TO refresh_secret_hash;
+UPDATE tokens SET
+	secret_hash = encode(digest(decode(secret_hash, 'hex'), 'sha256'), 'hex'),
+	refresh_secret_hash = encode(digest(decode(refresh_secret_hash, 'hex'), 'sha256'), 'hex');
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+-- Hashed secrets can't be restored
+DELETE FROM tokens;
+ALTER TABLE tokens RENAME refresh_secret_hash TO refresh_secret;
+ALTER TABLE tokens RENAME secret_hash TO secret;
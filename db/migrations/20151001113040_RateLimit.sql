@@ -0,0 +1,16 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS rate_limits (
+	key VARCHAR(255) NOT NULL PRIMARY KEY,
+	tokens DOUBLE PRECISION NOT NULL,
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+ALTER TABLE users ADD failed_login_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD locked_until TIMESTAMP WITH TIME ZONE;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE users DROP COLUMN locked_until;
+ALTER TABLE users DROP COLUMN failed_login_count;
+DROP TABLE IF EXISTS rate_limits;
@@ -1,11 +1,18 @@
 package model
 
 import (
+	"bytes"
 	"database/sql"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
+	"time"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/jinzhu/gorm"
@@ -13,6 +20,11 @@ import (
 	"github.com/tkusd/server/util"
 )
 
+const (
+	thumbDir     = "thumbs"
+	thumbQuality = 90
+)
+
 var (
 	rAssetBase = regexp.MustCompile(`^(.+?)(?: *\((\d+)\))?$`)
 )
@@ -103,10 +115,15 @@ func (asset *Asset) BeforeSave(tx *gorm.DB) error {
 		asset.Name = base + ext
 	}
 
+	// Keep the shared content until the asset is committed
+	if store, ok := assetStore.(*HashAssetStore); ok {
+		return store.Ref(tx, asset)
+	}
+
 	return nil
 }
 
-func (asset *Asset) Save() error {
+func (asset *Asset) validate() error {
 	asset.Name = govalidator.Trim(asset.Name, "")
 
 	if len(asset.Name) > 255 {
@@ -117,32 +134,320 @@ func (asset *Asset) Save() error {
 		}
 	}
 
-	return db.Save(asset).Error
+	return nil
+}
+
+func (asset *Asset) Save() error {
+	return asset.SaveIf(nil)
+}
+
+// SaveIf is like Save but the precondition is checked before updating.
+func (asset *Asset) SaveIf(pre Precondition) error {
+	if err := asset.validate(); err != nil {
+		return err
+	}
+
+	action := saveAction(asset.ID)
+	tx := db.Begin()
+
+	if err := checkPrecondition(tx, "assets", asset.ID, pre); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Save(asset).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Commit the transaction
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	publishChange(asset.ProjectID, action, ChangeTypeAsset, asset.ID, *asset)
+	return nil
 }
 
 func (asset *Asset) Delete() error {
-	if err := asset.DeleteAsset(); err != nil {
+	return asset.DeleteIf(nil)
+}
+
+// DeleteIf is like Delete but the precondition is checked before deleting.
+// References to the asset in elements are removed in the same transaction. The
+// content is deleted after the record is deleted.
+func (asset *Asset) DeleteIf(pre Precondition) error {
+	tx := db.Begin()
+
+	if err := checkPrecondition(tx, "assets", asset.ID, pre); err != nil {
+		tx.Rollback()
 		return err
 	}
 
-	return db.Delete(asset).Error
+	elements, err := clearAssetRefs(tx, asset.ID)
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if len(elements) > 0 {
+		if err := recordRevision(tx, asset.ProjectID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Delete(asset).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Commit the transaction
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	for _, e := range elements {
+		publishChange(e.ProjectID, ChangeUpdate, ChangeTypeElement, e.ID, *e)
+	}
+
+	publishChange(asset.ProjectID, ChangeDelete, ChangeTypeAsset, asset.ID, nil)
+
+	return asset.DeleteAsset()
 }
 
+// DeleteAsset deletes the content of the asset from the asset store.
 func (asset *Asset) DeleteAsset() error {
-	path := util.GetAssetFilePath(asset.Slug)
-
 	// File does not exist. Skip deletion
-	if !util.IsAssetExist(asset.Slug) {
+	if !assetStore.Exists(asset) {
 		return nil
 	}
 
-	return os.Remove(path)
+	if err := assetStore.Delete(asset); err != nil {
+		return err
+	}
+
+	// The content may be kept for other assets
+	if !assetStore.Exists(asset) {
+		asset.deleteThumbnails()
+	}
+
+	return nil
+}
+
+// DeleteUnsavedAssets deletes the content written for assets which are not
+// saved in the database, e.g. when a transaction is rolled back. IDs of the
+// assets are cleared, so the asset store won't mistake them for saved ones.
+func DeleteUnsavedAssets(assets []*Asset) {
+	for _, asset := range assets {
+		asset.ID = types.UUID{}
+		asset.DeleteAsset()
+	}
+}
+
+// WriteAsset writes the content of the asset to the asset store.
+func (asset *Asset) WriteAsset(r io.Reader) error {
+	return assetStore.Put(asset, r)
+}
+
+// OpenAsset opens the content of the asset from the asset store.
+func (asset *Asset) OpenAsset() (AssetFile, error) {
+	return assetStore.Get(asset)
+}
+
+// IsImage returns true if the asset is an image which can be resized.
+func (asset *Asset) IsImage() bool {
+	switch asset.Type {
+	case "image/png", "image/jpeg", "image/gif":
+		return true
+	}
+
+	return false
+}
+
+func (asset *Asset) thumbnailPath(size int) string {
+	name := asset.Hash.String() + filepath.Ext(asset.Slug)
+	return util.GetAssetFilePath(filepath.Join(thumbDir, strconv.Itoa(size), name))
+}
+
+func (asset *Asset) deleteThumbnails() {
+	name := asset.Hash.String() + filepath.Ext(asset.Slug)
+	matches, _ := filepath.Glob(util.GetAssetFilePath(filepath.Join(thumbDir, "*", name)))
+
+	for _, path := range matches {
+		os.Remove(path)
+	}
+}
+
+// Thumbnail opens a thumbnail which fits in a square of the given size.
+// Thumbnails are generated on the first request and cached on the local disk.
+// The original file is returned if it's small enough.
+func (asset *Asset) Thumbnail(size int) (AssetFile, error) {
+	if asset.Width <= size && asset.Height <= size {
+		return asset.OpenAsset()
+	}
+
+	path := asset.thumbnailPath(size)
+
+	// Use the cached thumbnail
+	if file, err := os.Open(path); err == nil {
+		return file, nil
+	}
+
+	src, err := asset.OpenAsset()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer src.Close()
+
+	img, format, err := image.Decode(src)
+
+	if err != nil {
+		return nil, err
+	}
+
+	thumb := util.ResizeImage(img, size)
+	buf := new(bytes.Buffer)
+
+	switch format {
+	case "png":
+		err = png.Encode(buf, thumb)
+	case "jpeg":
+		err = jpeg.Encode(buf, thumb, &jpeg.Options{Quality: thumbQuality})
+	case "gif":
+		err = gif.Encode(buf, thumb, nil)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeFile(path, buf); err != nil {
+		return nil, err
+	}
+
+	return os.Open(path)
 }
 
 func (asset *Asset) Exists() bool {
 	return exists("assets", asset.ID.String())
 }
 
+// AssetCollection is a page of assets.
+type AssetCollection struct {
+	Data       []*Asset `json:"data"`
+	HasMore    bool     `json:"has_more"`
+	Count      *int     `json:"count,omitempty"`
+	Limit      int      `json:"limit"`
+	Offset     int      `json:"offset"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+	PrevCursor string   `json:"prev_cursor,omitempty"`
+	Next       string   `json:"next,omitempty"`
+	Prev       string   `json:"prev,omitempty"`
+}
+
+// AssetQueryOption is the query options for assets.
+type AssetQueryOption struct {
+	QueryOption
+	ProjectID types.UUID
+}
+
+// assetCursorColumns are the columns which assets can be sorted by in cursor
+// mode.
+var assetCursorColumns = []string{"created_at", "updated_at", "name", "size"}
+
+type assetList []*Asset
+
+func (list assetList) Len() int {
+	return len(list)
+}
+
+func (list assetList) Swap(i, j int) {
+	list[i], list[j] = list[j], list[i]
+}
+
+func (list *assetList) Truncate(n int) {
+	*list = (*list)[:n]
+}
+
+func (list assetList) CursorKey(i int, column string) (string, types.UUID) {
+	asset := list[i]
+
+	switch column {
+	case "created_at":
+		return asset.CreatedAt.Format(time.RFC3339Nano), asset.ID
+	case "updated_at":
+		return asset.UpdatedAt.Format(time.RFC3339Nano), asset.ID
+	case "size":
+		return strconv.FormatInt(asset.Size, 10), asset.ID
+	}
+
+	return asset.Name, asset.ID
+}
+
+// GetAssetCollection gets a page of assets of a project.
+func GetAssetCollection(option *AssetQueryOption) (*AssetCollection, error) {
+	var count int
+	var list assetList
+
+	if option.Limit == 0 || option.Limit > maxLimit {
+		option.Limit = defaultLimit
+	}
+
+	if option.Order == "" {
+		option.Order = "created_at"
+	}
+
+	order, err := AssetFields.ParseOrder(option.Order, "assets")
+
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := newPage(&option.QueryOption, assetCursorColumns)
+
+	if err != nil {
+		return nil, err
+	}
+
+	scope := db.Table("assets").Where("assets.project_id = ?", option.ProjectID.String())
+
+	// Count is skipped in cursor mode
+	if !page.isCursor() {
+		if err := scope.Count(&count).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if err := page.apply(scope, "assets", order).Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	prev, next, hasMore := page.finish(&list, count)
+
+	if list == nil {
+		list = make([]*Asset, 0)
+	}
+
+	result := &AssetCollection{
+		Data:       list,
+		Limit:      option.Limit,
+		Offset:     option.Offset,
+		HasMore:    hasMore,
+		NextCursor: next,
+		PrevCursor: prev,
+	}
+
+	if !page.isCursor() {
+		result.Count = &count
+	}
+
+	return result, nil
+}
+
 func GetAssetList(projectID types.UUID) ([]*Asset, error) {
 	var assets []*Asset
 
@@ -157,6 +462,26 @@ func GetAssetList(projectID types.UUID) ([]*Asset, error) {
 	return assets, nil
 }
 
+// GetAssetListByIDs gets the assets of a project in the given IDs.
+func GetAssetListByIDs(projectID types.UUID, ids []types.UUID) ([]*Asset, error) {
+	var assets []*Asset
+	var idList []string
+
+	for _, id := range ids {
+		idList = append(idList, id.String())
+	}
+
+	if err := db.Where("project_id = ? AND id IN (?)", projectID.String(), idList).Order("created_at").Find(&assets).Error; err != nil {
+		return nil, err
+	}
+
+	if assets == nil {
+		assets = make([]*Asset, 0)
+	}
+
+	return assets, nil
+}
+
 func GetAsset(id types.UUID) (*Asset, error) {
 	asset := new(Asset)
 
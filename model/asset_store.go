@@ -0,0 +1,282 @@
+package model
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/jinzhu/gorm"
+	"github.com/tkusd/server/config"
+	"github.com/tkusd/server/model/types"
+	"github.com/tkusd/server/util"
+)
+
+const (
+	// LocalAssetStoreName is the name of LocalAssetStore in the config.
+	LocalAssetStoreName = "local"
+	// HashAssetStoreName is the name of HashAssetStore in the config.
+	HashAssetStoreName = "hash"
+)
+
+// AssetFile is the content of an asset opened for reading.
+type AssetFile interface {
+	io.Reader
+	io.Seeker
+	io.Closer
+}
+
+// AssetStore stores the content of assets.
+type AssetStore interface {
+	// Put writes the content of the asset.
+	Put(asset *Asset, r io.Reader) error
+
+	// Get opens the content of the asset.
+	Get(asset *Asset) (AssetFile, error)
+
+	// Delete deletes the content of the asset.
+	Delete(asset *Asset) error
+
+	// Stat returns the file info of the content.
+	Stat(asset *Asset) (os.FileInfo, error)
+
+	// Exists returns true if the content of the asset exists.
+	Exists(asset *Asset) bool
+}
+
+var assetStore AssetStore
+
+func init() {
+	dir := util.GetAssetFilePath("")
+
+	switch config.Config.AssetStore {
+	case HashAssetStoreName:
+		assetStore = &HashAssetStore{Dir: dir}
+	default:
+		assetStore = &LocalAssetStore{Dir: dir}
+	}
+}
+
+// SetAssetStore replaces the asset store.
+func SetAssetStore(store AssetStore) {
+	assetStore = store
+}
+
+// writeFile writes to a temporary file and renames it to the path when
+// completed, so others won't read an incomplete file.
+func writeFile(path string, r io.Reader) error {
+	dir, name := filepath.Split(path)
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+name)
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, r)
+
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// LocalAssetStore stores each asset as a file named after its slug.
+type LocalAssetStore struct {
+	Dir string
+}
+
+func (s *LocalAssetStore) path(asset *Asset) string {
+	return filepath.Join(s.Dir, asset.Slug)
+}
+
+// Put implements the AssetStore interface.
+func (s *LocalAssetStore) Put(asset *Asset, r io.Reader) error {
+	return writeFile(s.path(asset), r)
+}
+
+// Get implements the AssetStore interface.
+func (s *LocalAssetStore) Get(asset *Asset) (AssetFile, error) {
+	return os.Open(s.path(asset))
+}
+
+// Delete implements the AssetStore interface.
+func (s *LocalAssetStore) Delete(asset *Asset) error {
+	return os.Remove(s.path(asset))
+}
+
+// Stat implements the AssetStore interface.
+func (s *LocalAssetStore) Stat(asset *Asset) (os.FileInfo, error) {
+	return os.Stat(s.path(asset))
+}
+
+// Exists implements the AssetStore interface.
+func (s *LocalAssetStore) Exists(asset *Asset) bool {
+	if asset.Slug == "" {
+		return false
+	}
+
+	_, err := s.Stat(asset)
+	return err == nil
+}
+
+// HashAssetStore stores the content of assets by its SHA-1 hash. Identical
+// files are stored only once and a file is deleted when no assets refer to it.
+//
+// Files written by LocalAssetStore before switching to the hash store are still
+// read from their slugs, and they're deleted with the assets.
+type HashAssetStore struct {
+	Dir string
+}
+
+func (s *HashAssetStore) path(asset *Asset) string {
+	hash := asset.Hash.String()
+
+	if len(hash) < 2 {
+		return filepath.Join(s.Dir, hash)
+	}
+
+	return filepath.Join(s.Dir, hash[:2], hash[2:])
+}
+
+func (s *HashAssetStore) legacyPath(asset *Asset) string {
+	return filepath.Join(s.Dir, asset.Slug)
+}
+
+// find returns the path of the file named after the hash, or the file named
+// after the slug if it doesn't exist.
+func (s *HashAssetStore) find(asset *Asset) string {
+	if len(asset.Hash) > 0 {
+		path := s.path(asset)
+
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+
+	return s.legacyPath(asset)
+}
+
+// lockHash locks the hash until the transaction ends. Assets are saved with a
+// shared lock and files are deleted with an exclusive lock.
+func lockHash(tx *gorm.DB, hash types.Hash, shared bool) error {
+	fn := "pg_advisory_xact_lock"
+
+	if shared {
+		fn += "_shared"
+	}
+
+	return tx.Exec("SELECT "+fn+"(hashtext(?))", hash.String()).Error
+}
+
+// Ref is called in the transaction which saves the asset. The hash is locked
+// until the transaction ends, so the file won't be deleted before the asset is
+// committed. It fails if the file was deleted after it's written.
+func (s *HashAssetStore) Ref(tx *gorm.DB, asset *Asset) error {
+	if len(asset.Hash) == 0 {
+		return nil
+	}
+
+	if err := lockHash(tx, asset.Hash, true); err != nil {
+		return err
+	}
+
+	if !s.Exists(asset) {
+		return errors.New("content of the asset was deleted before it's saved")
+	}
+
+	return nil
+}
+
+// Put implements the AssetStore interface.
+func (s *HashAssetStore) Put(asset *Asset, r io.Reader) error {
+	if _, err := os.Stat(s.path(asset)); err == nil {
+		return nil
+	}
+
+	return writeFile(s.path(asset), r)
+}
+
+// Get implements the AssetStore interface.
+func (s *HashAssetStore) Get(asset *Asset) (AssetFile, error) {
+	return os.Open(s.find(asset))
+}
+
+// Delete implements the AssetStore interface. The file is kept if it's still
+// referred by other assets. Assets are counted under the lock of the hash, so
+// it won't race with assets being saved.
+func (s *HashAssetStore) Delete(asset *Asset) error {
+	if asset.Slug != "" {
+		if err := removeFile(s.legacyPath(asset)); err != nil {
+			return err
+		}
+	}
+
+	if len(asset.Hash) == 0 {
+		return nil
+	}
+
+	tx := db.Begin()
+
+	if err := lockHash(tx, asset.Hash, false); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var count int
+	scope := tx.Table("assets").Where("hash = ?", asset.Hash.String())
+
+	if asset.ID.Valid() {
+		scope = scope.Not("id", asset.ID.String())
+	}
+
+	if err := scope.Count(&count).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if count == 0 {
+		if err := removeFile(s.path(asset)); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// Stat implements the AssetStore interface.
+func (s *HashAssetStore) Stat(asset *Asset) (os.FileInfo, error) {
+	return os.Stat(s.find(asset))
+}
+
+// Exists implements the AssetStore interface.
+func (s *HashAssetStore) Exists(asset *Asset) bool {
+	if len(asset.Hash) == 0 && asset.Slug == "" {
+		return false
+	}
+
+	_, err := s.Stat(asset)
+	return err == nil
+}
+
+// removeFile removes the file. It doesn't fail if the file doesn't exist.
+func removeFile(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHashAssetStore(t *testing.T) {
+	user, err := createTestUser(fixtureUsers[0])
+	defer user.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	project, err := createTestProject(user)
+	defer project.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "assets")
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	store := &HashAssetStore{Dir: dir}
+	original := assetStore
+	SetAssetStore(store)
+	defer SetAssetStore(original)
+
+	content := []byte("content")
+	hash := sha1.Sum(content)
+
+	newAsset := func(slug string) *Asset {
+		return &Asset{Name: "a.txt", ProjectID: project.ID, Slug: slug, Hash: hash[:]}
+	}
+
+	Convey("Identical content is stored once", t, func() {
+		a := newAsset("a.txt")
+		b := newAsset("b.txt")
+
+		So(a.WriteAsset(bytes.NewReader(content)), ShouldBeNil)
+		So(b.WriteAsset(bytes.NewReader(content)), ShouldBeNil)
+		So(a.Save(), ShouldBeNil)
+		So(b.Save(), ShouldBeNil)
+
+		Convey("Content is kept while other assets refer to it", func() {
+			So(a.Delete(), ShouldBeNil)
+			So(store.Exists(b), ShouldBeTrue)
+
+			So(b.Delete(), ShouldBeNil)
+			So(store.Exists(b), ShouldBeFalse)
+		})
+	})
+
+	Convey("Assets can't be saved after the content is deleted", t, func() {
+		a := newAsset("a.txt")
+
+		So(a.WriteAsset(bytes.NewReader(content)), ShouldBeNil)
+		DeleteUnsavedAssets([]*Asset{newAsset("b.txt")})
+		So(a.Save(), ShouldNotBeNil)
+	})
+
+	Convey("Files written before switching to the hash store", t, func() {
+		a := newAsset("legacy.txt")
+		legacy := filepath.Join(dir, a.Slug)
+
+		So(ioutil.WriteFile(legacy, content, os.ModePerm), ShouldBeNil)
+		So(a.Save(), ShouldBeNil)
+
+		f, err := a.OpenAsset()
+		So(err, ShouldBeNil)
+
+		data, _ := ioutil.ReadAll(f)
+		f.Close()
+		So(data, ShouldResemble, content)
+
+		So(a.Delete(), ShouldBeNil)
+		_, err = os.Stat(legacy)
+		So(os.IsNotExist(err), ShouldBeTrue)
+	})
+}
@@ -0,0 +1,553 @@
+package model
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/jinzhu/gorm"
+	"github.com/tkusd/server/model/types"
+	"github.com/tkusd/server/util"
+)
+
+// Operations of batch.
+const (
+	BatchCreate  = "create"
+	BatchUpdate  = "update"
+	BatchDelete  = "delete"
+	BatchReorder = "reorder"
+)
+
+// MaxBatchOperations is the maximum number of operations in a batch.
+const MaxBatchOperations = 100
+
+// BatchOperation is an operation on an element or an event in a batch. IDs
+// can be temporary IDs of records created in the same batch.
+type BatchOperation struct {
+	Op   string          `json:"op"`
+	Type string          `json:"type"`
+	ID   string          `json:"id"`
+	Data json.RawMessage `json:"data"`
+}
+
+// BatchResult is the result of a batch operation.
+type BatchResult struct {
+	Op   string      `json:"op"`
+	Type string      `json:"type"`
+	ID   types.UUID  `json:"id"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+type batchElementData struct {
+	ElementID  *string           `json:"element_id"`
+	Name       *string           `json:"name"`
+	Type       *string           `json:"type"`
+	Attributes *types.JSONObject `json:"attributes"`
+	Styles     *types.JSONObject `json:"styles"`
+	IsVisible  *bool             `json:"is_visible"`
+	Elements   []string          `json:"elements"`
+}
+
+type batchEventData struct {
+	ElementID *string `json:"element_id"`
+	Event     *string `json:"event"`
+	Workspace *string `json:"workspace"`
+}
+
+type batch struct {
+	tx        *gorm.DB
+	projectID types.UUID
+	ids       map[string]types.UUID
+
+	// elementCount is the number of elements in the project. It's counted
+	// before the first element is created and reset when elements are deleted.
+	elementCount int
+	counted      bool
+}
+
+// resolve returns the UUID of the ID. Temporary IDs are replaced with the IDs
+// of created elements.
+func (b *batch) resolve(id string, field string) (types.UUID, error) {
+	if uid, ok := b.ids[id]; ok {
+		return uid, nil
+	}
+
+	uid := types.ParseUUID(id)
+
+	if !uid.Valid() {
+		return uid, &util.APIError{
+			Field:   field,
+			Code:    util.UUIDError,
+			Message: "UUID is invalid.",
+		}
+	}
+
+	return uid, nil
+}
+
+func (b *batch) decode(op *BatchOperation, v interface{}) error {
+	if len(op.Data) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(op.Data, v); err != nil {
+		return &util.APIError{
+			Field:   "data",
+			Code:    util.DeserializationError,
+			Message: err.Error(),
+		}
+	}
+
+	return nil
+}
+
+func (b *batch) getElement(id types.UUID) (*Element, error) {
+	e := new(Element)
+
+	err := b.tx.Where("id = ? AND project_id = ?", id.String(), b.projectID.String()).First(e).Error
+
+	if err == gorm.RecordNotFound {
+		return nil, &util.APIError{
+			Field:   "id",
+			Code:    util.ElementNotFoundError,
+			Message: "Element not found.",
+		}
+	}
+
+	return e, err
+}
+
+func (b *batch) getEvent(id types.UUID) (*Event, error) {
+	event := new(Event)
+
+	err := b.tx.Where("id = ? AND element_id IN (SELECT id FROM elements WHERE project_id = ?)", id.String(), b.projectID.String()).
+		First(event).
+		Error
+
+	if err == gorm.RecordNotFound {
+		return nil, &util.APIError{
+			Field:   "id",
+			Code:    util.EventNotFound,
+			Message: "Event not found.",
+		}
+	}
+
+	return event, err
+}
+
+func (b *batch) createElement(op *BatchOperation) (*Element, error) {
+	var data batchElementData
+
+	if err := b.decode(op, &data); err != nil {
+		return nil, err
+	}
+
+	if err := b.checkElementQuota(); err != nil {
+		return nil, err
+	}
+
+	e := &Element{
+		ID:        types.NewRandomUUID(),
+		ProjectID: b.projectID,
+		IsVisible: true,
+	}
+
+	if data.ElementID != nil {
+		parentID, err := b.resolve(*data.ElementID, "data.element_id")
+
+		if err != nil {
+			return nil, err
+		}
+
+		e.ElementID = parentID
+	}
+
+	if err := b.updateElement(e, &data, true); err != nil {
+		return nil, err
+	}
+
+	if op.ID != "" {
+		b.ids[op.ID] = e.ID
+	}
+
+	return e, nil
+}
+
+// checkElementQuota checks whether one more element can be created and counts
+// it.
+func (b *batch) checkElementQuota() error {
+	if !b.counted {
+		count, err := countElements(b.tx, b.projectID)
+
+		if err != nil {
+			return err
+		}
+
+		b.elementCount = count
+		b.counted = true
+	}
+
+	if err := checkElementCount(b.elementCount + 1); err != nil {
+		return err
+	}
+
+	b.elementCount++
+	return nil
+}
+
+func (b *batch) updateElement(e *Element, data *batchElementData, create bool) error {
+	var prev *Element
+
+	if !create {
+		stored := *e
+		prev = &stored
+	}
+
+	if data.Name != nil {
+		e.Name = *data.Name
+	}
+
+	if data.Type != nil {
+		e.Type = *data.Type
+	}
+
+	if data.Attributes != nil {
+		e.Attributes = *data.Attributes
+	}
+
+	if data.Styles != nil {
+		e.Styles = *data.Styles
+	}
+
+	if data.IsVisible != nil {
+		e.IsVisible = *data.IsVisible
+	}
+
+	if err := e.validate(prev); err != nil {
+		return err
+	}
+
+	if err := checkElementTree(b.tx, e); err != nil {
+		return err
+	}
+
+	var err error
+
+	if create {
+		err = b.tx.Create(e).Error
+	} else {
+		err = b.tx.Save(e).Error
+	}
+
+	if err != nil {
+		return convertElementError(err)
+	}
+
+	return saveAssetRefs(b.tx, e)
+}
+
+func (b *batch) reorderElements(op *BatchOperation) (types.UUID, []types.UUID, error) {
+	var data batchElementData
+	var parentID types.UUID
+	option := &ElementQueryOption{ProjectID: &b.projectID}
+
+	if err := b.decode(op, &data); err != nil {
+		return parentID, nil, err
+	}
+
+	if op.ID != "" {
+		id, err := b.resolve(op.ID, "id")
+
+		if err != nil {
+			return parentID, nil, err
+		}
+
+		parentID = id
+		option.ElementID = &parentID
+	}
+
+	elements := make([]types.UUID, len(data.Elements))
+
+	for i, s := range data.Elements {
+		id, err := b.resolve(s, "data.elements")
+
+		if err != nil {
+			return parentID, nil, err
+		}
+
+		if _, err := b.getElement(id); err != nil {
+			return parentID, nil, err
+		}
+
+		elements[i] = id
+	}
+
+	return parentID, elements, updateElementOrder(b.tx, option, elements)
+}
+
+func (b *batch) saveEvent(op *BatchOperation, event *Event, create bool) error {
+	var data batchEventData
+
+	if err := b.decode(op, &data); err != nil {
+		return err
+	}
+
+	if data.ElementID != nil {
+		elementID, err := b.resolve(*data.ElementID, "data.element_id")
+
+		if err != nil {
+			return err
+		}
+
+		if _, err := b.getElement(elementID); err != nil {
+			return err
+		}
+
+		event.ElementID = elementID
+	}
+
+	if !event.ElementID.Valid() {
+		return &util.APIError{
+			Field:   "data.element_id",
+			Code:    util.RequiredError,
+			Message: "Element ID is required.",
+		}
+	}
+
+	if data.Event != nil {
+		event.Event = *data.Event
+	}
+
+	if data.Workspace != nil {
+		event.Workspace = *data.Workspace
+	}
+
+	if err := event.validate(); err != nil {
+		return err
+	}
+
+	if create {
+		return b.tx.Create(event).Error
+	}
+
+	return b.tx.Save(event).Error
+}
+
+func (b *batch) execute(op *BatchOperation) (*BatchResult, error) {
+	result := &BatchResult{Op: op.Op, Type: op.Type}
+
+	switch op.Type + ":" + op.Op {
+	case ChangeTypeElement + ":" + BatchCreate:
+		e, err := b.createElement(op)
+
+		if err != nil {
+			return nil, err
+		}
+
+		result.ID = e.ID
+		result.Data = e
+
+	case ChangeTypeElement + ":" + BatchUpdate:
+		var data batchElementData
+		id, err := b.resolve(op.ID, "id")
+
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := b.getElement(id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := b.decode(op, &data); err != nil {
+			return nil, err
+		}
+
+		if err := b.updateElement(e, &data, false); err != nil {
+			return nil, err
+		}
+
+		result.ID = e.ID
+		result.Data = e
+
+	case ChangeTypeElement + ":" + BatchDelete:
+		id, err := b.resolve(op.ID, "id")
+
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := b.getElement(id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := b.tx.Delete(e).Error; err != nil {
+			return nil, err
+		}
+
+		// Descendants are deleted too, so elements are counted again
+		b.counted = false
+		result.ID = e.ID
+
+	case ChangeTypeElement + ":" + BatchReorder:
+		parentID, elements, err := b.reorderElements(op)
+
+		if err != nil {
+			return nil, err
+		}
+
+		result.ID = parentID
+		result.Data = &ElementOrder{Elements: elements}
+
+	case ChangeTypeEvent + ":" + BatchCreate:
+		event := &Event{ID: types.NewRandomUUID()}
+
+		if err := b.saveEvent(op, event, true); err != nil {
+			return nil, err
+		}
+
+		if op.ID != "" {
+			b.ids[op.ID] = event.ID
+		}
+
+		result.ID = event.ID
+		result.Data = event
+
+	case ChangeTypeEvent + ":" + BatchUpdate:
+		id, err := b.resolve(op.ID, "id")
+
+		if err != nil {
+			return nil, err
+		}
+
+		event, err := b.getEvent(id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := b.saveEvent(op, event, false); err != nil {
+			return nil, err
+		}
+
+		result.ID = event.ID
+		result.Data = event
+
+	case ChangeTypeEvent + ":" + BatchDelete:
+		id, err := b.resolve(op.ID, "id")
+
+		if err != nil {
+			return nil, err
+		}
+
+		event, err := b.getEvent(id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := b.tx.Delete(event).Error; err != nil {
+			return nil, err
+		}
+
+		result.ID = event.ID
+
+	default:
+		return nil, &util.APIError{
+			Field:   "op",
+			Code:    util.TypeError,
+			Message: "Operation " + op.Op + " on " + op.Type + " is not supported.",
+		}
+	}
+
+	return result, nil
+}
+
+// ExecuteBatch runs the operations on elements and events of the project in
+// order in one transaction. If any operation fails, all operations are rolled
+// back and the error field is prefixed with the index of the operation, e.g.
+// "operations.2.name".
+func ExecuteBatch(projectID types.UUID, ops []*BatchOperation) ([]*BatchResult, error) {
+	if len(ops) > MaxBatchOperations {
+		return nil, &util.APIError{
+			Field:   "operations",
+			Code:    util.LengthError,
+			Message: "Maximum number of operations is " + strconv.Itoa(MaxBatchOperations) + ".",
+		}
+	}
+
+	for i, op := range ops {
+		if op == nil {
+			return nil, &util.APIError{
+				Field:   "operations." + strconv.Itoa(i),
+				Code:    util.RequiredError,
+				Message: "Operation is required.",
+			}
+		}
+	}
+
+	b := &batch{
+		tx:        db.Begin(),
+		projectID: projectID,
+		ids:       map[string]types.UUID{},
+	}
+
+	results := make([]*BatchResult, len(ops))
+
+	for i, op := range ops {
+		result, err := b.execute(op)
+
+		if err != nil {
+			b.tx.Rollback()
+
+			if e, ok := err.(*util.APIError); ok {
+				field := "operations." + strconv.Itoa(i)
+
+				if e.Field != "" {
+					field += "." + e.Field
+				}
+
+				e.Field = field
+			}
+
+			return nil, err
+		}
+
+		results[i] = result
+	}
+
+	if err := recordRevision(b.tx, projectID); err != nil {
+		b.tx.Rollback()
+		return nil, err
+	}
+
+	// Commit the transaction
+	if err := b.tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	for _, result := range results {
+		if order, ok := result.Data.(*ElementOrder); ok {
+			publishReorder(projectID, result.ID, order.Elements)
+			continue
+		}
+
+		publishChange(projectID, batchChangeAction(result.Op), result.Type, result.ID, result.Data)
+	}
+
+	return results, nil
+}
+
+func batchChangeAction(op string) string {
+	switch op {
+	case BatchCreate:
+		return ChangeCreate
+	case BatchDelete:
+		return ChangeDelete
+	}
+
+	return ChangeUpdate
+}
@@ -0,0 +1,81 @@
+package model
+
+import (
+	"encoding/json"
+	"log"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tkusd/server/model/types"
+	"github.com/tkusd/server/util"
+)
+
+func TestExecuteBatch(t *testing.T) {
+	user, err := createTestUser(fixtureUsers[0])
+	defer user.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	project, err := createTestProject(user)
+	defer project.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	Convey("Refer to temporary IDs", t, func() {
+		results, err := ExecuteBatch(project.ID, []*BatchOperation{
+			{Op: BatchCreate, Type: ChangeTypeElement, ID: "screen", Data: json.RawMessage(`{"name": "Screen", "type": "screen"}`)},
+			{Op: BatchCreate, Type: ChangeTypeElement, Data: json.RawMessage(`{"element_id": "screen", "name": "Text", "type": "text"}`)},
+			{Op: BatchCreate, Type: ChangeTypeEvent, Data: json.RawMessage(`{"element_id": "screen", "event": "click"}`)},
+		})
+
+		So(err, ShouldBeNil)
+		So(results, ShouldHaveLength, 3)
+
+		child, _ := GetElement(results[1].ID)
+		So(child.ElementID, ShouldResemble, results[0].ID)
+	})
+
+	Convey("Roll back if failed", t, func() {
+		_, err := ExecuteBatch(project.ID, []*BatchOperation{
+			{Op: BatchCreate, Type: ChangeTypeElement, ID: "a", Data: json.RawMessage(`{"name": "A", "type": "screen"}`)},
+			{Op: BatchCreate, Type: ChangeTypeElement, Data: json.RawMessage(`{"name": "B"}`)},
+		})
+
+		So(err, ShouldResemble, &util.APIError{
+			Field:   "operations.1.type",
+			Code:    util.RequiredError,
+			Message: "Element type is required.",
+		})
+
+		list, _ := GetElementList(&ElementQueryOption{ProjectID: &project.ID, Flat: true})
+
+		for _, e := range list {
+			So(e.Name, ShouldNotEqual, "A")
+		}
+	})
+
+	Convey("Unsupported operation", t, func() {
+		_, err := ExecuteBatch(project.ID, []*BatchOperation{
+			{Op: BatchReorder, Type: ChangeTypeEvent, ID: types.NewRandomUUID().String()},
+		})
+
+		So(err.(*util.APIError).Field, ShouldEqual, "operations.0.op")
+	})
+
+	Convey("Operation is required", t, func() {
+		_, err := ExecuteBatch(project.ID, []*BatchOperation{
+			{Op: BatchCreate, Type: ChangeTypeElement, Data: json.RawMessage(`{"name": "A", "type": "screen"}`)},
+			nil,
+		})
+
+		So(err, ShouldResemble, &util.APIError{
+			Field:   "operations.1",
+			Code:    util.RequiredError,
+			Message: "Operation is required.",
+		})
+	})
+}
@@ -0,0 +1,424 @@
+package model
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	"github.com/tkusd/server/model/types"
+	"github.com/tkusd/server/util"
+)
+
+// BundleVersion is the version of the bundle format.
+const BundleVersion = 1
+
+// Bundle contains everything in a project. It's used to move projects between
+// servers.
+type Bundle struct {
+	Version  int        `json:"version"`
+	Project  *Project   `json:"project"`
+	Elements []*Element `json:"elements"`
+	Assets   []*Asset   `json:"assets"`
+}
+
+type elementsByIndex []*Element
+
+func (e elementsByIndex) Len() int           { return len(e) }
+func (e elementsByIndex) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e elementsByIndex) Less(i, j int) bool { return e[i].Index < e[j].Index }
+
+// ExportProject returns a bundle of the project. Elements are flattened and
+// contain their events.
+func ExportProject(project *Project) (*Bundle, error) {
+	elements, err := GetElementList(&ElementQueryOption{
+		ProjectID:  &project.ID,
+		Flat:       true,
+		WithEvents: true,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	assets, err := GetAssetList(project.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bundle{
+		Version:  BundleVersion,
+		Project:  project,
+		Elements: elements,
+		Assets:   assets,
+	}, nil
+}
+
+// idMap maps old IDs to new IDs when records are copied.
+type idMap map[string]types.UUID
+
+// add generates a new ID for the record.
+func (m idMap) add(id types.UUID) types.UUID {
+	newID := types.NewRandomUUID()
+
+	if id.Valid() {
+		m[id.String()] = newID
+	}
+
+	return newID
+}
+
+// get returns the new ID. An invalid UUID is returned if the ID is not mapped.
+func (m idMap) get(id types.UUID) types.UUID {
+	if !id.Valid() {
+		return types.UUID{}
+	}
+
+	return m[id.String()]
+}
+
+// remapJSON replaces strings which are old IDs with new IDs in the JSON value.
+// Elements and assets are referred this way in attributes and styles.
+func (m idMap) remapJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		if id, ok := m[v]; ok {
+			return id.String()
+		}
+
+	case map[string]interface{}:
+		for key, val := range v {
+			v[key] = m.remapJSON(val)
+		}
+
+	case types.JSONObject:
+		for key, val := range v {
+			v[key] = m.remapJSON(val)
+		}
+
+	case []interface{}:
+		for i, val := range v {
+			v[i] = m.remapJSON(val)
+		}
+	}
+
+	return v
+}
+
+// remapString replaces all old IDs in the string with new IDs.
+func (m idMap) remapString(s string) string {
+	for oldID, newID := range m {
+		s = strings.Replace(s, oldID, newID.String(), -1)
+	}
+
+	return s
+}
+
+// sortElementsByParent sorts elements so parents are always in front of their
+// children, and siblings are sorted by index.
+func sortElementsByParent(list []*Element) ([]*Element, error) {
+	var result []*Element
+	added := map[string]bool{}
+	rest := make([]*Element, len(list))
+
+	copy(rest, list)
+	sort.Stable(elementsByIndex(rest))
+
+	for len(rest) > 0 {
+		var next []*Element
+
+		for _, e := range rest {
+			if !e.ElementID.Valid() || added[e.ElementID.String()] {
+				result = append(result, e)
+				added[e.ID.String()] = true
+			} else {
+				next = append(next, e)
+			}
+		}
+
+		// Parents of the remaining elements are missing or circular
+		if len(next) == len(rest) {
+			return nil, &util.APIError{
+				Code:    util.ElementNotOwnedByProjectError,
+				Message: "The parent element is not owned by the project.",
+				Field:   "element_id",
+			}
+		}
+
+		rest = next
+	}
+
+	return result, nil
+}
+
+// copyElements inserts the elements and their events into the project in the
+// transaction. Elements get new IDs and are remapped with the map. Parents
+// must be in front of their children.
+func copyElements(tx *gorm.DB, projectID types.UUID, list []*Element, ids idMap, trusted bool) error {
+	for _, e := range list {
+		ids.add(e.ID)
+	}
+
+	for _, e := range list {
+		e.ID = ids.get(e.ID)
+		e.ProjectID = projectID
+
+		// Parents which are not copied are kept
+		if parentID := ids.get(e.ElementID); parentID.Valid() {
+			e.ElementID = parentID
+		}
+
+		ids.remapJSON(e.Attributes)
+		ids.remapJSON(e.Styles)
+
+		for _, event := range e.Events {
+			event.ID = types.NewRandomUUID()
+			event.ElementID = e.ID
+			event.Workspace = ids.remapString(event.Workspace)
+		}
+	}
+
+	return insertElements(tx, list, trusted)
+}
+
+// insertElements inserts the elements and their events in the transaction.
+// IDs and indexes of elements are kept. Parents must be in front of their
+// children. Trusted elements are copies of elements on this server, e.g.
+// snapshots, so legacy data which doesn't match the element types is kept.
+// All elements must be in the same project.
+func insertElements(tx *gorm.DB, list []*Element, trusted bool) error {
+	if len(list) > 0 {
+		if err := checkElementQuota(tx, list[0].ProjectID, len(list)); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range list {
+		var prev *Element
+		index := e.Index
+
+		if trusted {
+			prev = e
+
+			if err := removeMissingAssetRefs(tx, e); err != nil {
+				return err
+			}
+		}
+
+		if err := e.validate(prev); err != nil {
+			return err
+		}
+
+		if err := checkParentType(tx, e.ElementID, e.Type); err != nil {
+			return err
+		}
+
+		if err := tx.Create(e).Error; err != nil {
+			return err
+		}
+
+		// The index is replaced by the trigger on insert. Restore it to keep
+		// the original order.
+		if index > 0 && index != e.Index {
+			err := tx.Table("elements").
+				Where("id = ?", e.ID.String()).
+				UpdateColumn("index", index).
+				Error
+
+			if err != nil {
+				return err
+			}
+
+			e.Index = index
+		}
+
+		if err := saveAssetRefs(tx, e); err != nil {
+			return err
+		}
+
+		for _, event := range e.Events {
+			if err := event.validate(); err != nil {
+				return err
+			}
+
+			if err := tx.Create(event).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// copyAssets inserts the assets into the project in the transaction. The
+// content of assets must be written to the asset store already.
+func copyAssets(tx *gorm.DB, projectID types.UUID, list []*Asset, ids idMap) error {
+	for _, asset := range list {
+		asset.ID = ids.add(asset.ID)
+		asset.ProjectID = projectID
+
+		if err := asset.validate(); err != nil {
+			return err
+		}
+
+		if err := tx.Create(asset).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insertBundle inserts everything in the bundle as a new project of the user in
+// one transaction. The bundle is trusted if it's exported from this server.
+func insertBundle(userID types.UUID, bundle *Bundle, trusted bool) (*Project, error) {
+	elements, err := sortElementsByParent(bundle.Elements)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ids := idMap{}
+	src := bundle.Project
+	project := &Project{
+		ID:          ids.add(src.ID),
+		Title:       src.Title,
+		Description: src.Description,
+		UserID:      userID,
+		IsPrivate:   src.IsPrivate,
+		Theme:       src.Theme,
+	}
+
+	if err := project.validate(); err != nil {
+		return nil, err
+	}
+
+	tx := db.Begin()
+
+	if err := tx.Create(project).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Assets are copied first, so elements can refer to them
+	if err := copyAssets(tx, project.ID, bundle.Assets, ids); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := copyElements(tx, project.ID, elements, ids, trusted); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if mainScreen := ids.get(src.MainScreen); mainScreen.Valid() {
+		err := tx.Table("projects").
+			Where("id = ?", project.ID.String()).
+			UpdateColumn("main_screen", mainScreen.String()).
+			Error
+
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	// The imported state is the baseline of later changes
+	if err := createRevision(tx, project.ID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Commit the transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return GetProjectWithOwner(project.ID)
+}
+
+// ImportBundle creates a new project for the user from the bundle. All records
+// get new IDs and references between them, including the main screen, parent
+// elements and assets in attributes and styles, are updated. The content of
+// assets must be written to the asset store before importing.
+func ImportBundle(userID types.UUID, bundle *Bundle) (*Project, error) {
+	if bundle.Version != BundleVersion {
+		return nil, &util.APIError{
+			Field:   "version",
+			Code:    util.TypeError,
+			Message: "Bundle version is not supported.",
+		}
+	}
+
+	if bundle.Project == nil {
+		return nil, &util.APIError{
+			Field:   "project",
+			Code:    util.RequiredError,
+			Message: "Project is required.",
+		}
+	}
+
+	return insertBundle(userID, bundle, false)
+}
+
+// ForkProject copies the project with all elements, events and assets into a
+// new project owned by the user. The content of assets is copied in the asset
+// store as well. Quotas of the user are checked before copying.
+func ForkProject(project *Project, userID types.UUID) (*Project, error) {
+	bundle, err := ExportProject(project)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := CheckBundleQuota(userID, bundle); err != nil {
+		return nil, err
+	}
+
+	if err := copyAssetContents(bundle.Assets); err != nil {
+		return nil, err
+	}
+
+	result, err := insertBundle(userID, bundle, true)
+
+	if err != nil {
+		DeleteUnsavedAssets(bundle.Assets)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// copyAssetContents copies the content of assets with new slugs. Assets are
+// updated to refer to the new content. Copied contents are deleted if failed.
+func copyAssetContents(assets []*Asset) error {
+	for i, asset := range assets {
+		if err := copyAssetContent(asset); err != nil {
+			DeleteUnsavedAssets(assets[:i])
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyAssetContent(asset *Asset) error {
+	src, err := asset.OpenAsset()
+
+	if err != nil {
+		return err
+	}
+
+	defer src.Close()
+
+	copied := *asset
+	copied.Slug = types.NewRandomUUID().String() + filepath.Ext(asset.Slug)
+
+	if err := copied.WriteAsset(src); err != nil {
+		return err
+	}
+
+	asset.Slug = copied.Slug
+	return nil
+}
@@ -0,0 +1,158 @@
+package model
+
+import (
+	"log"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tkusd/server/config"
+	"github.com/tkusd/server/model/types"
+	"github.com/tkusd/server/util"
+)
+
+func TestSortElementsByParent(t *testing.T) {
+	parent := &Element{ID: types.NewRandomUUID(), Index: 2}
+	child := &Element{ID: types.NewRandomUUID(), ElementID: parent.ID, Index: 1}
+	sibling := &Element{ID: types.NewRandomUUID(), Index: 1}
+
+	Convey("Parents are in front of children", t, func() {
+		list, err := sortElementsByParent([]*Element{child, parent, sibling})
+		So(err, ShouldBeNil)
+		So(list, ShouldResemble, []*Element{sibling, parent, child})
+	})
+
+	Convey("Parent is missing", t, func() {
+		orphan := &Element{ID: types.NewRandomUUID(), ElementID: types.NewRandomUUID()}
+		_, err := sortElementsByParent([]*Element{parent, orphan})
+		So(err, ShouldResemble, &util.APIError{
+			Code:    util.ElementNotOwnedByProjectError,
+			Message: "The parent element is not owned by the project.",
+			Field:   "element_id",
+		})
+	})
+}
+
+func TestImportBundle(t *testing.T) {
+	user, err := createTestUser(fixtureUsers[0])
+	defer user.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	project, err := createTestProject(user)
+	defer project.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	screen, err := createTestElement(project)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	child, err := createTestChildElement(screen)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	child.Attributes = types.JSONObject{"link": screen.ID.String()}
+	child.Save()
+
+	Convey("Import an exported project", t, func() {
+		bundle, err := ExportProject(project)
+		So(err, ShouldBeNil)
+
+		result, err := ImportBundle(user.ID, bundle)
+		defer result.Delete()
+
+		So(err, ShouldBeNil)
+		So(result.ID, ShouldNotResemble, project.ID)
+		So(result.Title, ShouldEqual, project.Title)
+
+		elements, _ := GetElementList(&ElementQueryOption{
+			ProjectID: &result.ID,
+			Flat:      true,
+		})
+
+		So(elements, ShouldHaveLength, 2)
+		So(result.MainScreen, ShouldResemble, elements[0].ID)
+		So(elements[1].ElementID, ShouldResemble, elements[0].ID)
+		So(elements[1].Attributes["link"], ShouldEqual, elements[0].ID.String())
+	})
+
+	Convey("Unsupported version", t, func() {
+		_, err := ImportBundle(user.ID, &Bundle{Version: BundleVersion + 1})
+		So(err, ShouldResemble, &util.APIError{
+			Field:   "version",
+			Code:    util.TypeError,
+			Message: "Bundle version is not supported.",
+		})
+	})
+}
+
+func TestForkProject(t *testing.T) {
+	owner, err := createTestUser(fixtureUsers[0])
+	defer owner.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	user, err := createTestUser(fixtureUsers[1])
+	defer user.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	project, err := createTestProject(owner)
+	defer project.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	screen, err := createTestElement(project)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	createTestChildElement(screen)
+	createTestChildElement(screen)
+
+	Convey("Fork a project", t, func() {
+		result, err := ForkProject(project, user.ID)
+		defer result.Delete()
+
+		So(err, ShouldBeNil)
+		So(result.UserID, ShouldResemble, user.ID)
+
+		elements, _ := GetElementList(&ElementQueryOption{
+			ProjectID: &result.ID,
+			Flat:      true,
+		})
+
+		So(elements, ShouldHaveLength, 3)
+		So(result.MainScreen, ShouldResemble, elements[0].ID)
+		So(elements[1].Index, ShouldEqual, 1)
+		So(elements[2].Index, ShouldEqual, 2)
+	})
+	Convey("Quota of the new owner", t, func() {
+		quota := config.Config.Quota
+		defer func() { config.Config.Quota = quota }()
+
+		asset := &Asset{Name: "a.png", ProjectID: project.ID, Size: 600}
+		So(asset.Save(), ShouldBeNil)
+		defer asset.Delete()
+
+		config.Config.Quota.UserAssetSize = 500
+
+		_, err := ForkProject(project, user.ID)
+		So(err.(*util.APIError).Code, ShouldEqual, util.QuotaExceededError)
+	})
+}
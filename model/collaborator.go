@@ -0,0 +1,191 @@
+package model
+
+import (
+	"database/sql"
+
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"github.com/tkusd/server/model/types"
+	"github.com/tkusd/server/util"
+)
+
+// Collaborator represents a user who is granted access to a project.
+type Collaborator struct {
+	ID         types.UUID       `json:"id"`
+	ProjectID  types.UUID       `json:"project_id"`
+	UserID     types.UUID       `json:"user_id"`
+	Permission types.Permission `json:"permission"`
+	CreatedAt  types.Time       `json:"created_at"`
+	UpdatedAt  types.Time       `json:"updated_at"`
+
+	// Virtual attributes
+	User struct {
+		ID     types.UUID `json:"id"`
+		Name   string     `json:"name"`
+		Avatar string     `json:"avatar"`
+	} `json:"user,omitempty" sql:"-"`
+}
+
+// Save creates or updates data in the database.
+func (collaborator *Collaborator) Save() error {
+	collaborator.Permission = collaborator.Permission.Normalize()
+
+	if collaborator.Permission == 0 {
+		return &util.APIError{
+			Field:   "permission",
+			Code:    util.RequiredError,
+			Message: "Permission is required.",
+		}
+	}
+
+	if GetUserIDForProject(collaborator.ProjectID).Equal(collaborator.UserID) {
+		return &util.APIError{
+			Field:   "user_id",
+			Code:    util.CollaboratorIsOwnerError,
+			Message: "The owner of the project can't be a collaborator.",
+		}
+	}
+
+	if err := db.Save(collaborator).Error; err != nil {
+		switch e := err.(type) {
+		case *pq.Error:
+			switch e.Code.Name() {
+			case UniqueViolation:
+				return &util.APIError{
+					Field:   "user_id",
+					Code:    util.CollaboratorExistError,
+					Message: "The user is already a collaborator.",
+				}
+			}
+		}
+
+		return err
+	}
+
+	var user User
+
+	if err := db.Where("id = ?", collaborator.UserID.String()).Select([]string{"id", "name", "avatar"}).First(&user).Error; err != nil {
+		return err
+	}
+
+	collaborator.User.ID = user.ID
+	collaborator.User.Name = user.Name
+	collaborator.User.Avatar = user.Avatar
+
+	return nil
+}
+
+// Delete deletes data from the database.
+func (collaborator *Collaborator) Delete() error {
+	return db.Delete(collaborator).Error
+}
+
+func generateCollaboratorWithUserQuery() *gorm.DB {
+	return db.Table("collaborators").
+		Joins("JOIN users ON users.id = collaborators.user_id").
+		Select([]string{
+			"collaborators.id",
+			"collaborators.project_id",
+			"collaborators.user_id",
+			"collaborators.permission",
+			"collaborators.created_at",
+			"collaborators.updated_at",
+			"users.id",
+			"users.name",
+			"users.avatar",
+		})
+}
+
+func scanCollaboratorsWithUser(rows *sql.Rows) ([]*Collaborator, error) {
+	var list []*Collaborator
+
+	defer rows.Close()
+
+	for rows.Next() {
+		collaborator := new(Collaborator)
+
+		err := rows.Scan(
+			&collaborator.ID,
+			&collaborator.ProjectID,
+			&collaborator.UserID,
+			&collaborator.Permission,
+			&collaborator.CreatedAt,
+			&collaborator.UpdatedAt,
+			&collaborator.User.ID,
+			&collaborator.User.Name,
+			&collaborator.User.Avatar,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, collaborator)
+	}
+
+	return list, nil
+}
+
+// GetCollaboratorList gets the collaborators of a project.
+func GetCollaboratorList(projectID types.UUID) ([]*Collaborator, error) {
+	rows, err := generateCollaboratorWithUserQuery().
+		Where("collaborators.project_id = ?", projectID.String()).
+		Order("collaborators.created_at").
+		Rows()
+
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := scanCollaboratorsWithUser(rows)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if list == nil {
+		list = make([]*Collaborator, 0)
+	}
+
+	return list, nil
+}
+
+// GetCollaborator gets the collaborator data.
+func GetCollaborator(id types.UUID) (*Collaborator, error) {
+	rows, err := generateCollaboratorWithUserQuery().
+		Where("collaborators.id = ?", id.String()).
+		Limit(1).
+		Rows()
+
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := scanCollaboratorsWithUser(rows)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(list) == 0 {
+		return nil, gorm.RecordNotFound
+	}
+
+	return list[0], nil
+}
+
+// GetProjectPermission returns the permission of a user on the project.
+// The owner always has all permissions.
+func GetProjectPermission(project *Project, userID types.UUID) types.Permission {
+	if project.UserID.Equal(userID) {
+		return types.PermissionAll
+	}
+
+	var perm types.Permission
+
+	db.Raw("SELECT permission FROM collaborators WHERE project_id = ? AND user_id = ?", project.ID.String(), userID.String()).
+		Row().
+		Scan(&perm)
+
+	return perm.Normalize()
+}
@@ -0,0 +1,150 @@
+package model
+
+import (
+	"log"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tkusd/server/model/types"
+	"github.com/tkusd/server/util"
+)
+
+func TestCollaborator(t *testing.T) {
+	owner, err := createTestUser(fixtureUsers[0])
+	defer owner.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	user, err := createTestUser(fixtureUsers[1])
+	defer user.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	project, err := createTestProject(owner)
+	defer project.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	Convey("Save", t, func() {
+		Convey("Normalize permission", func() {
+			collaborator := &Collaborator{
+				ProjectID:  project.ID,
+				UserID:     user.ID,
+				Permission: types.PermissionAdmin,
+			}
+
+			err := collaborator.Save()
+			defer collaborator.Delete()
+
+			So(err, ShouldBeNil)
+			So(collaborator.Permission, ShouldEqual, types.PermissionAll)
+			So(collaborator.User.ID, ShouldResemble, user.ID)
+		})
+
+		Convey("Permission is required", func() {
+			collaborator := &Collaborator{
+				ProjectID: project.ID,
+				UserID:    user.ID,
+			}
+
+			err := collaborator.Save()
+			So(err, ShouldResemble, &util.APIError{
+				Field:   "permission",
+				Code:    util.RequiredError,
+				Message: "Permission is required.",
+			})
+		})
+
+		Convey("Owner can't be a collaborator", func() {
+			collaborator := &Collaborator{
+				ProjectID:  project.ID,
+				UserID:     owner.ID,
+				Permission: types.PermissionRead,
+			}
+
+			err := collaborator.Save()
+			So(err, ShouldResemble, &util.APIError{
+				Field:   "user_id",
+				Code:    util.CollaboratorIsOwnerError,
+				Message: "The owner of the project can't be a collaborator.",
+			})
+		})
+
+		Convey("User is already a collaborator", func() {
+			c1 := &Collaborator{
+				ProjectID:  project.ID,
+				UserID:     user.ID,
+				Permission: types.PermissionRead,
+			}
+
+			c1.Save()
+			defer c1.Delete()
+
+			c2 := &Collaborator{
+				ProjectID:  project.ID,
+				UserID:     user.ID,
+				Permission: types.PermissionRead,
+			}
+
+			err := c2.Save()
+			So(err, ShouldResemble, &util.APIError{
+				Field:   "user_id",
+				Code:    util.CollaboratorExistError,
+				Message: "The user is already a collaborator.",
+			})
+		})
+	})
+}
+
+func TestGetProjectPermission(t *testing.T) {
+	owner, err := createTestUser(fixtureUsers[0])
+	defer owner.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	user, err := createTestUser(fixtureUsers[1])
+	defer user.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	project, err := createTestProject(owner)
+	defer project.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	Convey("Owner", t, func() {
+		So(GetProjectPermission(project, owner.ID), ShouldEqual, types.PermissionAll)
+	})
+
+	Convey("Not a collaborator", t, func() {
+		So(GetProjectPermission(project, user.ID), ShouldEqual, types.Permission(0))
+	})
+
+	Convey("Collaborator", t, func() {
+		collaborator := &Collaborator{
+			ProjectID:  project.ID,
+			UserID:     user.ID,
+			Permission: types.PermissionWrite,
+		}
+
+		collaborator.Save()
+		defer collaborator.Delete()
+
+		perm := GetProjectPermission(project, user.ID)
+		So(perm.Has(types.PermissionWrite), ShouldBeTrue)
+		So(perm.Has(types.PermissionRead), ShouldBeTrue)
+		So(perm.Has(types.PermissionAdmin), ShouldBeFalse)
+	})
+}
@@ -0,0 +1,212 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	"github.com/tkusd/server/model/types"
+	"github.com/tkusd/server/util"
+)
+
+// Cursor points to a row in a sorted list. Rows after the cursor are returned,
+// or rows before it if Before is true.
+type Cursor struct {
+	Value  string     `json:"v"`
+	ID     types.UUID `json:"id"`
+	Before bool       `json:"b,omitempty"`
+}
+
+// Encode returns the cursor as an opaque string.
+func (c *Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.URLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses the string returned by Cursor.Encode.
+func DecodeCursor(s string) (*Cursor, error) {
+	cursor := new(Cursor)
+	data, err := base64.URLEncoding.DecodeString(s)
+
+	if err == nil {
+		err = json.Unmarshal(data, cursor)
+	}
+
+	if err != nil || !cursor.ID.Valid() {
+		return nil, &util.APIError{
+			Field:   "cursor",
+			Code:    util.ValueError,
+			Message: "Cursor is invalid.",
+		}
+	}
+
+	return cursor, nil
+}
+
+// pageList is a list of rows which can be paginated with cursors.
+type pageList interface {
+	Len() int
+	Swap(i, j int)
+	Truncate(n int)
+
+	// CursorKey returns the value of the column and the ID of the row.
+	CursorKey(i int, column string) (string, types.UUID)
+}
+
+// page paginates a list with offset or cursor. Cursors are only available if
+// the list is sorted by one of the cursor columns. The ID is used to break
+// ties.
+type page struct {
+	option *QueryOption
+	column string
+	desc   bool
+	cursor *Cursor
+}
+
+func newPage(option *QueryOption, columns []string) (*page, error) {
+	p := &page{option: option}
+	order := strings.TrimSpace(option.Order)
+	column := strings.TrimPrefix(order, "-")
+
+	for _, col := range columns {
+		if col == column {
+			p.column = column
+			p.desc = order != column
+		}
+	}
+
+	if option.Cursor == "" {
+		return p, nil
+	}
+
+	if p.column == "" {
+		return nil, &util.APIError{
+			Field:   "order",
+			Code:    util.ValueError,
+			Message: "Cursor is only supported when sorted by " + strings.Join(columns, ", ") + ".",
+		}
+	}
+
+	cursor, err := DecodeCursor(option.Cursor)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p.cursor = cursor
+	option.Offset = 0
+
+	return p, nil
+}
+
+// isCursor returns true if the page is fetched with a cursor.
+func (p *page) isCursor() bool {
+	return p.cursor != nil
+}
+
+// apply adds the order, offset and limit to the query. The order is used if
+// the list isn't sorted by a cursor column. In cursor mode, rows before the
+// cursor are fetched in reverse order and one more row is fetched to know
+// whether there are more rows.
+func (p *page) apply(scope *gorm.DB, table, order string) *gorm.DB {
+	if p.column == "" {
+		return scope.Order(order).
+			Offset(p.option.Offset).
+			Limit(p.option.Limit)
+	}
+
+	desc := p.desc
+	col := table + "." + p.column
+	id := table + ".id"
+
+	if p.cursor != nil {
+		desc = desc != p.cursor.Before
+		op := ">"
+
+		if desc {
+			op = "<"
+		}
+
+		scope = scope.Where("("+col+", "+id+") "+op+" (?, ?)", p.cursor.Value, p.cursor.ID.String())
+	}
+
+	dir := " asc"
+
+	if desc {
+		dir = " desc"
+	}
+
+	scope = scope.Order(col + dir + ", " + id + dir)
+
+	if p.cursor != nil {
+		return scope.Limit(p.option.Limit + 1)
+	}
+
+	return scope.Offset(p.option.Offset).Limit(p.option.Limit)
+}
+
+func (p *page) cursorAt(list pageList, i int, before bool) string {
+	value, id := list.CursorKey(i, p.column)
+	cursor := &Cursor{Value: value, ID: id, Before: before}
+	return cursor.Encode()
+}
+
+// finish trims the extra row, restores the order of rows fetched backwards and
+// returns the cursors of the previous and next pages. The count is only used
+// in offset mode.
+func (p *page) finish(list pageList, count int) (prev, next string, hasMore bool) {
+	if p.cursor == nil {
+		hasMore = count > p.option.Offset+p.option.Limit
+
+		if p.column == "" || list.Len() == 0 {
+			return
+		}
+
+		if hasMore {
+			next = p.cursorAt(list, list.Len()-1, false)
+		}
+
+		if p.option.Offset > 0 {
+			prev = p.cursorAt(list, 0, true)
+		}
+
+		return
+	}
+
+	extra := list.Len() > p.option.Limit
+
+	if extra {
+		list.Truncate(p.option.Limit)
+	}
+
+	n := list.Len()
+
+	if p.cursor.Before {
+		for i := 0; i < n/2; i++ {
+			list.Swap(i, n-1-i)
+		}
+	}
+
+	if n == 0 {
+		return
+	}
+
+	if p.cursor.Before {
+		hasMore = true
+		next = p.cursorAt(list, n-1, false)
+
+		if extra {
+			prev = p.cursorAt(list, 0, true)
+		}
+	} else {
+		hasMore = extra
+		prev = p.cursorAt(list, 0, true)
+
+		if extra {
+			next = p.cursorAt(list, n-1, false)
+		}
+	}
+
+	return
+}
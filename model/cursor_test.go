@@ -0,0 +1,100 @@
+package model
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tkusd/server/model/types"
+	"github.com/tkusd/server/util"
+)
+
+func TestCursor(t *testing.T) {
+	Convey("Encode and decode", t, func() {
+		cursor := &Cursor{Value: "abc", ID: types.NewRandomUUID(), Before: true}
+		result, err := DecodeCursor(cursor.Encode())
+
+		So(err, ShouldBeNil)
+		So(result.Value, ShouldEqual, cursor.Value)
+		So(result.ID, ShouldResemble, cursor.ID)
+		So(result.Before, ShouldBeTrue)
+	})
+
+	Convey("Invalid cursor", t, func() {
+		_, err := DecodeCursor("foo")
+		So(err, ShouldResemble, &util.APIError{
+			Field:   "cursor",
+			Code:    util.ValueError,
+			Message: "Cursor is invalid.",
+		})
+	})
+
+	Convey("Cursor requires a cursor column", t, func() {
+		cursor := &Cursor{ID: types.NewRandomUUID()}
+		_, err := newPage(&QueryOption{Order: "title,-created_at", Cursor: cursor.Encode()}, projectCursorColumns)
+		So(err.(*util.APIError).Field, ShouldEqual, "order")
+	})
+}
+
+func TestPageFinish(t *testing.T) {
+	newList := func(titles ...string) *projectList {
+		var list projectList
+
+		for _, title := range titles {
+			list = append(list, &Project{ID: types.NewRandomUUID(), Title: title})
+		}
+
+		return &list
+	}
+
+	Convey("Offset mode", t, func() {
+		p, _ := newPage(&QueryOption{Order: "title", Limit: 2, Offset: 2}, projectCursorColumns)
+		list := newList("c", "d")
+		prev, next, hasMore := p.finish(list, 5)
+
+		So(hasMore, ShouldBeTrue)
+		So(prev, ShouldNotBeEmpty)
+		So(next, ShouldNotBeEmpty)
+
+		cursor, _ := DecodeCursor(next)
+		So(cursor.Value, ShouldEqual, "d")
+		So(cursor.Before, ShouldBeFalse)
+	})
+
+	Convey("No cursors if the order isn't supported", t, func() {
+		p, _ := newPage(&QueryOption{Order: "title,-created_at", Limit: 2}, projectCursorColumns)
+		prev, next, hasMore := p.finish(newList("a", "b"), 5)
+
+		So(hasMore, ShouldBeTrue)
+		So(prev, ShouldBeEmpty)
+		So(next, ShouldBeEmpty)
+	})
+
+	Convey("After cursor", t, func() {
+		cursor := &Cursor{Value: "b", ID: types.NewRandomUUID()}
+		p, _ := newPage(&QueryOption{Order: "title", Limit: 2, Cursor: cursor.Encode()}, projectCursorColumns)
+		list := newList("c", "d", "e")
+		_, next, hasMore := p.finish(list, 0)
+
+		So(hasMore, ShouldBeTrue)
+		So(list.Len(), ShouldEqual, 2)
+
+		cursor, _ = DecodeCursor(next)
+		So(cursor.Value, ShouldEqual, "d")
+	})
+
+	Convey("Before cursor", t, func() {
+		cursor := &Cursor{Value: "e", ID: types.NewRandomUUID(), Before: true}
+		p, _ := newPage(&QueryOption{Order: "title", Limit: 2, Cursor: cursor.Encode()}, projectCursorColumns)
+
+		// Rows are fetched in reverse order
+		list := newList("d", "c", "b")
+		prev, _, _ := p.finish(list, 0)
+
+		So((*list)[0].Title, ShouldEqual, "c")
+		So((*list)[1].Title, ShouldEqual, "d")
+
+		cursor, _ = DecodeCursor(prev)
+		So(cursor.Value, ShouldEqual, "c")
+		So(cursor.Before, ShouldBeTrue)
+	})
+}
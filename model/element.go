@@ -50,8 +50,11 @@ func (e *Element) AfterCreate(tx *gorm.DB) error {
 	return nil
 }
 
-// Save creates or updates data in the database.
-func (e *Element) Save() error {
+// validate checks the element. Values which are the same as in prev, the
+// element stored in the database, are not checked again, so legacy elements
+// which don't match their types can still be saved. prev is nil for new
+// elements.
+func (e *Element) validate(prev *Element) error {
 	e.Name = govalidator.Trim(e.Name, "")
 
 	if len(e.Name) > 255 {
@@ -78,30 +81,122 @@ func (e *Element) Save() error {
 		e.Styles = map[string]interface{}{}
 	}
 
-	err := db.Save(e).Error
+	return e.validateType(prev)
+}
+
+// storedElement gets the element stored in the database in the transaction.
+// It returns nil if the element is not saved yet.
+func storedElement(tx *gorm.DB, id types.UUID) (*Element, error) {
+	var list []*Element
 
-	if err == nil {
-		return nil
+	if !id.Valid() {
+		return nil, nil
 	}
 
-	switch e := err.(type) {
-	case *pq.Error:
-		switch e.Code.Name() {
-		case ForeignKeyViolation:
-			return &util.APIError{
-				Code:    util.ElementNotOwnedByProjectError,
-				Message: "The parent element is not owned by the project.",
-				Field:   "element_id",
-			}
-		}
+	if err := tx.Where("id = ?", id.String()).Find(&list).Error; err != nil {
+		return nil, err
 	}
 
-	return err
+	if len(list) == 0 {
+		return nil, nil
+	}
+
+	return list[0], nil
+}
+
+// Save creates or updates data in the database.
+func (e *Element) Save() error {
+	return e.SaveIf(nil)
+}
+
+// SaveIf is like Save but the precondition is checked before updating.
+func (e *Element) SaveIf(pre Precondition) error {
+	action := saveAction(e.ID)
+	tx := db.Begin()
+
+	if err := checkPrecondition(tx, "elements", e.ID, pre); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	prev, err := storedElement(tx, e.ID)
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := e.validate(prev); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := checkElementTree(tx, e); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Save(e).Error; err != nil {
+		tx.Rollback()
+		return convertElementError(err)
+	}
+
+	if err := saveAssetRefs(tx, e); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := recordRevision(tx, e.ProjectID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Commit the transaction
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	publishChange(e.ProjectID, action, ChangeTypeElement, e.ID, *e)
+	return nil
 }
 
 // Delete deletes data from the database.
 func (e *Element) Delete() error {
-	return db.Delete(e).Error
+	return e.DeleteIf(nil)
+}
+
+// DeleteIf is like Delete but the precondition is checked before deleting.
+func (e *Element) DeleteIf(pre Precondition) error {
+	projectID := e.ProjectID
+
+	if !projectID.Valid() {
+		projectID = GetProjectIDForElement(e.ID)
+	}
+
+	tx := db.Begin()
+
+	if err := checkPrecondition(tx, "elements", e.ID, pre); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Delete(e).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := recordRevision(tx, projectID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Commit the transaction
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	publishChange(projectID, ChangeDelete, ChangeTypeElement, e.ID, nil)
+	return nil
 }
 
 func (e *Element) Exists() bool {
@@ -120,11 +215,22 @@ func GetElement(id types.UUID) (*Element, error) {
 }
 
 func appendIfMissing(arr []string, key ...string) []string {
+	for _, k := range key {
+		if !containsString(arr, k) {
+			arr = append(arr, k)
+		}
+	}
+
 	return arr
 }
 
 // GetElementList gets a list of elements.
 func GetElementList(option *ElementQueryOption) ([]*Element, error) {
+	return getElementList(&db, option)
+}
+
+// getElementList gets a list of elements in the transaction.
+func getElementList(tx *gorm.DB, option *ElementQueryOption) ([]*Element, error) {
 	var list []*Element
 	var id string
 	var elementID types.UUID
@@ -133,8 +239,8 @@ func GetElementList(option *ElementQueryOption) ([]*Element, error) {
 	if len(option.Select) == 0 {
 		option.Select = []string{"*"}
 	} else {
-		// TODO: Add missing fields
-		//option.Select = appendIfMissing(option.Select, "id", "project_id", "element_id", "index")
+		// Columns used to build the tree
+		option.Select = appendIfMissing(option.Select, "id", "project_id", "element_id", "index")
 	}
 
 	for _, col := range option.Select {
@@ -166,7 +272,7 @@ WHERE elements.element_id = tree.id`
 	raw += `)
 SELECT * FROM tree ORDER BY depth, index;`
 
-	if err := db.Raw(raw, id).Find(&list).Error; err != nil {
+	if err := tx.Raw(raw, id).Find(&list).Error; err != nil {
 		return nil, err
 	}
 
@@ -177,7 +283,7 @@ SELECT * FROM tree ORDER BY depth, index;`
 	if option.WithEvents {
 		var events []*Event
 
-		err := db.Select([]string{
+		err := tx.Select([]string{
 			"events.id",
 			"events.element_id",
 			"events.workspace",
@@ -228,10 +334,45 @@ func buildElementTree(list []*Element, parentID types.UUID) []*Element {
 	return result
 }
 
+// UpdateElementOrder moves the elements under the parent in the given order
+// in one transaction.
 func UpdateElementOrder(option *ElementQueryOption, elements []types.UUID) error {
-	var parentID types.UUID
 	tx := db.Begin()
 
+	if err := updateElementOrder(tx, option, elements); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var projectID, parentID types.UUID
+
+	if option.ProjectID != nil {
+		projectID = *option.ProjectID
+	}
+
+	if option.ElementID != nil {
+		parentID = *option.ElementID
+	}
+
+	if err := recordRevision(tx, projectID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Commit the transaction
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	publishReorder(projectID, parentID, elements)
+	return nil
+}
+
+// updateElementOrder moves the elements under the parent in the given order
+// in the transaction.
+func updateElementOrder(tx *gorm.DB, option *ElementQueryOption, elements []types.UUID) error {
+	var parentID types.UUID
+
 	if option.ElementID != nil {
 		parentID = *option.ElementID
 	}
@@ -243,8 +384,6 @@ func UpdateElementOrder(option *ElementQueryOption, elements []types.UUID) error
 		}
 
 		if err := tx.Table("elements").Where("id = ?", elementID.String()).UpdateColumns(data).Error; err != nil {
-			tx.Rollback()
-
 			switch e := err.(type) {
 			case *pq.Error:
 				switch e.Code.Name() {
@@ -260,9 +399,6 @@ func UpdateElementOrder(option *ElementQueryOption, elements []types.UUID) error
 		}
 	}
 
-	// Commit the transaction
-	tx.Commit()
-
 	return nil
 }
 
@@ -271,3 +407,301 @@ func GetProjectIDForElement(elementID types.UUID) types.UUID {
 	db.Raw("SELECT project_id FROM elements WHERE id = ?", elementID.String()).Row().Scan(&projectID)
 	return projectID
 }
+
+// ElementPosition is the target position to copy or move an element to.
+type ElementPosition struct {
+	// ElementID is the parent element. The element is placed at the root of
+	// the project if it's not valid.
+	ElementID types.UUID
+
+	// Index is the position in the siblings starting from 1. The element is
+	// appended if it's 0 or larger than the number of siblings.
+	Index int
+}
+
+func convertElementError(err error) error {
+	switch e := err.(type) {
+	case *pq.Error:
+		switch e.Code.Name() {
+		case ForeignKeyViolation:
+			return &util.APIError{
+				Code:    util.ElementNotOwnedByProjectError,
+				Message: "The parent element is not owned by the project.",
+				Field:   "element_id",
+			}
+		}
+	}
+
+	return err
+}
+
+// placeElement moves the element to the position in the transaction. Siblings
+// after the position are shifted.
+func placeElement(tx *gorm.DB, e *Element, pos *ElementPosition) error {
+	var max int
+
+	if err := checkParentType(tx, pos.ElementID, e.Type); err != nil {
+		return err
+	}
+
+	where := "project_id = ? AND id <> ? AND "
+	args := []interface{}{e.ProjectID.String(), e.ID.String()}
+
+	if pos.ElementID.Valid() {
+		where += "element_id = ?"
+		args = append(args, pos.ElementID.String())
+	} else {
+		where += "element_id IS NULL"
+	}
+
+	if err := tx.Raw("SELECT COALESCE(MAX(index), 0) FROM elements WHERE "+where, args...).Row().Scan(&max); err != nil {
+		return err
+	}
+
+	index := pos.Index
+
+	if index <= 0 || index > max {
+		index = max + 1
+	} else {
+		args = append(args, index)
+
+		if err := tx.Exec("UPDATE elements SET index = index + 1 WHERE "+where+" AND index >= ?", args...).Error; err != nil {
+			return err
+		}
+	}
+
+	data := map[string]interface{}{
+		"element_id": pos.ElementID,
+		"index":      index,
+	}
+
+	if err := tx.Table("elements").Where("id = ?", e.ID.String()).UpdateColumns(data).Error; err != nil {
+		return convertElementError(err)
+	}
+
+	e.ElementID = pos.ElementID
+	e.Index = index
+
+	return nil
+}
+
+// CopyElement copies the element with all descendants and events to the
+// position in one transaction. The copied element is returned with its
+// descendants.
+func CopyElement(element *Element, pos *ElementPosition) (*Element, error) {
+	events, err := GetEventList(element.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	children, err := GetElementList(&ElementQueryOption{
+		ProjectID:  &element.ProjectID,
+		ElementID:  &element.ID,
+		Flat:       true,
+		WithEvents: true,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	root := *element
+	root.Index = 0
+	root.Events = events
+
+	// Descendants are sorted by depth, so parents are always in front
+	list := append([]*Element{&root}, children...)
+	ids := idMap{}
+	tx := db.Begin()
+
+	if err := copyElements(tx, element.ProjectID, list, ids, true); err != nil {
+		tx.Rollback()
+		return nil, convertElementError(err)
+	}
+
+	if err := placeElement(tx, &root, pos); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := recordRevision(tx, root.ProjectID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Commit the transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	root.Elements = buildElementTree(children, root.ID)
+	publishChange(root.ProjectID, ChangeCreate, ChangeTypeElement, root.ID, root)
+
+	return &root, nil
+}
+
+// isDescendant returns true if the element is the ancestor itself or one of
+// its descendants.
+func isDescendant(tx *gorm.DB, elementID, ancestorID types.UUID) (bool, error) {
+	var result bool
+
+	raw := `WITH RECURSIVE tree AS (
+SELECT id FROM elements WHERE id = ?
+UNION ALL
+SELECT elements.id FROM elements, tree WHERE elements.element_id = tree.id)
+SELECT exists(SELECT 1 FROM tree WHERE id = ?);`
+
+	if err := tx.Raw(raw, ancestorID.String(), elementID.String()).Row().Scan(&result); err != nil {
+		return false, err
+	}
+
+	return result, nil
+}
+
+// MoveElement moves the element to the position in one transaction. The
+// element can't be moved into itself or its descendants.
+func MoveElement(element *Element, pos *ElementPosition) error {
+	tx := db.Begin()
+
+	if pos.ElementID.Valid() {
+		circular, err := isDescendant(tx, pos.ElementID, element.ID)
+
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if circular {
+			tx.Rollback()
+			return &util.APIError{
+				Code:    util.ElementCircularError,
+				Message: "The element can't be moved into itself or its descendants.",
+				Field:   "element_id",
+			}
+		}
+	}
+
+	if err := placeElement(tx, element, pos); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Replace the main screen if it's not a screen anymore
+	if pos.ElementID.Valid() {
+		err := tx.Exec(`UPDATE projects SET main_screen = (
+  SELECT id FROM elements
+  WHERE project_id = ? AND element_id IS NULL
+  ORDER BY index
+  LIMIT 1
+) WHERE id = ? AND main_screen = ?`,
+			element.ProjectID.String(),
+			element.ProjectID.String(),
+			element.ID.String()).Error
+
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := recordRevision(tx, element.ProjectID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Commit the transaction
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	publishChange(element.ProjectID, ChangeUpdate, ChangeTypeElement, element.ID, *element)
+	return nil
+}
+
+// PatchElement applies the patch to the attributes and styles of the element
+// in one transaction. The row is locked during patching, so concurrent patches
+// on different keys won't overwrite each other. The patch function receives a
+// document like {"attributes": {...}, "styles": {...}} and returns the new
+// document. The precondition, if any, is checked with the locked row.
+func PatchElement(id types.UUID, patch func(doc interface{}) (interface{}, error), pre Precondition) (*Element, error) {
+	var list []*Element
+	tx := db.Begin()
+
+	if err := tx.Raw("SELECT * FROM elements WHERE id = ? FOR UPDATE", id.String()).Find(&list).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if len(list) == 0 {
+		tx.Rollback()
+		return nil, gorm.RecordNotFound
+	}
+
+	e := list[0]
+
+	if pre != nil {
+		if err := pre(e.UpdatedAt.Time); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	doc := map[string]interface{}{
+		"attributes": map[string]interface{}(e.Attributes),
+		"styles":     map[string]interface{}(e.Styles),
+	}
+
+	result, err := patch(doc)
+
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	obj, _ := result.(map[string]interface{})
+
+	for _, key := range []string{"attributes", "styles"} {
+		if _, ok := obj[key].(map[string]interface{}); !ok {
+			tx.Rollback()
+			return nil, &util.APIError{
+				Field:   "/" + key,
+				Code:    util.PatchError,
+				Message: "The " + key + " must be an object.",
+			}
+		}
+	}
+
+	prev := *e
+	e.Attributes = obj["attributes"].(map[string]interface{})
+	e.Styles = obj["styles"].(map[string]interface{})
+
+	if err := e.validate(&prev); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Save(e).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := saveAssetRefs(tx, e); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := recordRevision(tx, e.ProjectID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Commit the transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	publishChange(e.ProjectID, ChangeUpdate, ChangeTypeElement, e.ID, *e)
+
+	return e, nil
+}
@@ -0,0 +1,264 @@
+package model
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	"github.com/tkusd/server/model/types"
+	"github.com/tkusd/server/util"
+)
+
+// assetFormat is the schema format of asset references in attributes and
+// styles.
+const assetFormat = "asset"
+
+// ElementAsset is a reference to an asset from the attributes or styles of an
+// element. Field is the path of the reference, e.g. "attributes.asset".
+type ElementAsset struct {
+	ElementID types.UUID `json:"element_id"`
+	AssetID   types.UUID `json:"asset_id"`
+	Field     string     `json:"field"`
+}
+
+// AssetUsage is an element which refers to an asset.
+type AssetUsage struct {
+	ElementID types.UUID `json:"element_id"`
+	Name      string     `json:"name"`
+	Type      string     `json:"type"`
+	Field     string     `json:"field"`
+}
+
+// assetRefs returns the asset references in the attributes and styles of the
+// element.
+func (e *Element) assetRefs() []*ElementAsset {
+	var refs []*ElementAsset
+	t := GetElementType(e.Type)
+
+	if t == nil {
+		return nil
+	}
+
+	add := func(value, field string) {
+		id := types.ParseUUID(value)
+
+		// Legacy values which are not UUIDs are kept as they are
+		if !id.Valid() {
+			return
+		}
+
+		refs = append(refs, &ElementAsset{
+			ElementID: e.ID,
+			AssetID:   id,
+			Field:     field,
+		})
+	}
+
+	t.Attributes.FindFormat(map[string]interface{}(e.Attributes), "attributes", assetFormat, add)
+	t.Styles.FindFormat(map[string]interface{}(e.Styles), "styles", assetFormat, add)
+
+	return refs
+}
+
+// saveAssetRefs replaces the asset references of the element in the
+// transaction. Referred assets must belong to the project of the element.
+func saveAssetRefs(tx *gorm.DB, e *Element) error {
+	refs := e.assetRefs()
+
+	for _, ref := range refs {
+		var projectID types.UUID
+
+		tx.Raw("SELECT project_id FROM assets WHERE id = ?", ref.AssetID.String()).Row().Scan(&projectID)
+
+		if !projectID.Valid() {
+			return &util.APIError{
+				Field:   ref.Field,
+				Code:    util.AssetNotFound,
+				Message: "Asset not found.",
+			}
+		}
+
+		if !projectID.Equal(e.ProjectID) {
+			return &util.APIError{
+				Field:   ref.Field,
+				Code:    util.AssetNotOwnedByProjectError,
+				Message: "The asset is not owned by the project.",
+			}
+		}
+	}
+
+	if err := tx.Exec("DELETE FROM element_assets WHERE element_id = ?", e.ID.String()).Error; err != nil {
+		return err
+	}
+
+	for _, ref := range refs {
+		err := tx.Exec("INSERT INTO element_assets (element_id, asset_id, field) VALUES (?, ?, ?)",
+			ref.ElementID.String(),
+			ref.AssetID.String(),
+			ref.Field).Error
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fieldPaths sorts paths of fields by their segments. Indexes of arrays are
+// compared as numbers, so "styles.list.10" comes after "styles.list.9".
+type fieldPaths []string
+
+func (p fieldPaths) Len() int {
+	return len(p)
+}
+
+func (p fieldPaths) Swap(i, j int) {
+	p[i], p[j] = p[j], p[i]
+}
+
+func (p fieldPaths) Less(i, j int) bool {
+	a := strings.Split(p[i], ".")
+	b := strings.Split(p[j], ".")
+
+	for k := 0; k < len(a) && k < len(b); k++ {
+		if a[k] == b[k] {
+			continue
+		}
+
+		x, xerr := strconv.Atoi(a[k])
+		y, yerr := strconv.Atoi(b[k])
+
+		if xerr == nil && yerr == nil {
+			return x < y
+		}
+
+		return a[k] < b[k]
+	}
+
+	return len(a) < len(b)
+}
+
+// removeFields removes the values at the fields, e.g. "styles.background_image",
+// from the attributes and styles of the element. Fields are removed in
+// descending order, so indexes of arrays are not shifted by former ones.
+func (e *Element) removeFields(fields []string) error {
+	doc := map[string]interface{}{
+		"attributes": map[string]interface{}(e.Attributes),
+		"styles":     map[string]interface{}(e.Styles),
+	}
+
+	sorted := make([]string, len(fields))
+	copy(sorted, fields)
+	sort.Sort(sort.Reverse(fieldPaths(sorted)))
+
+	ops := make([]util.PatchOperation, len(sorted))
+
+	for i, field := range sorted {
+		ops[i] = util.PatchOperation{
+			Op:   "remove",
+			Path: "/" + strings.Replace(field, ".", "/", -1),
+		}
+	}
+
+	result, err := util.ApplyJSONPatch(doc, ops)
+
+	if err != nil {
+		return err
+	}
+
+	obj := result.(map[string]interface{})
+	e.Attributes = obj["attributes"].(map[string]interface{})
+	e.Styles = obj["styles"].(map[string]interface{})
+
+	return nil
+}
+
+// removeMissingAssetRefs removes references to assets which don't exist in the
+// project of the element, e.g. in snapshots recorded before the assets are
+// deleted.
+func removeMissingAssetRefs(tx *gorm.DB, e *Element) error {
+	var missing []string
+
+	for _, ref := range e.assetRefs() {
+		var projectID types.UUID
+
+		tx.Raw("SELECT project_id FROM assets WHERE id = ?", ref.AssetID.String()).Row().Scan(&projectID)
+
+		if !projectID.Equal(e.ProjectID) {
+			missing = append(missing, ref.Field)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	return e.removeFields(missing)
+}
+
+// clearAssetRefs removes references to the asset from attributes and styles of
+// elements in the transaction, so they won't refer to the asset after it's
+// deleted. Updated elements are returned.
+func clearAssetRefs(tx *gorm.DB, assetID types.UUID) ([]*Element, error) {
+	var refs []*ElementAsset
+	var elements []*Element
+	fields := map[string][]string{}
+
+	if err := tx.Where("asset_id = ?", assetID.String()).Find(&refs).Error; err != nil {
+		return nil, err
+	}
+
+	for _, ref := range refs {
+		id := ref.ElementID.String()
+		fields[id] = append(fields[id], ref.Field)
+	}
+
+	for id, list := range fields {
+		var result []*Element
+
+		if err := tx.Raw("SELECT * FROM elements WHERE id = ? FOR UPDATE", id).Find(&result).Error; err != nil {
+			return nil, err
+		}
+
+		if len(result) == 0 {
+			continue
+		}
+
+		e := result[0]
+
+		if err := e.removeFields(list); err != nil {
+			return nil, err
+		}
+
+		if err := tx.Save(e).Error; err != nil {
+			return nil, err
+		}
+
+		elements = append(elements, e)
+	}
+
+	return elements, nil
+}
+
+// GetAssetUsages gets the elements which refer to the asset.
+func GetAssetUsages(assetID types.UUID) ([]*AssetUsage, error) {
+	var list []*AssetUsage
+
+	raw := `SELECT elements.id AS element_id, elements.name, elements.type, element_assets.field
+FROM element_assets
+JOIN elements ON elements.id = element_assets.element_id
+WHERE element_assets.asset_id = ?
+ORDER BY elements.name, element_assets.field;`
+
+	if err := db.Raw(raw, assetID.String()).Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	if list == nil {
+		list = make([]*AssetUsage, 0)
+	}
+
+	return list, nil
+}
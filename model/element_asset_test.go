@@ -0,0 +1,160 @@
+package model
+
+import (
+	"log"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tkusd/server/model/types"
+	"github.com/tkusd/server/util"
+)
+
+func TestElementAsset(t *testing.T) {
+	user, err := createTestUser(fixtureUsers[0])
+	defer user.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	project, err := createTestProject(user)
+	defer project.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	other, err := createTestProject(user)
+	defer other.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	asset := &Asset{Name: "logo.png", ProjectID: project.ID}
+	otherAsset := &Asset{Name: "logo.png", ProjectID: other.ID}
+
+	if err := asset.Save(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := otherAsset.Save(); err != nil {
+		log.Fatal(err)
+	}
+
+	screen, err := createTestElement(project)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	Convey("Record references", t, func() {
+		image := &Element{
+			ProjectID:  project.ID,
+			ElementID:  screen.ID,
+			Name:       "Logo",
+			Type:       types.ElementTypeImage,
+			Attributes: types.JSONObject{"asset": asset.ID.String()},
+		}
+
+		So(image.Save(), ShouldBeNil)
+
+		usages, err := GetAssetUsages(asset.ID)
+		So(err, ShouldBeNil)
+		So(usages, ShouldResemble, []*AssetUsage{
+			{ElementID: image.ID, Name: "Logo", Type: types.ElementTypeImage, Field: "attributes.asset"},
+		})
+
+		image.Attributes = types.JSONObject{}
+		So(image.Save(), ShouldBeNil)
+
+		usages, _ = GetAssetUsages(asset.ID)
+		So(usages, ShouldBeEmpty)
+	})
+
+	Convey("Reject assets of other projects", t, func() {
+		element := &Element{
+			ProjectID: project.ID,
+			Type:      types.ElementTypeScreen,
+			Styles:    types.JSONObject{"background_image": otherAsset.ID.String()},
+		}
+
+		So(element.Save(), ShouldResemble, &util.APIError{
+			Field:   "styles.background_image",
+			Code:    util.AssetNotOwnedByProjectError,
+			Message: "The asset is not owned by the project.",
+		})
+	})
+
+	Convey("Reject assets which don't exist", t, func() {
+		element := &Element{
+			ProjectID:  project.ID,
+			ElementID:  screen.ID,
+			Type:       types.ElementTypeImage,
+			Attributes: types.JSONObject{"asset": types.NewRandomUUID().String()},
+		}
+
+		So(element.Save(), ShouldResemble, &util.APIError{
+			Field:   "attributes.asset",
+			Code:    util.AssetNotFound,
+			Message: "Asset not found.",
+		})
+	})
+
+	Convey("Remove references when the asset is deleted", t, func() {
+		logo := &Asset{Name: "logo.png", ProjectID: project.ID}
+		So(logo.Save(), ShouldBeNil)
+
+		image := &Element{
+			ProjectID:  project.ID,
+			ElementID:  screen.ID,
+			Type:       types.ElementTypeImage,
+			Attributes: types.JSONObject{"asset": logo.ID.String(), "alt": "Logo"},
+			Styles:     types.JSONObject{"background_image": logo.ID.String()},
+		}
+		So(image.Save(), ShouldBeNil)
+
+		list, _ := GetRevisionList(project.ID)
+		revision, _ := GetRevision(list[0].ID)
+
+		So(logo.Delete(), ShouldBeNil)
+
+		image, _ = GetElement(image.ID)
+		So(image.Attributes, ShouldResemble, types.JSONObject{"alt": "Logo"})
+		So(image.Styles, ShouldResemble, types.JSONObject{})
+
+		// The element can be saved again
+		image.Name = "Logo"
+		So(image.Save(), ShouldBeNil)
+
+		// Missing assets are skipped when restored
+		_, err := RestoreRevision(revision)
+		So(err, ShouldBeNil)
+
+		image, _ = GetElement(image.ID)
+		So(image.Attributes, ShouldResemble, types.JSONObject{"alt": "Logo"})
+	})
+}
+
+func TestRemoveFields(t *testing.T) {
+	Convey("Indexes of arrays are removed in descending order", t, func() {
+		list := make([]interface{}, 12)
+
+		for i := range list {
+			list[i] = float64(i)
+		}
+
+		e := &Element{
+			Attributes: types.JSONObject{"list": list},
+			Styles:     types.JSONObject{"background_image": "a"},
+		}
+
+		err := e.removeFields([]string{"attributes.list.2", "styles.background_image", "attributes.list.10"})
+		So(err, ShouldBeNil)
+		So(e.Attributes["list"], ShouldResemble, []interface{}{
+			float64(0), float64(1), float64(3), float64(4), float64(5),
+			float64(6), float64(7), float64(8), float64(9), float64(11),
+		})
+		So(e.Styles, ShouldResemble, types.JSONObject{})
+	})
+}
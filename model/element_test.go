@@ -1,7 +1,7 @@
 package model
 
 import (
-	"fmt"
+	"errors"
 	"log"
 	"strings"
 	"testing"
@@ -84,7 +84,7 @@ func TestElement(t *testing.T) {
 		})
 
 		Convey("Unsupported element type", func() {
-			element := &Element{Type: -1}
+			element := &Element{Type: "unknown"}
 			err := element.Save()
 			So(err, ShouldResemble, &util.APIError{
 				Field:   "type",
@@ -118,7 +118,7 @@ func TestElement(t *testing.T) {
 			So(e.ProjectID, ShouldResemble, project.ID)
 			So(e.Type, ShouldEqual, element.Type)
 			So(e.Attributes, ShouldResemble, element.Attributes)
-			So(e.OrderID, ShouldEqual, 1)
+			So(e.Index, ShouldEqual, 1)
 		})
 
 		Convey("Order should be increased", func() {
@@ -144,8 +144,8 @@ func TestElement(t *testing.T) {
 				log.Fatal(err)
 			}
 
-			So(e1.OrderID, ShouldEqual, 1)
-			So(e2.OrderID, ShouldEqual, 2)
+			So(e1.Index, ShouldEqual, 1)
+			So(e2.Index, ShouldEqual, 2)
 		})
 
 		Convey("Update", func() {
@@ -385,58 +385,252 @@ func TestUpdateElementOrder(t *testing.T) {
 		log.Fatal(err)
 	}
 
-	Convey("Not one of the children", t, func() {
+	Convey("Success", t, func() {
 		option := &ElementQueryOption{
-			ElementID: &e3.ID,
+			ElementID: &e1.ID,
 		}
-		tree := []ElementTreeItem{
-			ElementTreeItem{ID: e1.ID},
+		err := UpdateElementOrder(option, []types.UUID{e3.ID, e4.ID, e2.ID})
+
+		if err != nil {
+			log.Fatal(err)
 		}
-		err := UpdateElementOrder(option, tree)
 
-		So(err, ShouldResemble, &util.APIError{
-			Code:    util.ElementNotInTreeError,
-			Message: fmt.Sprintf("Element %s is not a child of the specified element.", e1.ID.String()),
-		})
+		list, _ := GetElementList(option)
+
+		So(len(list), ShouldEqual, 3)
+		So(list[0].ID, ShouldResemble, e3.ID)
+		So(list[1].ID, ShouldResemble, e4.ID)
+		So(list[1].ElementID, ShouldResemble, e1.ID)
+		So(list[2].ID, ShouldResemble, e2.ID)
 	})
 
-	Convey("Not complete list of children", t, func() {
+	Convey("Publish the new order", t, func() {
+		sub := Subscribe(project.ID)
+		defer sub.Close()
+
 		option := &ElementQueryOption{
+			ProjectID: &project.ID,
 			ElementID: &e1.ID,
 		}
-		tree := []ElementTreeItem{
-			ElementTreeItem{ID: e2.ID},
-			ElementTreeItem{ID: e4.ID},
-		}
-		err := UpdateElementOrder(option, tree)
+		elements := []types.UUID{e2.ID, e3.ID, e4.ID}
+		err := UpdateElementOrder(option, elements)
+		So(err, ShouldBeNil)
+
+		change := <-sub.C
+		So(change.Action, ShouldEqual, ChangeReorder)
+		So(change.Type, ShouldEqual, ChangeTypeElement)
+		So(change.ID, ShouldResemble, e1.ID)
+		So(change.Data, ShouldResemble, &ElementOrder{Elements: elements})
+	})
+}
+
+func TestPatchElement(t *testing.T) {
+	user, err := createTestUser(fixtureUsers[0])
+	defer user.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	project, err := createTestProject(user)
+	defer project.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	element, err := createTestElement(project)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	element.Attributes = types.JSONObject{"text": "foo", "size": float64(1)}
+	element.Save()
+
+	Convey("JSON Patch", t, func() {
+		result, err := PatchElement(element.ID, func(doc interface{}) (interface{}, error) {
+			return util.ApplyJSONPatch(doc, []util.PatchOperation{
+				{Op: "remove", Path: "/attributes/size"},
+			})
+		}, nil)
+
+		So(err, ShouldBeNil)
+		So(result.Attributes, ShouldResemble, types.JSONObject{"text": "foo"})
+	})
+
+	Convey("Merge patch", t, func() {
+		result, err := PatchElement(element.ID, func(doc interface{}) (interface{}, error) {
+			return util.ApplyMergePatch(doc, map[string]interface{}{
+				"styles": map[string]interface{}{"color": "red"},
+			})
+		}, nil)
+
+		So(err, ShouldBeNil)
+		So(result.Attributes, ShouldResemble, types.JSONObject{"text": "foo"})
+		So(result.Styles, ShouldResemble, types.JSONObject{"color": "red"})
+	})
+
+	Convey("Invalid path", t, func() {
+		_, err := PatchElement(element.ID, func(doc interface{}) (interface{}, error) {
+			return util.ApplyJSONPatch(doc, []util.PatchOperation{
+				{Op: "remove", Path: "/attributes/foo"},
+			})
+		}, nil)
 
 		So(err, ShouldResemble, &util.APIError{
-			Code:    util.ElementTreeNotCompletedError,
-			Message: "You didn't provide the full list of children.",
+			Field:   "/attributes/foo",
+			Code:    util.PatchError,
+			Message: "Path does not exist.",
 		})
 	})
 
-	Convey("Success", t, func() {
-		option := &ElementQueryOption{
-			ElementID: &e1.ID,
+	Convey("Attributes must be an object", t, func() {
+		_, err := PatchElement(element.ID, func(doc interface{}) (interface{}, error) {
+			return util.ApplyMergePatch(doc, map[string]interface{}{"attributes": "foo"})
+		}, nil)
+
+		So(err.(*util.APIError).Field, ShouldEqual, "/attributes")
+	})
+
+	Convey("Precondition is checked with the locked row", t, func() {
+		current, _ := GetElement(element.ID)
+		precondition := errors.New("precondition failed")
+		var updatedAt time.Time
+
+		_, err := PatchElement(element.ID, func(doc interface{}) (interface{}, error) {
+			return doc, nil
+		}, func(t time.Time) error {
+			updatedAt = t
+			return precondition
+		})
+
+		So(err, ShouldEqual, precondition)
+		So(updatedAt.Equal(current.UpdatedAt.Time), ShouldBeTrue)
+	})
+}
+
+func TestElementType(t *testing.T) {
+	user, err := createTestUser(fixtureUsers[0])
+	defer user.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	project, err := createTestProject(user)
+	defer project.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	screen, err := createTestElement(project)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	text, err := createTestChildElement(screen)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	Convey("Attributes are validated", t, func() {
+		element := &Element{
+			ProjectID:  project.ID,
+			Type:       types.ElementTypeButton,
+			Attributes: types.JSONObject{"text": float64(1)},
 		}
-		tree := []ElementTreeItem{
-			ElementTreeItem{ID: e3.ID},
-			ElementTreeItem{ID: e4.ID},
-			ElementTreeItem{ID: e2.ID},
+		err := element.Save()
+		So(err, ShouldResemble, &util.APIError{
+			Field:   "attributes.text",
+			Code:    util.TypeError,
+			Message: "attributes.text must be a string.",
+		})
+	})
+
+	Convey("Styles are validated", t, func() {
+		element := &Element{
+			ProjectID: project.ID,
+			Type:      types.ElementTypeText,
+			Styles:    types.JSONObject{"text_align": "top"},
 		}
-		err := UpdateElementOrder(option, tree)
+		err := element.Save()
+		So(err, ShouldResemble, &util.APIError{
+			Field:   "styles.text_align",
+			Code:    util.ValueError,
+			Message: "styles.text_align must be one of left, center, right.",
+		})
+	})
 
-		if err != nil {
-			log.Fatal(err)
+	Convey("Child type must be allowed by the parent", t, func() {
+		element := &Element{
+			ProjectID: project.ID,
+			ElementID: text.ID,
+			Type:      types.ElementTypeButton,
 		}
+		err := element.Save()
+		So(err, ShouldResemble, &util.APIError{
+			Field:   "element_id",
+			Code:    util.ElementChildTypeError,
+			Message: "Element type button can't be placed in text.",
+		})
+	})
 
-		list, _ := GetElementList(option)
+	Convey("Type can't be changed if children are not allowed", t, func() {
+		screen.Type = types.ElementTypeImage
+		err := screen.Save()
+		So(err, ShouldResemble, &util.APIError{
+			Field:   "type",
+			Code:    util.ElementChildTypeError,
+			Message: "Element type image doesn't allow children of type text.",
+		})
+	})
 
-		So(len(list), ShouldEqual, 3)
-		So(list[0].ID, ShouldResemble, e3.ID)
-		So(list[1].ID, ShouldResemble, e4.ID)
-		So(list[1].ElementID, ShouldResemble, e1.ID)
-		So(list[2].ID, ShouldResemble, e2.ID)
+	Convey("The first invalid property in order is reported", t, func() {
+		element := &Element{
+			ProjectID: project.ID,
+			Type:      types.ElementTypeText,
+			Styles:    types.JSONObject{"width": "a", "opacity": "b", "color": float64(1)},
+		}
+		err := element.Save()
+		So(err.(*util.APIError).Field, ShouldEqual, "styles.color")
+	})
+
+	Convey("Legacy data is kept if not changed", t, func() {
+		legacy, _ := createTestChildElement(screen)
+		db.Exec("UPDATE elements SET type = 'legacy', styles = ? WHERE id = ?", `{"background_image": "bg.png"}`, legacy.ID.String())
+		legacy, _ = GetElement(legacy.ID)
+
+		legacy.Name = "Legacy"
+		So(legacy.Save(), ShouldBeNil)
+
+		legacy.Styles["background_image"] = "bg2.png"
+		So(legacy.Save(), ShouldBeNil)
+
+		legacy.Type = "legacy2"
+		So(legacy.Save().(*util.APIError).Code, ShouldEqual, util.UnsupportedElementTypeError)
+
+		// Values of supported types are still checked after changed
+		db.Exec("UPDATE elements SET styles = ? WHERE id = ?", `{"background_image": "bg.png"}`, text.ID.String())
+		text, _ = GetElement(text.ID)
+		text.Styles["color"] = "red"
+		So(text.Save(), ShouldBeNil)
+
+		text.Styles["background_image"] = "bg2.png"
+		So(text.Save(), ShouldResemble, &util.APIError{
+			Field:   "styles.background_image",
+			Code:    util.UUIDError,
+			Message: "UUID is invalid.",
+		})
+	})
+
+	Convey("List element types", t, func() {
+		list := GetElementTypeList()
+		So(len(list), ShouldEqual, 7)
+		So(list[0].Name, ShouldEqual, types.ElementTypeButton)
+		So(GetElementType(types.ElementTypeScreen).AllowsChild(types.ElementTypeText), ShouldBeTrue)
 	})
 }
@@ -0,0 +1,284 @@
+package model
+
+import (
+	"sort"
+
+	"github.com/jinzhu/gorm"
+	"github.com/tkusd/server/model/types"
+	"github.com/tkusd/server/util"
+)
+
+// ElementType describes a type of elements. Attributes and styles of elements
+// are validated against the schemas and only elements of types in Children
+// can be placed in elements of this type.
+type ElementType struct {
+	Name       string       `json:"name"`
+	Attributes *util.Schema `json:"attributes"`
+	Styles     *util.Schema `json:"styles"`
+	Children   []string     `json:"children"`
+}
+
+// AllowsChild returns true if elements of the type can be placed in elements
+// of this type.
+func (t *ElementType) AllowsChild(name string) bool {
+	for _, child := range t.Children {
+		if child == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func floatPtr(n float64) *float64 {
+	return &n
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func stringSchema(max int) *util.Schema {
+	return &util.Schema{Type: "string", MaxLength: intPtr(max)}
+}
+
+func objectSchema(properties map[string]*util.Schema) *util.Schema {
+	return &util.Schema{Type: "object", Properties: properties}
+}
+
+func styleSchema(properties map[string]*util.Schema) *util.Schema {
+	base := map[string]*util.Schema{
+		"color":            stringSchema(64),
+		"background_color": stringSchema(64),
+		"width":            {Type: "number", Minimum: floatPtr(0)},
+		"height":           {Type: "number", Minimum: floatPtr(0)},
+		"margin":           {Type: "number"},
+		"padding":          {Type: "number", Minimum: floatPtr(0)},
+		"opacity":          {Type: "number", Minimum: floatPtr(0), Maximum: floatPtr(1)},
+		"background_image": {Type: "string", Format: "asset"},
+	}
+
+	for key, prop := range properties {
+		base[key] = prop
+	}
+
+	return objectSchema(base)
+}
+
+func textStyleSchema() *util.Schema {
+	return styleSchema(map[string]*util.Schema{
+		"font_size":   {Type: "number", Minimum: floatPtr(0)},
+		"font_weight": {Type: "string", Enum: []interface{}{"normal", "bold"}},
+		"text_align":  {Type: "string", Enum: []interface{}{"left", "center", "right"}},
+	})
+}
+
+// Types which can be placed in screens and containers.
+var widgetTypes = []string{
+	types.ElementTypeView,
+	types.ElementTypeList,
+	types.ElementTypeText,
+	types.ElementTypeButton,
+	types.ElementTypeImage,
+	types.ElementTypeInput,
+}
+
+var elementTypes = map[string]*ElementType{
+	types.ElementTypeScreen: {
+		Attributes: objectSchema(map[string]*util.Schema{
+			"title": stringSchema(255),
+		}),
+		Styles:   styleSchema(nil),
+		Children: widgetTypes,
+	},
+	types.ElementTypeView: {
+		Attributes: objectSchema(nil),
+		Styles:     styleSchema(nil),
+		Children:   widgetTypes,
+	},
+	types.ElementTypeList: {
+		Attributes: objectSchema(map[string]*util.Schema{
+			"divider": {Type: "boolean"},
+		}),
+		Styles:   styleSchema(nil),
+		Children: widgetTypes,
+	},
+	types.ElementTypeText: {
+		Attributes: objectSchema(map[string]*util.Schema{
+			"text": stringSchema(10000),
+		}),
+		Styles: textStyleSchema(),
+	},
+	types.ElementTypeButton: {
+		Attributes: objectSchema(map[string]*util.Schema{
+			"text":     stringSchema(255),
+			"disabled": {Type: "boolean"},
+		}),
+		Styles: textStyleSchema(),
+	},
+	types.ElementTypeImage: {
+		Attributes: objectSchema(map[string]*util.Schema{
+			"asset": {Type: "string", Format: "asset"},
+			"alt":   stringSchema(255),
+		}),
+		Styles: styleSchema(map[string]*util.Schema{
+			"resize_mode": {Type: "string", Enum: []interface{}{"cover", "contain", "stretch"}},
+		}),
+	},
+	types.ElementTypeInput: {
+		Attributes: objectSchema(map[string]*util.Schema{
+			"type":        {Type: "string", Enum: []interface{}{"text", "password", "email", "number"}},
+			"placeholder": stringSchema(255),
+			"value":       stringSchema(10000),
+			"disabled":    {Type: "boolean"},
+		}),
+		Styles: textStyleSchema(),
+	},
+}
+
+func init() {
+	for name, t := range elementTypes {
+		t.Name = name
+
+		if t.Children == nil {
+			t.Children = []string{}
+		}
+	}
+}
+
+type elementTypeList []*ElementType
+
+func (list elementTypeList) Len() int {
+	return len(list)
+}
+
+func (list elementTypeList) Less(i, j int) bool {
+	return list[i].Name < list[j].Name
+}
+
+func (list elementTypeList) Swap(i, j int) {
+	list[i], list[j] = list[j], list[i]
+}
+
+// GetElementType returns the element type with the name. It returns nil if the
+// type is not supported.
+func GetElementType(name string) *ElementType {
+	return elementTypes[name]
+}
+
+// GetElementTypeList returns all element types sorted by name.
+func GetElementTypeList() []*ElementType {
+	list := make(elementTypeList, 0, len(elementTypes))
+
+	for _, t := range elementTypes {
+		list = append(list, t)
+	}
+
+	sort.Sort(list)
+	return list
+}
+
+// validateType checks the type, attributes and styles of the element. Values
+// which are not changed from prev are not checked, and legacy types which are
+// not supported anymore are kept if the type is not changed.
+func (e *Element) validateType(prev *Element) error {
+	t := GetElementType(e.Type)
+	sameType := prev != nil && prev.Type == e.Type
+
+	if t == nil {
+		if sameType {
+			return nil
+		}
+
+		return &util.APIError{
+			Field:   "type",
+			Code:    util.UnsupportedElementTypeError,
+			Message: "Unsupported element type.",
+		}
+	}
+
+	if !sameType {
+		if err := t.Attributes.Validate(map[string]interface{}(e.Attributes), "attributes"); err != nil {
+			return err
+		}
+
+		return t.Styles.Validate(map[string]interface{}(e.Styles), "styles")
+	}
+
+	if err := t.Attributes.ValidateChanges(map[string]interface{}(e.Attributes), map[string]interface{}(prev.Attributes), "attributes"); err != nil {
+		return err
+	}
+
+	return t.Styles.ValidateChanges(map[string]interface{}(e.Styles), map[string]interface{}(prev.Styles), "styles")
+}
+
+// checkParentType returns an error if the parent element doesn't allow
+// children of the type. Nothing is checked if the parent doesn't exist or
+// either type is a legacy one which is not supported anymore.
+func checkParentType(tx *gorm.DB, parentID types.UUID, name string) error {
+	var parentType string
+
+	if !parentID.Valid() || GetElementType(name) == nil {
+		return nil
+	}
+
+	tx.Raw("SELECT type FROM elements WHERE id = ?", parentID.String()).Row().Scan(&parentType)
+
+	if t := GetElementType(parentType); t != nil && !t.AllowsChild(name) {
+		return &util.APIError{
+			Field:   "element_id",
+			Code:    util.ElementChildTypeError,
+			Message: "Element type " + name + " can't be placed in " + parentType + ".",
+		}
+	}
+
+	return nil
+}
+
+// checkChildTypes returns an error if the element has children which are not
+// allowed by its type, e.g. after the type is changed. Children of legacy
+// types are ignored.
+func checkChildTypes(tx *gorm.DB, e *Element) error {
+	var children []string
+
+	if !e.ID.Valid() {
+		return nil
+	}
+
+	t := GetElementType(e.Type)
+
+	if t == nil {
+		return nil
+	}
+
+	err := tx.Table("elements").
+		Where("element_id = ?", e.ID.String()).
+		Pluck("DISTINCT type", &children).
+		Error
+
+	if err != nil {
+		return err
+	}
+
+	for _, name := range children {
+		if GetElementType(name) != nil && !t.AllowsChild(name) {
+			return &util.APIError{
+				Field:   "type",
+				Code:    util.ElementChildTypeError,
+				Message: "Element type " + e.Type + " doesn't allow children of type " + name + ".",
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkElementTree checks that the element is allowed in its parent and its
+// children are allowed in it.
+func checkElementTree(tx *gorm.DB, e *Element) error {
+	if err := checkParentType(tx, e.ElementID, e.Type); err != nil {
+		return err
+	}
+
+	return checkChildTypes(tx, e)
+}
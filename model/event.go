@@ -14,7 +14,7 @@ type Event struct {
 	UpdatedAt types.Time `json:"updated_at"`
 }
 
-func (event *Event) Save() error {
+func (event *Event) validate() error {
 	if event.Event == "" {
 		return &util.APIError{
 			Field:   "event",
@@ -23,11 +23,78 @@ func (event *Event) Save() error {
 		}
 	}
 
-	return db.Save(event).Error
+	return nil
+}
+
+func (event *Event) Save() error {
+	return event.SaveIf(nil)
+}
+
+// SaveIf is like Save but the precondition is checked before updating.
+func (event *Event) SaveIf(pre Precondition) error {
+	if err := event.validate(); err != nil {
+		return err
+	}
+
+	action := saveAction(event.ID)
+	projectID := GetProjectIDForElement(event.ElementID)
+	tx := db.Begin()
+
+	if err := checkPrecondition(tx, "events", event.ID, pre); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Save(event).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := recordRevision(tx, projectID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Commit the transaction
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	publishChange(projectID, action, ChangeTypeEvent, event.ID, *event)
+	return nil
 }
 
 func (event *Event) Delete() error {
-	return db.Delete(event).Error
+	return event.DeleteIf(nil)
+}
+
+// DeleteIf is like Delete but the precondition is checked before deleting.
+func (event *Event) DeleteIf(pre Precondition) error {
+	projectID := GetProjectIDForElement(event.ElementID)
+	tx := db.Begin()
+
+	if err := checkPrecondition(tx, "events", event.ID, pre); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Delete(event).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := recordRevision(tx, projectID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Commit the transaction
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	publishChange(projectID, ChangeDelete, ChangeTypeEvent, event.ID, nil)
+	return nil
 }
 
 func GetEvent(id types.UUID) (*Event, error) {
@@ -40,6 +107,28 @@ func GetEvent(id types.UUID) (*Event, error) {
 	return event, nil
 }
 
+// GetSortedEventList gets the events of the element in the order. See
+// FieldSet.ParseOrder for the format of the order.
+func GetSortedEventList(elementID types.UUID, order string) ([]*Event, error) {
+	var list []*Event
+
+	orderBy, err := EventFields.ParseOrder(order, "events")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Where("element_id = ?", elementID.String()).Order(orderBy).Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	if list == nil {
+		list = make([]*Event, 0)
+	}
+
+	return list, nil
+}
+
 func GetEventList(elementID types.UUID) ([]*Event, error) {
 	var list []*Event
 
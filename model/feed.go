@@ -0,0 +1,162 @@
+package model
+
+import (
+	"sync"
+
+	"github.com/tkusd/server/model/types"
+)
+
+// Actions of changes.
+const (
+	ChangeCreate = "create"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+
+	// ChangeReorder is published when children of an element, or screens of
+	// a project, are reordered. The ID is the parent and the data is an
+	// ElementOrder.
+	ChangeReorder = "reorder"
+)
+
+// Types of changed records.
+const (
+	ChangeTypeProject = "project"
+	ChangeTypeElement = "element"
+	ChangeTypeEvent   = "event"
+	ChangeTypeAsset   = "asset"
+)
+
+// changeBufferSize is the number of changes buffered for each subscription.
+// Changes are dropped if the subscriber is too slow.
+const changeBufferSize = 64
+
+// ElementOrder is the data of reorder changes.
+type ElementOrder struct {
+	Elements []types.UUID `json:"elements"`
+}
+
+// Change is published when a record in a project is created, updated or
+// deleted.
+type Change struct {
+	Action    string      `json:"action"`
+	Type      string      `json:"type"`
+	ID        types.UUID  `json:"id"`
+	ProjectID types.UUID  `json:"project_id"`
+	Data      interface{} `json:"data,omitempty"`
+}
+
+// Subscription receives changes of a project.
+type Subscription struct {
+	C <-chan *Change
+
+	// Resync receives a value when changes are dropped because the subscriber
+	// is too slow. The subscriber should discard pending changes and reload the
+	// whole project.
+	Resync <-chan struct{}
+
+	c         chan *Change
+	resync    chan struct{}
+	projectID string
+}
+
+var feed = struct {
+	sync.RWMutex
+	subs map[string]map[*Subscription]bool
+}{
+	subs: map[string]map[*Subscription]bool{},
+}
+
+// Subscribe subscribes to changes of the project. The subscription must be
+// closed when it's not used anymore.
+func Subscribe(projectID types.UUID) *Subscription {
+	c := make(chan *Change, changeBufferSize)
+	resync := make(chan struct{}, 1)
+	sub := &Subscription{
+		C:         c,
+		Resync:    resync,
+		c:         c,
+		resync:    resync,
+		projectID: projectID.String(),
+	}
+
+	feed.Lock()
+	defer feed.Unlock()
+
+	if feed.subs[sub.projectID] == nil {
+		feed.subs[sub.projectID] = map[*Subscription]bool{}
+	}
+
+	feed.subs[sub.projectID][sub] = true
+
+	return sub
+}
+
+// Close unsubscribes and closes the channel.
+func (sub *Subscription) Close() {
+	feed.Lock()
+	defer feed.Unlock()
+
+	subs := feed.subs[sub.projectID]
+
+	if !subs[sub] {
+		return
+	}
+
+	delete(subs, sub)
+	close(sub.c)
+
+	if len(subs) == 0 {
+		delete(feed.subs, sub.projectID)
+	}
+}
+
+// publishChange sends the change to subscribers of the project without
+// blocking. Subscribers whose buffer is full are notified to resync.
+func publishChange(projectID types.UUID, action, typ string, id types.UUID, data interface{}) {
+	if !projectID.Valid() {
+		return
+	}
+
+	change := &Change{
+		Action:    action,
+		Type:      typ,
+		ID:        id,
+		ProjectID: projectID,
+		Data:      data,
+	}
+
+	feed.RLock()
+	defer feed.RUnlock()
+
+	for sub := range feed.subs[projectID.String()] {
+		select {
+		case sub.c <- change:
+		default:
+			select {
+			case sub.resync <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// publishReorder publishes the new order of children of the parent. The type
+// is project if the parent is not valid, i.e. screens are reordered.
+func publishReorder(projectID, parentID types.UUID, elements []types.UUID) {
+	typ, id := ChangeTypeElement, parentID
+
+	if !parentID.Valid() {
+		typ, id = ChangeTypeProject, projectID
+	}
+
+	publishChange(projectID, ChangeReorder, typ, id, &ElementOrder{Elements: elements})
+}
+
+// saveAction returns the action of saving a record with the ID.
+func saveAction(id types.UUID) string {
+	if id.Valid() {
+		return ChangeUpdate
+	}
+
+	return ChangeCreate
+}
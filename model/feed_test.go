@@ -0,0 +1,62 @@
+package model
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tkusd/server/model/types"
+)
+
+func TestFeed(t *testing.T) {
+	projectID := types.NewRandomUUID()
+
+	Convey("Receive changes of the project", t, func() {
+		sub := Subscribe(projectID)
+		defer sub.Close()
+
+		id := types.NewRandomUUID()
+		publishChange(projectID, ChangeCreate, ChangeTypeElement, id, nil)
+		publishChange(types.NewRandomUUID(), ChangeCreate, ChangeTypeElement, id, nil)
+
+		change := <-sub.C
+		So(change.Action, ShouldEqual, ChangeCreate)
+		So(change.Type, ShouldEqual, ChangeTypeElement)
+		So(change.ID, ShouldResemble, id)
+		So(len(sub.C), ShouldEqual, 0)
+	})
+
+	Convey("Channel is closed after unsubscribed", t, func() {
+		sub := Subscribe(projectID)
+		sub.Close()
+		sub.Close()
+
+		_, ok := <-sub.C
+		So(ok, ShouldBeFalse)
+	})
+
+	Convey("Changes are dropped if the buffer is full", t, func() {
+		sub := Subscribe(projectID)
+		defer sub.Close()
+
+		for i := 0; i < changeBufferSize+1; i++ {
+			publishChange(projectID, ChangeUpdate, ChangeTypeProject, projectID, nil)
+		}
+
+		So(len(sub.C), ShouldEqual, changeBufferSize)
+		So(len(sub.Resync), ShouldEqual, 1)
+	})
+
+	Convey("Reorder screens", t, func() {
+		sub := Subscribe(projectID)
+		defer sub.Close()
+
+		elements := []types.UUID{types.NewRandomUUID()}
+		publishReorder(projectID, types.UUID{}, elements)
+
+		change := <-sub.C
+		So(change.Action, ShouldEqual, ChangeReorder)
+		So(change.Type, ShouldEqual, ChangeTypeProject)
+		So(change.ID, ShouldResemble, projectID)
+		So(change.Data, ShouldResemble, &ElementOrder{Elements: elements})
+	})
+}
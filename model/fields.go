@@ -0,0 +1,100 @@
+package model
+
+import (
+	"strings"
+
+	"github.com/tkusd/server/util"
+)
+
+// FieldSet is the whitelist of fields of a resource. Only fields in the set
+// can be selected and only sortable fields can be used to sort. Fields are the
+// keys in JSON, which are also the column names of sortable fields.
+type FieldSet struct {
+	Fields   []string
+	Sortable []string
+}
+
+// Field sets of resources.
+var (
+	ProjectFields = &FieldSet{
+		Fields:   []string{"id", "title", "description", "user_id", "created_at", "updated_at", "is_private", "main_screen", "theme", "owner", "stats"},
+		Sortable: []string{"created_at", "updated_at", "title", "theme"},
+	}
+
+	ElementFields = &FieldSet{
+		Fields: []string{"id", "project_id", "element_id", "index", "name", "type", "created_at", "updated_at", "attributes", "styles", "is_visible", "elements", "events"},
+	}
+
+	AssetFields = &FieldSet{
+		Fields:   []string{"id", "name", "description", "project_id", "created_at", "updated_at", "size", "type", "width", "height", "hash"},
+		Sortable: []string{"created_at", "updated_at", "name", "size", "type"},
+	}
+
+	EventFields = &FieldSet{
+		Fields:   []string{"id", "element_id", "event", "workspace", "created_at", "updated_at"},
+		Sortable: []string{"created_at", "updated_at", "event"},
+	}
+)
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseFields parses the comma-separated fields. The ID is always included.
+// It returns nil if the string is empty.
+func (f *FieldSet) ParseFields(s string) ([]string, error) {
+	var fields []string
+
+	if s == "" {
+		return nil, nil
+	}
+
+	for _, name := range util.SplitAndTrim(s, ",") {
+		if !containsString(f.Fields, name) {
+			return nil, &util.APIError{
+				Field:   "fields",
+				Code:    util.ValueError,
+				Message: "Unknown field " + name + ".",
+			}
+		}
+
+		fields = appendIfMissing(fields, name)
+	}
+
+	return appendIfMissing(fields, "id"), nil
+}
+
+// ParseOrder converts the comma-separated order to an ORDER BY clause. Fields
+// prefixed with "-" are sorted in descending order. Columns are prefixed with
+// the table name.
+func (f *FieldSet) ParseOrder(order, table string) (string, error) {
+	var arr []string
+
+	for _, s := range util.SplitAndTrim(order, ",") {
+		name := strings.TrimPrefix(s, "-")
+
+		if !containsString(f.Sortable, name) {
+			return "", &util.APIError{
+				Field:   "order",
+				Code:    util.ValueError,
+				Message: "Can't sort by " + name + ".",
+			}
+		}
+
+		col := table + "." + name
+
+		if name != s {
+			col += " desc"
+		}
+
+		arr = append(arr, col)
+	}
+
+	return strings.Join(arr, ", "), nil
+}
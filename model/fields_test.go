@@ -0,0 +1,55 @@
+package model
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tkusd/server/util"
+)
+
+func TestFieldSet(t *testing.T) {
+	Convey("ParseFields", t, func() {
+		Convey("ID is always included", func() {
+			fields, err := ProjectFields.ParseFields("title, owner")
+			So(err, ShouldBeNil)
+			So(fields, ShouldResemble, []string{"title", "owner", "id"})
+		})
+
+		Convey("Empty string", func() {
+			fields, err := ProjectFields.ParseFields("")
+			So(err, ShouldBeNil)
+			So(fields, ShouldBeNil)
+		})
+
+		Convey("Unknown field", func() {
+			_, err := AssetFields.ParseFields("name,slug")
+			So(err, ShouldResemble, &util.APIError{
+				Field:   "fields",
+				Code:    util.ValueError,
+				Message: "Unknown field slug.",
+			})
+		})
+	})
+
+	Convey("ParseOrder", t, func() {
+		Convey("Multiple fields", func() {
+			order, err := ProjectFields.ParseOrder("title,-created_at", "projects")
+			So(err, ShouldBeNil)
+			So(order, ShouldEqual, "projects.title, projects.created_at desc")
+		})
+
+		Convey("Field is not sortable", func() {
+			_, err := ProjectFields.ParseOrder("description", "projects")
+			So(err, ShouldResemble, &util.APIError{
+				Field:   "order",
+				Code:    util.ValueError,
+				Message: "Can't sort by description.",
+			})
+		})
+
+		Convey("SQL is rejected", func() {
+			_, err := EventFields.ParseOrder("created_at; DROP TABLE events", "events")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
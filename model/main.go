@@ -3,14 +3,14 @@ package model
 import (
 	"database/sql"
 	"log"
-	"strings"
+	"time"
 
 	"path/filepath"
 
 	"bitbucket.org/liamstask/goose/lib/goose"
 	"github.com/jinzhu/gorm"
 	"github.com/tkusd/server/config"
-	"github.com/tkusd/server/util"
+	"github.com/tkusd/server/model/types"
 )
 
 const (
@@ -26,21 +26,9 @@ type QueryOption struct {
 	Offset int
 	Limit  int
 	Order  string
-}
-
-func (q *QueryOption) ParseOrder() string {
-	var arr []string
-	split := util.SplitAndTrim(q.Order, ",")
-
-	for _, s := range split {
-		if s[0] == '-' {
-			arr = append(arr, string(s[1:]), "desc")
-		} else {
-			arr = append(arr, s)
-		}
-	}
 
-	return strings.Join(arr, " ")
+	// Cursor is the encoded cursor of the page. Offset is ignored if it's set.
+	Cursor string
 }
 
 func init() {
@@ -70,3 +58,28 @@ func exists(table string, id string) bool {
 	db.Raw("SELECT exists(SELECT 1 FROM "+table+" WHERE id = ?)", id).Row().Scan(&result)
 	return result.Bool
 }
+
+// Precondition is checked with the current update time of a record before it's
+// changed. The change is aborted if it returns an error.
+type Precondition func(updatedAt time.Time) error
+
+// checkPrecondition locks the row in the transaction and checks the
+// precondition with its update time, so the record can't be modified by others
+// until the transaction ends.
+func checkPrecondition(tx *gorm.DB, table string, id types.UUID, pre Precondition) error {
+	if pre == nil {
+		return nil
+	}
+
+	var updatedAt time.Time
+
+	if err := tx.Raw("SELECT updated_at FROM "+table+" WHERE id = ? FOR UPDATE", id.String()).Row().Scan(&updatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return gorm.RecordNotFound
+		}
+
+		return err
+	}
+
+	return pre(updatedAt)
+}
@@ -2,6 +2,8 @@ package model
 
 import (
 	"database/sql"
+	"strings"
+	"time"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/jinzhu/gorm"
@@ -28,14 +30,50 @@ type Project struct {
 		Name   string     `json:"name"`
 		Avatar string     `json:"avatar"`
 	} `json:"owner,omitempty" sql:"-"`
+	Stats *ProjectStats `json:"stats,omitempty" sql:"-"`
 }
 
 type ProjectCollection struct {
-	Data    []*Project `json:"data"`
-	HasMore bool       `json:"has_more"`
-	Count   int        `json:"count"`
-	Limit   int        `json:"limit"`
-	Offset  int        `json:"offset"`
+	Data       []*Project `json:"data"`
+	HasMore    bool       `json:"has_more"`
+	Count      *int       `json:"count,omitempty"`
+	Limit      int        `json:"limit"`
+	Offset     int        `json:"offset"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+	PrevCursor string     `json:"prev_cursor,omitempty"`
+	Next       string     `json:"next,omitempty"`
+	Prev       string     `json:"prev,omitempty"`
+}
+
+// projectCursorColumns are the columns which projects can be sorted by in
+// cursor mode.
+var projectCursorColumns = []string{"created_at", "updated_at", "title"}
+
+type projectList []*Project
+
+func (list projectList) Len() int {
+	return len(list)
+}
+
+func (list projectList) Swap(i, j int) {
+	list[i], list[j] = list[j], list[i]
+}
+
+func (list *projectList) Truncate(n int) {
+	*list = (*list)[:n]
+}
+
+func (list projectList) CursorKey(i int, column string) (string, types.UUID) {
+	p := list[i]
+
+	switch column {
+	case "created_at":
+		return p.CreatedAt.Format(time.RFC3339Nano), p.ID
+	case "updated_at":
+		return p.UpdatedAt.Format(time.RFC3339Nano), p.ID
+	}
+
+	return p.Title, p.ID
 }
 
 // ProjectQueryOption is the query options for projects.
@@ -44,10 +82,23 @@ type ProjectQueryOption struct {
 	UserID    *types.UUID
 	Private   bool
 	WithOwner bool
+
+	// ViewerID is the current user. Private projects of the user are included.
+	ViewerID *types.UUID
+
+	// Query is the full-text search query on title and description.
+	Query string
+	Theme string
 }
 
-// Save creates or updates data in the database.
-func (p *Project) Save() error {
+// orderRelevance sorts projects by the rank of the full-text search.
+const orderRelevance = "relevance"
+
+// projectSearchVector is the full-text search vector of projects. It must
+// match the expression of the index projects_search_idx.
+const projectSearchVector = "to_tsvector('simple', projects.title || ' ' || coalesce(projects.description, ''))"
+
+func (p *Project) validate() error {
 	p.Title = govalidator.Trim(p.Title, "")
 
 	if p.Title == "" {
@@ -66,7 +117,31 @@ func (p *Project) Save() error {
 		}
 	}
 
-	if err := db.Save(p).Error; err != nil {
+	return nil
+}
+
+// Save creates or updates data in the database.
+func (p *Project) Save() error {
+	return p.SaveIf(nil)
+}
+
+// SaveIf is like Save but the precondition is checked before updating.
+func (p *Project) SaveIf(pre Precondition) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+
+	action := saveAction(p.ID)
+	tx := db.Begin()
+
+	if err := checkPrecondition(tx, "projects", p.ID, pre); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Save(p).Error; err != nil {
+		tx.Rollback()
+
 		switch e := err.(type) {
 		case *pq.Error:
 			switch e.Code.Name() {
@@ -81,6 +156,16 @@ func (p *Project) Save() error {
 		return err
 	}
 
+	if err := recordRevision(tx, p.ID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Commit the transaction
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
 	var user User
 
 	if err := db.Where("id = ?", p.UserID.String()).Select([]string{"id", "name", "avatar"}).First(&user).Error; err != nil {
@@ -91,6 +176,7 @@ func (p *Project) Save() error {
 	p.Owner.Name = user.Name
 	p.Owner.Avatar = user.Avatar
 
+	publishChange(p.ID, action, ChangeTypeProject, p.ID, *p)
 	return nil
 }
 
@@ -100,13 +186,40 @@ func deleteAssetFiles(assets []*Asset) {
 	}
 }
 
-func (p *Project) BeforeDelete(tx *gorm.DB) error {
+// Delete deletes data from the database.
+func (p *Project) Delete() error {
+	return p.DeleteIf(nil)
+}
+
+// DeleteIf is like Delete but the precondition is checked before deleting.
+func (p *Project) DeleteIf(pre Precondition) error {
 	var assets []*Asset
+	tx := db.Begin()
+
+	if err := checkPrecondition(tx, "projects", p.ID, pre); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Where("project_id = ?", p.ID.String()).Select([]string{"id", "slug", "hash"}).Find(&assets).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
 
-	if err := db.Where("project_id = ?", p.ID.String()).Select([]string{"id", "slug"}).Find(&assets).Error; err != nil {
+	if err := tx.Delete(p).Error; err != nil {
+		tx.Rollback()
 		return err
 	}
 
+	// Commit the transaction
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	publishChange(p.ID, ChangeDelete, ChangeTypeProject, p.ID, nil)
+
+	// Delete files after the assets are deleted, so the asset store won't keep
+	// files for them
 	if len(assets) > 0 {
 		go deleteAssetFiles(assets)
 	}
@@ -114,36 +227,35 @@ func (p *Project) BeforeDelete(tx *gorm.DB) error {
 	return nil
 }
 
-// Delete deletes data from the database.
-func (p *Project) Delete() error {
-	return db.Delete(p).Error
-}
-
 // Exists returns true if the record exists.
 func (p *Project) Exists() bool {
 	return exists("projects", p.ID.String())
 }
 
+var projectWithOwnerColumns = []string{
+	"projects.id",
+	"projects.title",
+	"projects.description",
+	"projects.user_id",
+	"projects.created_at",
+	"projects.updated_at",
+	"projects.is_private",
+	"projects.main_screen",
+	"projects.theme",
+	"users.id",
+	"users.name",
+	"users.avatar",
+}
+
 func generateProjectWithOwnerQuery() *gorm.DB {
 	return db.Table("projects").
 		Joins("JOIN users ON users.id = projects.user_id").
-		Select([]string{
-		"projects.id",
-		"projects.title",
-		"projects.description",
-		"projects.user_id",
-		"projects.created_at",
-		"projects.updated_at",
-		"projects.is_private",
-		"projects.main_screen",
-		"projects.theme",
-		"users.id",
-		"users.name",
-		"users.avatar",
-	})
+		Select(projectWithOwnerColumns)
 }
 
-func scanProjectsWithOwner(rows *sql.Rows) ([]*Project, error) {
+// scanProjectsWithOwner scans the rows of generateProjectWithOwnerQuery. Extra
+// columns selected after the default ones are scanned into extra.
+func scanProjectsWithOwner(rows *sql.Rows, extra ...interface{}) ([]*Project, error) {
 	var list []*Project
 
 	defer rows.Close()
@@ -151,7 +263,7 @@ func scanProjectsWithOwner(rows *sql.Rows) ([]*Project, error) {
 	for rows.Next() {
 		project := new(Project)
 
-		err := rows.Scan(
+		dest := []interface{}{
 			&project.ID,
 			&project.Title,
 			&project.Description,
@@ -164,7 +276,9 @@ func scanProjectsWithOwner(rows *sql.Rows) ([]*Project, error) {
 			&project.Owner.ID,
 			&project.Owner.Name,
 			&project.Owner.Avatar,
-		)
+		}
+
+		err := rows.Scan(append(dest, extra...)...)
 
 		if err != nil {
 			return nil, err
@@ -176,69 +290,123 @@ func scanProjectsWithOwner(rows *sql.Rows) ([]*Project, error) {
 	return list, nil
 }
 
-// GetProjectList gets a list of projects.
-func GetProjectList(option *ProjectQueryOption) (*ProjectCollection, error) {
-	var count int
-	query := map[string]interface{}{}
+// filterProjects adds conditions of the option to the query.
+func filterProjects(scope *gorm.DB, option *ProjectQueryOption) *gorm.DB {
+	if option.UserID != nil {
+		scope = scope.Where("projects.user_id = ?", option.UserID.String())
+	}
 
 	if !option.Private {
-		query["is_private"] = false
+		if option.ViewerID != nil {
+			scope = scope.Where("(projects.is_private = false OR projects.user_id = ?)", option.ViewerID.String())
+		} else {
+			scope = scope.Where("projects.is_private = false")
+		}
 	}
 
-	if option.UserID != nil {
-		query["user_id"] = option.UserID.String()
+	if option.Theme != "" {
+		scope = scope.Where("projects.theme = ?", option.Theme)
 	}
 
+	if option.Query != "" {
+		scope = scope.Where(projectSearchVector+" @@ plainto_tsquery('simple', ?)", option.Query)
+	}
+
+	return scope
+}
+
+// GetProjectList gets a list of projects.
+func GetProjectList(option *ProjectQueryOption) (*ProjectCollection, error) {
+	var count int
+	var order string
+	var extra []interface{}
+	var err error
+
 	if option.Limit == 0 || option.Limit > maxLimit {
 		option.Limit = defaultLimit
 	}
 
 	if option.Order == "" {
-		option.Order = "-created_at"
+		if option.Query != "" {
+			option.Order = orderRelevance
+		} else {
+			option.Order = "-created_at"
+		}
+	}
+
+	if option.Order == orderRelevance && option.Query != "" {
+		order = "rank desc, projects.created_at desc"
+		extra = append(extra, new(float64))
+	} else if order, err = ProjectFields.ParseOrder(option.Order, "projects"); err != nil {
+		return nil, err
 	}
 
-	order := option.ParseOrder()
+	page, err := newPage(&option.QueryOption, projectCursorColumns)
 
-	// Get count
-	if err := db.Table("projects").Where(query).Count(&count).Error; err != nil {
+	if err != nil {
 		return nil, err
 	}
 
-	rows, err := generateProjectWithOwnerQuery().
-		Where(query).
-		Order(order).
-		Offset(option.Offset).
-		Limit(option.Limit).
-		Rows()
+	// Count is skipped in cursor mode
+	if !page.isCursor() {
+		if err := filterProjects(db.Table("projects"), option).Count(&count).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	scope := generateProjectWithOwnerQuery()
+
+	// The rank is selected with the query bound as a parameter
+	if extra != nil {
+		scope = scope.Select(strings.Join(projectWithOwnerColumns, ", ")+
+			", ts_rank("+projectSearchVector+", plainto_tsquery('simple', ?)) AS rank", option.Query)
+	}
+
+	rows, err := page.apply(filterProjects(scope, option), "projects", order).Rows()
 
 	if err != nil {
 		return nil, err
 	}
 
-	projects, err := scanProjectsWithOwner(rows)
+	projects, err := scanProjectsWithOwner(rows, extra...)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if projects == nil {
-		projects = make([]*Project, 0)
+	list := projectList(projects)
+	prev, next, hasMore := page.finish(&list, count)
+
+	if list == nil {
+		list = make([]*Project, 0)
+	}
+
+	result := &ProjectCollection{
+		Data:       list,
+		Limit:      option.Limit,
+		Offset:     option.Offset,
+		HasMore:    hasMore,
+		NextCursor: next,
+		PrevCursor: prev,
+	}
+
+	if !page.isCursor() {
+		result.Count = &count
 	}
 
-	return &ProjectCollection{
-		Data:    projects,
-		Limit:   option.Limit,
-		Offset:  option.Offset,
-		Count:   count,
-		HasMore: count > option.Offset+option.Limit,
-	}, nil
+	return result, nil
 }
 
 // GetProject gets the project data.
 func GetProject(id types.UUID) (*Project, error) {
+	return getProject(&db, id)
+}
+
+// getProject gets the project data in the transaction.
+func getProject(tx *gorm.DB, id types.UUID) (*Project, error) {
 	project := new(Project)
 
-	if err := db.Where("id = ?", id.String()).First(project).Error; err != nil {
+	if err := tx.Where("id = ?", id.String()).First(project).Error; err != nil {
 		return nil, err
 	}
 
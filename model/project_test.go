@@ -141,6 +141,58 @@ func TestGetProjectList(t *testing.T) {
 	// TODO: need tests
 }
 
+func TestSearchProjects(t *testing.T) {
+	owner, err := createTestUser(fixtureUsers[0])
+	defer owner.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	user, err := createTestUser(fixtureUsers[1])
+	defer user.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	public := &Project{Title: "Todo list", Description: "Simple tasks", UserID: owner.ID, Theme: "ios"}
+	private := &Project{Title: "Secret todo", UserID: owner.ID, IsPrivate: true}
+
+	for _, p := range []*Project{public, private} {
+		if err := p.Save(); err != nil {
+			log.Fatal(err)
+		}
+
+		defer p.Delete()
+	}
+
+	Convey("Only public projects are returned", t, func() {
+		list, err := GetProjectList(&ProjectQueryOption{Query: "todo"})
+		So(err, ShouldBeNil)
+		So(*list.Count, ShouldEqual, 1)
+		So(list.Data[0].ID, ShouldResemble, public.ID)
+	})
+
+	Convey("Private projects of the viewer are included", t, func() {
+		list, _ := GetProjectList(&ProjectQueryOption{Query: "todo", ViewerID: &owner.ID})
+		So(*list.Count, ShouldEqual, 2)
+
+		list, _ = GetProjectList(&ProjectQueryOption{Query: "todo", ViewerID: &user.ID})
+		So(*list.Count, ShouldEqual, 1)
+	})
+
+	Convey("Search description", t, func() {
+		list, _ := GetProjectList(&ProjectQueryOption{Query: "tasks"})
+		So(*list.Count, ShouldEqual, 1)
+	})
+
+	Convey("Filter by theme", t, func() {
+		list, _ := GetProjectList(&ProjectQueryOption{Query: "todo", Theme: "android"})
+		So(*list.Count, ShouldEqual, 0)
+	})
+}
+
 func TestGetProject(t *testing.T) {
 	user, err := createTestUser(fixtureUsers[0])
 	defer user.Delete()
@@ -0,0 +1,183 @@
+package model
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/jinzhu/gorm"
+	"github.com/tkusd/server/config"
+	"github.com/tkusd/server/model/types"
+	"github.com/tkusd/server/util"
+)
+
+// UserUsage is the storage used by a user and the limit. The limit is zero if
+// it's unlimited.
+type UserUsage struct {
+	AssetSize      int64 `json:"asset_size"`
+	AssetSizeLimit int64 `json:"asset_size_limit"`
+}
+
+func quotaError(field, message string, status int) error {
+	return &util.APIError{
+		Field:   field,
+		Code:    util.QuotaExceededError,
+		Message: message,
+		Status:  status,
+	}
+}
+
+// AssetSizeLimit returns the maximum size of a single asset. It returns zero
+// if it's unlimited.
+func AssetSizeLimit() int64 {
+	return config.Config.Quota.AssetSize
+}
+
+// sumAssetSize returns the total size of assets matching the condition. The
+// asset with excludeID is skipped if it's valid.
+func sumAssetSize(excludeID types.UUID, where string, args ...interface{}) (int64, error) {
+	var size int64
+
+	scope := db.Table("assets").
+		Select("COALESCE(sum(assets.size), 0)").
+		Joins("JOIN projects ON projects.id = assets.project_id").
+		Where(where, args...)
+
+	if excludeID.Valid() {
+		scope = scope.Where("assets.id != ?", excludeID.String())
+	}
+
+	err := scope.Row().Scan(&size)
+
+	return size, err
+}
+
+func checkAssetSize(size int64) error {
+	if limit := config.Config.Quota.AssetSize; limit > 0 && size > limit {
+		return quotaError("data", "Maximum size of an asset is "+strconv.FormatInt(limit, 10)+" bytes.", http.StatusRequestEntityTooLarge)
+	}
+
+	return nil
+}
+
+func checkProjectAssetSize(size int64) error {
+	if limit := config.Config.Quota.ProjectAssetSize; limit > 0 && size > limit {
+		return quotaError("data", "Maximum size of assets in a project is "+strconv.FormatInt(limit, 10)+" bytes.", http.StatusForbidden)
+	}
+
+	return nil
+}
+
+// checkUserAssetQuota checks whether size bytes can be added to the assets of
+// the user. The asset with excludeID is not counted.
+func checkUserAssetQuota(userID, excludeID types.UUID, size int64) error {
+	limit := config.Config.Quota.UserAssetSize
+
+	if limit <= 0 {
+		return nil
+	}
+
+	used, err := sumAssetSize(excludeID, "projects.user_id = ?", userID.String())
+
+	if err != nil {
+		return err
+	}
+
+	if used+size > limit {
+		return quotaError("data", "Maximum size of assets of a user is "+strconv.FormatInt(limit, 10)+" bytes.", http.StatusForbidden)
+	}
+
+	return nil
+}
+
+// CheckAssetQuota checks whether the asset can be saved with the given size.
+// The current size of the asset is excluded from the usage, so it can be
+// replaced.
+func CheckAssetQuota(asset *Asset, size int64) error {
+	if err := checkAssetSize(size); err != nil {
+		return err
+	}
+
+	if config.Config.Quota.ProjectAssetSize > 0 {
+		used, err := sumAssetSize(asset.ID, "assets.project_id = ?", asset.ProjectID.String())
+
+		if err != nil {
+			return err
+		}
+
+		if err := checkProjectAssetSize(used + size); err != nil {
+			return err
+		}
+	}
+
+	return checkUserAssetQuota(GetUserIDForProject(asset.ProjectID), asset.ID, size)
+}
+
+// CheckBundleQuota checks whether the bundle can be imported as a new project
+// of the user. Sizes of assets in the bundle must be set. It's checked before
+// the content of assets is written.
+func CheckBundleQuota(userID types.UUID, bundle *Bundle) error {
+	var total int64
+
+	for _, asset := range bundle.Assets {
+		if err := checkAssetSize(asset.Size); err != nil {
+			return err
+		}
+
+		total += asset.Size
+	}
+
+	if err := checkProjectAssetSize(total); err != nil {
+		return err
+	}
+
+	return checkUserAssetQuota(userID, types.UUID{}, total)
+}
+
+// CheckElementQuota checks whether n elements can be added to the project.
+func CheckElementQuota(projectID types.UUID, n int) error {
+	return checkElementQuota(&db, projectID, n)
+}
+
+// checkElementQuota is like CheckElementQuota but counts the elements in the
+// transaction.
+func checkElementQuota(tx *gorm.DB, projectID types.UUID, n int) error {
+	if config.Config.Quota.ProjectElements <= 0 {
+		return nil
+	}
+
+	count, err := countElements(tx, projectID)
+
+	if err != nil {
+		return err
+	}
+
+	return checkElementCount(count + n)
+}
+
+func countElements(tx *gorm.DB, projectID types.UUID) (int, error) {
+	var count int
+	err := tx.Table("elements").Where("project_id = ?", projectID.String()).Count(&count).Error
+	return count, err
+}
+
+func checkElementCount(count int) error {
+	if limit := config.Config.Quota.ProjectElements; limit > 0 && count > limit {
+		return quotaError("", "Maximum number of elements in a project is "+strconv.Itoa(limit)+".", http.StatusForbidden)
+	}
+
+	return nil
+}
+
+// GetUserUsage gets the storage used by the user.
+func GetUserUsage(userID types.UUID) (*UserUsage, error) {
+	size, err := sumAssetSize(types.UUID{}, "projects.user_id = ?", userID.String())
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserUsage{
+		AssetSize:      size,
+		AssetSizeLimit: config.Config.Quota.UserAssetSize,
+	}, nil
+}
@@ -0,0 +1,154 @@
+package model
+
+import (
+	"encoding/json"
+	"log"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tkusd/server/config"
+	"github.com/tkusd/server/util"
+)
+
+func TestQuota(t *testing.T) {
+	quota := config.Config.Quota
+	defer func() { config.Config.Quota = quota }()
+
+	user, err := createTestUser(fixtureUsers[0])
+	defer user.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	project, err := createTestProject(user)
+	defer project.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	other, err := createTestProject(user)
+	defer other.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	asset := &Asset{Name: "a.png", ProjectID: project.ID, Size: 600}
+
+	if err := asset.Save(); err != nil {
+		log.Fatal(err)
+	}
+
+	screen, err := createTestElement(project)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	Convey("CheckAssetQuota", t, func() {
+		config.Config.Quota.AssetSize = 500
+		config.Config.Quota.ProjectAssetSize = 1000
+		config.Config.Quota.UserAssetSize = 1200
+
+		Convey("Single asset", func() {
+			err := CheckAssetQuota(&Asset{ProjectID: project.ID}, 501)
+			So(err.(*util.APIError).Code, ShouldEqual, util.QuotaExceededError)
+			So(CheckAssetQuota(&Asset{ProjectID: project.ID}, 400), ShouldBeNil)
+		})
+
+		Convey("Project", func() {
+			err := CheckAssetQuota(&Asset{ProjectID: project.ID}, 401)
+			So(err.(*util.APIError).Code, ShouldEqual, util.QuotaExceededError)
+		})
+
+		Convey("Exclude the asset itself", func() {
+			So(CheckAssetQuota(asset, 500), ShouldBeNil)
+		})
+
+		Convey("User", func() {
+			So(CheckAssetQuota(&Asset{ProjectID: other.ID}, 500), ShouldBeNil)
+
+			extra := &Asset{Name: "b.png", ProjectID: other.ID, Size: 500}
+			So(extra.Save(), ShouldBeNil)
+			defer extra.Delete()
+
+			err := CheckAssetQuota(&Asset{ProjectID: other.ID}, 101)
+			So(err.(*util.APIError).Code, ShouldEqual, util.QuotaExceededError)
+		})
+
+		Convey("Unlimited", func() {
+			config.Config.Quota.AssetSize = 0
+			config.Config.Quota.ProjectAssetSize = 0
+			config.Config.Quota.UserAssetSize = 0
+			So(CheckAssetQuota(&Asset{ProjectID: project.ID}, 1<<30), ShouldBeNil)
+		})
+	})
+
+	Convey("CheckBundleQuota", t, func() {
+		config.Config.Quota.AssetSize = 500
+		config.Config.Quota.ProjectAssetSize = 1000
+		config.Config.Quota.UserAssetSize = 1200
+
+		Convey("Single asset", func() {
+			err := CheckBundleQuota(user.ID, &Bundle{Assets: []*Asset{{Size: 501}}})
+			So(err.(*util.APIError).Code, ShouldEqual, util.QuotaExceededError)
+		})
+
+		Convey("Project", func() {
+			err := CheckBundleQuota(other.UserID, &Bundle{Assets: []*Asset{{Size: 500}, {Size: 500}, {Size: 1}}})
+			So(err.(*util.APIError).Code, ShouldEqual, util.QuotaExceededError)
+		})
+
+		Convey("User", func() {
+			So(CheckBundleQuota(user.ID, &Bundle{Assets: []*Asset{{Size: 500}, {Size: 100}}}), ShouldBeNil)
+
+			err := CheckBundleQuota(user.ID, &Bundle{Assets: []*Asset{{Size: 500}, {Size: 101}}})
+			So(err.(*util.APIError).Code, ShouldEqual, util.QuotaExceededError)
+		})
+	})
+
+	Convey("CheckElementQuota", t, func() {
+		config.Config.Quota.ProjectElements = 2
+		So(CheckElementQuota(project.ID, 1), ShouldBeNil)
+
+		err := CheckElementQuota(project.ID, 2)
+		So(err.(*util.APIError).Code, ShouldEqual, util.QuotaExceededError)
+	})
+
+	Convey("Element quota of batches", t, func() {
+		config.Config.Quota.ProjectElements = 2
+
+		_, err := ExecuteBatch(project.ID, []*BatchOperation{
+			{Op: BatchCreate, Type: ChangeTypeElement, Data: json.RawMessage(`{"name": "A", "type": "screen"}`)},
+			{Op: BatchCreate, Type: ChangeTypeElement, Data: json.RawMessage(`{"name": "B", "type": "screen"}`)},
+		})
+
+		So(err.(*util.APIError).Code, ShouldEqual, util.QuotaExceededError)
+		So(err.(*util.APIError).Field, ShouldEqual, "operations.1")
+
+		list, _ := GetElementList(&ElementQueryOption{ProjectID: &project.ID})
+		So(list, ShouldHaveLength, 1)
+	})
+
+	Convey("Element quota of copies", t, func() {
+		config.Config.Quota = quota
+		config.Config.Quota.ProjectElements = 1
+
+		_, err := CopyElement(screen, &ElementPosition{})
+		So(err.(*util.APIError).Code, ShouldEqual, util.QuotaExceededError)
+
+		// Forks are new projects
+		fork, err := ForkProject(project, user.ID)
+		So(err, ShouldBeNil)
+		So(fork.Delete(), ShouldBeNil)
+	})
+
+	Convey("GetUserUsage", t, func() {
+		config.Config.Quota.UserAssetSize = 1200
+		usage, err := GetUserUsage(user.ID)
+		So(err, ShouldBeNil)
+		So(usage, ShouldResemble, &UserUsage{AssetSize: 600, AssetSizeLimit: 1200})
+	})
+}
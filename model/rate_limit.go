@@ -0,0 +1,76 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/tkusd/server/util"
+)
+
+// RateLimitStore stores token buckets in the rate_limits table, so the limits
+// are shared between processes.
+type RateLimitStore struct{}
+
+// NewRateLimitStore creates a new store. Buckets which haven't been used for
+// the expiry time are deleted periodically. It should be longer than the
+// windows of rate limits.
+func NewRateLimitStore(expiry time.Duration) *RateLimitStore {
+	go func() {
+		for range time.Tick(expiry) {
+			if err := deleteRateLimits(time.Now().Add(-expiry)); err != nil {
+				util.Log().Errorf("Failed to delete expired rate limits: %v", err)
+			}
+		}
+	}()
+
+	return &RateLimitStore{}
+}
+
+// Take implements util.RateLimitStore. The row is locked until the bucket is
+// updated.
+func (s *RateLimitStore) Take(key string, limit int, window time.Duration) (*util.RateLimitResult, error) {
+	var bucket util.TokenBucket
+	tx := db.Begin()
+
+	err := tx.Raw("SELECT tokens, updated_at FROM rate_limits WHERE key = ? FOR UPDATE", key).
+		Row().
+		Scan(&bucket.Tokens, &bucket.UpdatedAt)
+
+	exists := err == nil
+
+	if err != nil && err != sql.ErrNoRows {
+		tx.Rollback()
+		return nil, err
+	}
+
+	result := bucket.Take(time.Now(), limit, window)
+
+	if exists {
+		err = tx.Exec("UPDATE rate_limits SET tokens = ?, updated_at = ? WHERE key = ?", bucket.Tokens, bucket.UpdatedAt, key).Error
+	} else {
+		err = tx.Exec("INSERT INTO rate_limits (key, tokens, updated_at) VALUES (?, ?, ?)", key, bucket.Tokens, bucket.UpdatedAt).Error
+	}
+
+	if err != nil {
+		tx.Rollback()
+
+		// The row was inserted by another request. Try again with the lock.
+		if e, ok := err.(*pq.Error); ok && e.Code.Name() == UniqueViolation {
+			return s.Take(key, limit, window)
+		}
+
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// deleteRateLimits deletes buckets which haven't been used since the time.
+func deleteRateLimits(before time.Time) error {
+	return db.Exec("DELETE FROM rate_limits WHERE updated_at < ?", before).Error
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRateLimitStore(t *testing.T) {
+	store := &RateLimitStore{}
+	key := "test:ip:127.0.0.1"
+	defer db.Exec("DELETE FROM rate_limits WHERE key = ?", key)
+
+	Convey("Take tokens until the bucket is empty", t, func() {
+		for i := 2; i >= 0; i-- {
+			result, err := store.Take(key, 3, time.Minute)
+			So(err, ShouldBeNil)
+			So(result.Allowed, ShouldBeTrue)
+			So(result.Remaining, ShouldEqual, i)
+		}
+
+		result, err := store.Take(key, 3, time.Minute)
+		So(err, ShouldBeNil)
+		So(result.Allowed, ShouldBeFalse)
+		So(result.RetryAfter, ShouldBeGreaterThan, 0)
+		So(result.RetryAfter, ShouldBeLessThanOrEqualTo, 20*time.Second)
+	})
+
+	Convey("Buckets are separated by keys", t, func() {
+		other := key + ":other"
+		defer db.Exec("DELETE FROM rate_limits WHERE key = ?", other)
+
+		result, err := store.Take(other, 3, time.Minute)
+		So(err, ShouldBeNil)
+		So(result.Allowed, ShouldBeTrue)
+	})
+}
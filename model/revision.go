@@ -0,0 +1,266 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/tkusd/server/model/types"
+)
+
+const (
+	// maxRevisions is the number of revisions kept for each project.
+	maxRevisions = 100
+
+	// revisionInterval is the period in which changes of a project are
+	// coalesced into the latest revision.
+	revisionInterval = time.Minute
+)
+
+// Revision is a snapshot of the project and its elements and events.
+type Revision struct {
+	ID        types.UUID       `json:"id"`
+	ProjectID types.UUID       `json:"project_id"`
+	CreatedAt types.Time       `json:"created_at"`
+	Snapshot  types.JSONObject `json:"snapshot,omitempty"`
+}
+
+// Bundle returns the project data in the snapshot. Assets are not included.
+func (r *Revision) Bundle() (*Bundle, error) {
+	data, err := json.Marshal(r.Snapshot)
+
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := new(Bundle)
+
+	if err := json.Unmarshal(data, bundle); err != nil {
+		return nil, err
+	}
+
+	return bundle, nil
+}
+
+func (r *Revision) Exists() bool {
+	return exists("revisions", r.ID.String())
+}
+
+// newSnapshot returns the current state of the project in the transaction.
+// Assets are not included since their contents are not versioned.
+func newSnapshot(tx *gorm.DB, projectID types.UUID) (types.JSONObject, error) {
+	project, err := getProject(tx, projectID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	elements, err := getElementList(tx, &ElementQueryOption{
+		ProjectID:  &projectID,
+		Flat:       true,
+		WithEvents: true,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(&Bundle{
+		Version:  BundleVersion,
+		Project:  project,
+		Elements: elements,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot types.JSONObject
+
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// pruneRevisions deletes old revisions of the project over maxRevisions.
+func pruneRevisions(tx *gorm.DB, projectID types.UUID) error {
+	return tx.Exec(`DELETE FROM revisions
+WHERE project_id = ? AND id NOT IN (
+  SELECT id FROM revisions
+  WHERE project_id = ?
+  ORDER BY created_at DESC
+  LIMIT ?
+)`, projectID.String(), projectID.String(), maxRevisions).Error
+}
+
+// createRevision records the current state of the project as a new revision
+// in the transaction.
+func createRevision(tx *gorm.DB, projectID types.UUID) error {
+	snapshot, err := newSnapshot(tx, projectID)
+
+	if err != nil {
+		return err
+	}
+
+	r := &Revision{
+		ProjectID: projectID,
+		Snapshot:  snapshot,
+	}
+
+	if err := tx.Create(r).Error; err != nil {
+		return err
+	}
+
+	return pruneRevisions(tx, projectID)
+}
+
+// recordRevision records the current state of the project in the transaction
+// of the change. Changes within revisionInterval are coalesced, so editing
+// won't flood the revisions. The revision which opens the interval is kept as
+// the baseline, and later changes are coalesced into the next revision, so the
+// state before them can still be restored.
+func recordRevision(tx *gorm.DB, projectID types.UUID) error {
+	if !projectID.Valid() {
+		return nil
+	}
+
+	var list []*Revision
+
+	err := tx.Select([]string{"id", "created_at"}).
+		Where("project_id = ?", projectID.String()).
+		Order("created_at desc").
+		Limit(2).
+		Find(&list).
+		Error
+
+	if err != nil {
+		return err
+	}
+
+	if !isCoalescing(list) {
+		return createRevision(tx, projectID)
+	}
+
+	snapshot, err := newSnapshot(tx, projectID)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Table("revisions").Where("id = ?", list[0].ID.String()).UpdateColumn("snapshot", snapshot).Error
+}
+
+// isCoalescing returns true if changes can be coalesced into the latest
+// revision. It must be created within revisionInterval, after the baseline
+// revision of the same interval.
+func isCoalescing(list []*Revision) bool {
+	if len(list) < 2 {
+		return false
+	}
+
+	latest := list[0].CreatedAt.Time
+	baseline := list[1].CreatedAt.Time
+
+	return time.Since(latest) < revisionInterval && latest.Sub(baseline) < revisionInterval
+}
+
+// GetRevisionList gets the revisions of the project. Snapshots are not
+// included.
+func GetRevisionList(projectID types.UUID) ([]*Revision, error) {
+	var list []*Revision
+
+	err := db.Select([]string{"id", "project_id", "created_at"}).
+		Where("project_id = ?", projectID.String()).
+		Order("created_at desc").
+		Find(&list).
+		Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	if list == nil {
+		list = make([]*Revision, 0)
+	}
+
+	return list, nil
+}
+
+// GetRevision gets the revision with the snapshot.
+func GetRevision(id types.UUID) (*Revision, error) {
+	r := new(Revision)
+
+	if err := db.Where("id = ?", id.String()).First(r).Error; err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// RestoreRevision replaces elements and events of the project with the ones
+// in the snapshot in one transaction. IDs of elements and events are kept.
+// A new revision is always created after restored instead of being coalesced,
+// so the restore can be undone as well.
+func RestoreRevision(r *Revision) (*Project, error) {
+	bundle, err := r.Bundle()
+
+	if err != nil {
+		return nil, err
+	}
+
+	elements, err := sortElementsByParent(bundle.Elements)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tx := db.Begin()
+
+	// Children and events are deleted by the foreign keys
+	if err := tx.Where("project_id = ? AND element_id IS NULL", r.ProjectID.String()).Delete(&Element{}).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := insertElements(tx, elements, true); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if src := bundle.Project; src != nil {
+		data := map[string]interface{}{
+			"title":       src.Title,
+			"description": src.Description,
+			"theme":       src.Theme,
+			"main_screen": src.MainScreen,
+		}
+
+		if err := tx.Table("projects").Where("id = ?", r.ProjectID.String()).UpdateColumns(data).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := createRevision(tx, r.ProjectID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Commit the transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	project, err := GetProjectWithOwner(r.ProjectID)
+
+	if err != nil || project == nil {
+		return nil, err
+	}
+
+	// Elements are replaced, so clients should reload the whole project
+	publishChange(r.ProjectID, ChangeUpdate, ChangeTypeProject, r.ProjectID, *project)
+
+	return project, nil
+}
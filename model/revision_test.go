@@ -0,0 +1,86 @@
+package model
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tkusd/server/model/types"
+)
+
+func TestRevision(t *testing.T) {
+	user, err := createTestUser(fixtureUsers[0])
+	defer user.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	project, err := createTestProject(user)
+	defer project.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	element, err := createTestElement(project)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	Convey("Keep the baseline before coalescing", t, func() {
+		list, err := GetRevisionList(project.ID)
+		So(err, ShouldBeNil)
+		So(list, ShouldHaveLength, 2)
+		So(list[0].Snapshot, ShouldBeNil)
+
+		baseline, _ := GetRevision(list[1].ID)
+		bundle, err := baseline.Bundle()
+		So(err, ShouldBeNil)
+		So(bundle.Elements, ShouldBeEmpty)
+
+		revision, _ := GetRevision(list[0].ID)
+		bundle, err = revision.Bundle()
+		So(err, ShouldBeNil)
+		So(bundle.Elements, ShouldHaveLength, 1)
+	})
+
+	Convey("Coalesce changes within the interval", t, func() {
+		err := UpdateElementOrder(&ElementQueryOption{ProjectID: &project.ID}, []types.UUID{element.ID})
+		So(err, ShouldBeNil)
+
+		list, _ := GetRevisionList(project.ID)
+		So(list, ShouldHaveLength, 2)
+	})
+
+	Convey("Record a new revision after the interval", t, func() {
+		db.Exec("UPDATE revisions SET created_at = ? WHERE project_id = ?", time.Now().Add(-revisionInterval), project.ID.String())
+
+		err := UpdateElementOrder(&ElementQueryOption{ProjectID: &project.ID}, []types.UUID{element.ID})
+		So(err, ShouldBeNil)
+
+		list, _ := GetRevisionList(project.ID)
+		So(list, ShouldHaveLength, 3)
+	})
+
+	Convey("Restore", t, func() {
+		list, _ := GetRevisionList(project.ID)
+		revision, _ := GetRevision(list[0].ID)
+
+		element.Delete()
+
+		_, err := RestoreRevision(revision)
+		So(err, ShouldBeNil)
+
+		restored, err := GetElement(element.ID)
+		So(err, ShouldBeNil)
+		So(restored.Name, ShouldEqual, element.Name)
+
+		// The deletion is recorded after the baseline of the interval and
+		// restoring always creates a new revision
+		list, _ = GetRevisionList(project.ID)
+		So(list, ShouldHaveLength, 5)
+	})
+}
@@ -0,0 +1,149 @@
+package model
+
+import (
+	"time"
+
+	"github.com/tkusd/server/model/types"
+)
+
+// ProjectStats is the number of elements and assets in a project.
+type ProjectStats struct {
+	ElementCount int `json:"element_count"`
+	AssetCount   int `json:"asset_count"`
+}
+
+// ProjectDetailStats is the statistics of a project.
+type ProjectDetailStats struct {
+	ProjectStats
+	ElementTypes map[string]int `json:"element_types"`
+	EventCount   int            `json:"event_count"`
+	AssetSize    int64          `json:"asset_size"`
+	LastEditedAt types.Time     `json:"last_edited_at"`
+}
+
+// UserStats is the number of projects of a user.
+type UserStats struct {
+	TotalProjectCount  int `json:"total_project_count"`
+	PublicProjectCount int `json:"public_project_count"`
+}
+
+// LoadProjectStats sets Stats of the projects from the projects_extended view.
+func LoadProjectStats(projects []*Project) error {
+	var ids []string
+
+	if len(projects) == 0 {
+		return nil
+	}
+
+	for _, p := range projects {
+		ids = append(ids, p.ID.String())
+	}
+
+	rows, err := db.Table("projects_extended").
+		Select("id, element_count, asset_count").
+		Where("id IN (?)", ids).
+		Rows()
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var id types.UUID
+		stats := new(ProjectStats)
+
+		if err := rows.Scan(&id, &stats.ElementCount, &stats.AssetCount); err != nil {
+			return err
+		}
+
+		for _, p := range projects {
+			if p.ID.Equal(id) {
+				p.Stats = stats
+			}
+		}
+	}
+
+	return rows.Err()
+}
+
+// GetProjectDetailStats gets the statistics of the project.
+func GetProjectDetailStats(projectID types.UUID) (*ProjectDetailStats, error) {
+	var lastEditedAt time.Time
+	id := projectID.String()
+	stats := &ProjectDetailStats{
+		ElementTypes: map[string]int{},
+	}
+
+	rows, err := db.Raw("SELECT type, count(id) FROM elements WHERE project_id = ? GROUP BY type", id).Rows()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var count int
+
+		if err := rows.Scan(&name, &count); err != nil {
+			return nil, err
+		}
+
+		stats.ElementTypes[name] = count
+		stats.ElementCount += count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = db.Raw("SELECT count(events.id) FROM events JOIN elements ON elements.id = events.element_id WHERE elements.project_id = ?", id).
+		Row().
+		Scan(&stats.EventCount)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Raw("SELECT count(id), COALESCE(sum(size), 0) FROM assets WHERE project_id = ?", id).
+		Row().
+		Scan(&stats.AssetCount, &stats.AssetSize)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Saving elements also updates the project
+	raw := `SELECT GREATEST(
+  projects.updated_at,
+  (SELECT max(events.updated_at) FROM events JOIN elements ON elements.id = events.element_id WHERE elements.project_id = projects.id),
+  (SELECT max(updated_at) FROM assets WHERE project_id = projects.id)
+) FROM projects WHERE id = ?`
+
+	if err := db.Raw(raw, id).Row().Scan(&lastEditedAt); err != nil {
+		return nil, err
+	}
+
+	stats.LastEditedAt = types.Time{Time: lastEditedAt}
+
+	return stats, nil
+}
+
+// GetUserStats gets the number of projects of the user from the
+// users_extended view.
+func GetUserStats(userID types.UUID) (*UserStats, error) {
+	stats := new(UserStats)
+
+	err := db.Raw("SELECT total_project_count, public_project_count FROM users_extended WHERE id = ?", userID.String()).
+		Row().
+		Scan(&stats.TotalProjectCount, &stats.PublicProjectCount)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"log"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tkusd/server/model/types"
+)
+
+func TestStats(t *testing.T) {
+	user, err := createTestUser(fixtureUsers[0])
+	defer user.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	project, err := createTestProject(user)
+	defer project.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	private := &Project{Title: "Private", UserID: user.ID, IsPrivate: true}
+	defer private.Delete()
+
+	if err := private.Save(); err != nil {
+		log.Fatal(err)
+	}
+
+	screen, err := createTestElement(project)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	child, err := createTestChildElement(screen)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	event := &Event{ElementID: child.ID, Event: "click"}
+
+	if err := event.Save(); err != nil {
+		log.Fatal(err)
+	}
+
+	asset := &Asset{Name: "logo.png", ProjectID: project.ID, Size: 1024}
+
+	if err := asset.Save(); err != nil {
+		log.Fatal(err)
+	}
+
+	Convey("LoadProjectStats", t, func() {
+		list := []*Project{project, private}
+		So(LoadProjectStats(list), ShouldBeNil)
+		So(project.Stats, ShouldResemble, &ProjectStats{ElementCount: 2, AssetCount: 1})
+		So(private.Stats, ShouldResemble, &ProjectStats{})
+	})
+
+	Convey("GetProjectDetailStats", t, func() {
+		stats, err := GetProjectDetailStats(project.ID)
+		So(err, ShouldBeNil)
+		So(stats.ProjectStats, ShouldResemble, ProjectStats{ElementCount: 2, AssetCount: 1})
+		So(stats.ElementTypes, ShouldResemble, map[string]int{
+			types.ElementTypeScreen: 1,
+			types.ElementTypeText:   1,
+		})
+		So(stats.EventCount, ShouldEqual, 1)
+		So(stats.AssetSize, ShouldEqual, 1024)
+		So(stats.LastEditedAt.Before(project.UpdatedAt.Time), ShouldBeFalse)
+	})
+
+	Convey("GetUserStats", t, func() {
+		stats, err := GetUserStats(user.ID)
+		So(err, ShouldBeNil)
+		So(stats, ShouldResemble, &UserStats{TotalProjectCount: 2, PublicProjectCount: 1})
+	})
+}
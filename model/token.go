@@ -1,50 +1,287 @@
 package model
 
 import (
-	"math/rand"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
 	"time"
 
+	"github.com/asaskevich/govalidator"
+	"github.com/tkusd/server/config"
 	"github.com/tkusd/server/model/types"
 	"github.com/tkusd/server/util"
 )
 
 const (
-	secretLength = 64
+	// secretLength is the number of random bytes of a secret
+	secretLength = 32
+
+	// Kinds of tokens
+	TokenKindSession  = "session"
+	TokenKindPersonal = "personal"
+
+	defaultTokenLifetime        = time.Hour
+	defaultRefreshTokenLifetime = 30 * 24 * time.Hour
+
+	// The last used time is only updated once in this interval to reduce
+	// writes to the database.
+	tokenTouchInterval = time.Minute
 )
 
+var errTokenMismatch = errors.New("token secret mismatch")
+
 // Token represents the data structure of a token.
 type Token struct {
-	ID        types.UUID       `json:"id"`
-	UserID    types.UUID       `json:"user_id"`
-	Secret    types.Base64Hash `json:"secret"`
-	CreatedAt types.Time       `json:"created_at"`
-	UpdatedAt types.Time       `json:"updated_at"`
+	ID                types.UUID       `json:"id"`
+	UserID            types.UUID       `json:"user_id"`
+	Secret            types.Base64Hash `json:"secret" sql:"-"`
+	RefreshSecret     types.Base64Hash `json:"refresh_secret" sql:"-"`
+	SecretHash        types.Hash       `json:"-"`
+	RefreshSecretHash types.Hash       `json:"-"`
+	CreatedAt         types.Time       `json:"created_at"`
+	UpdatedAt         types.Time       `json:"updated_at"`
+	ExpiresAt         types.NullTime   `json:"expires_at"`
+	RefreshExpiresAt  types.Time       `json:"refresh_expires_at"`
+	LastUsedAt        types.Time       `json:"last_used_at"`
+	IP                string           `json:"ip"`
+	UserAgent         string           `json:"user_agent"`
+	Kind              string           `json:"kind"`
+	Name              string           `json:"name"`
+	Scopes            types.Scopes     `json:"scopes"`
+	ProjectID         types.UUID       `json:"project_id"`
 }
 
 func (t *Token) WithoutSecret() map[string]interface{} {
 	return map[string]interface{}{
-		"id":         t.ID,
-		"user_id":    t.UserID,
-		"created_at": t.CreatedAt,
-		"updated_at": t.UpdatedAt,
+		"id":                 t.ID,
+		"user_id":            t.UserID,
+		"created_at":         t.CreatedAt,
+		"updated_at":         t.UpdatedAt,
+		"expires_at":         t.ExpiresAt,
+		"refresh_expires_at": t.RefreshExpiresAt,
+		"last_used_at":       t.LastUsedAt,
+		"ip":                 t.IP,
+		"user_agent":         t.UserAgent,
+		"kind":               t.Kind,
+		"name":               t.Name,
+		"scopes":             t.Scopes,
+		"project_id":         t.ProjectID,
+	}
+}
+
+func tokenLifetime() time.Duration {
+	if s := config.Config.Token.Lifetime; s > 0 {
+		return time.Duration(s) * time.Second
+	}
+
+	return defaultTokenLifetime
+}
+
+func refreshTokenLifetime() time.Duration {
+	if s := config.Config.Token.RefreshLifetime; s > 0 {
+		return time.Duration(s) * time.Second
+	}
+
+	return defaultRefreshTokenLifetime
+}
+
+func generateSecret() (types.Base64Hash, error) {
+	hash := make(types.Hash, secretLength)
+
+	if _, err := rand.Read(hash); err != nil {
+		return types.Base64Hash{}, err
+	}
+
+	return types.Base64Hash{hash}, nil
+}
+
+// hashSecret returns the SHA-256 hash of the secret. Only hashes are stored in
+// the database.
+func hashSecret(secret types.Base64Hash) types.Hash {
+	return types.SHA256(string(secret.Hash))
+}
+
+// renew generates new secrets and resets the expiry time. The plain secrets are
+// only available until the token is loaded again. The expiry time of personal
+// tokens is set by the user, so it's not changed.
+func (t *Token) renew() error {
+	var err error
+	now := time.Now()
+
+	if t.Secret, err = generateSecret(); err != nil {
+		return err
+	}
+
+	if t.RefreshSecret, err = generateSecret(); err != nil {
+		return err
+	}
+
+	t.SecretHash = hashSecret(t.Secret)
+	t.RefreshSecretHash = hashSecret(t.RefreshSecret)
+
+	t.LastUsedAt = types.Time{Time: now}
+	t.RefreshExpiresAt = types.Time{Time: now.Add(refreshTokenLifetime())}
+
+	if t.Kind != TokenKindPersonal {
+		t.ExpiresAt = types.NullTime{Time: now.Add(tokenLifetime())}
 	}
+
+	return nil
 }
 
 func (t *Token) BeforeCreate() error {
-	randomStr := util.RandStringBytesMaskImprSrc("0123456789abcdef", rand.NewSource(time.Now().UnixNano()), secretLength)
-	hash, err := types.DecodeHash(randomStr)
+	if t.Kind == "" {
+		t.Kind = TokenKindSession
+	}
 
-	if err != nil {
+	if len(t.Scopes) == 0 {
+		t.Scopes = types.Scopes{types.ScopeAll}
+	}
+
+	return t.renew()
+}
+
+func (t *Token) validate() error {
+	if t.Kind != TokenKindPersonal {
+		return nil
+	}
+
+	t.Name = govalidator.Trim(t.Name, "")
+
+	if t.Name == "" {
+		return &util.APIError{
+			Field:   "name",
+			Code:    util.RequiredError,
+			Message: "Name is required.",
+		}
+	}
+
+	if len(t.Name) > 255 {
+		return &util.APIError{
+			Field:   "name",
+			Code:    util.LengthError,
+			Message: "Maximum length of name is 255.",
+		}
+	}
+
+	if len(t.Scopes) == 0 {
+		return &util.APIError{
+			Field:   "scopes",
+			Code:    util.RequiredError,
+			Message: "Scopes are required.",
+		}
+	}
+
+	for _, scope := range t.Scopes {
+		if !types.IsValidScope(scope) {
+			return &util.APIError{
+				Field:   "scopes",
+				Code:    util.ValueError,
+				Message: "Unknown scope " + scope + ".",
+			}
+		}
+	}
+
+	if !t.ID.Valid() && !t.ExpiresAt.IsZero() && t.ExpiresAt.Before(time.Now()) {
+		return &util.APIError{
+			Field:   "expires_at",
+			Code:    util.ValueError,
+			Message: "Expiry time must be in the future.",
+		}
+	}
+
+	return nil
+}
+
+// IsExpired returns true if the token can't be used anymore. Personal tokens
+// without expiry time never expire.
+func (t *Token) IsExpired() bool {
+	return !t.ExpiresAt.IsZero() && time.Now().After(t.ExpiresAt.Time)
+}
+
+// AllowsUser returns true if the token can manage the account of the user.
+func (t *Token) AllowsUser() bool {
+	return t.Scopes.Has(types.ScopeAll) && !t.ProjectID.Valid()
+}
+
+// AllowsProject returns true if the scopes of the token allow the permission
+// on the project. If assets is true, the permission is for assets of the
+// project. It only checks the scopes, not the permission of the user.
+func (t *Token) AllowsProject(projectID types.UUID, perm types.Permission, assets bool) bool {
+	if t.ProjectID.Valid() && !t.ProjectID.Equal(projectID) {
+		return false
+	}
+
+	switch {
+	case t.Scopes.Has(types.ScopeAll):
+		return true
+	case perm.Has(types.PermissionAdmin):
+		return false
+	case t.Scopes.Has(types.ScopeWrite):
+		return true
+	case assets && t.Scopes.Has(types.ScopeAssets):
+		return true
+	}
+
+	return perm == types.PermissionRead && t.Scopes.Has(types.ScopeRead)
+}
+
+// IsRefreshExpired returns true if the token can't be refreshed anymore.
+func (t *Token) IsRefreshExpired() bool {
+	return time.Now().After(t.RefreshExpiresAt.Time)
+}
+
+// Touch updates the last used time of the token and extends the expiry time.
+// The expiry time can't exceed the expiry time of the refresh token. The
+// expiry time of personal tokens is never extended.
+func (t *Token) Touch() error {
+	now := time.Now()
+
+	if now.Sub(t.LastUsedAt.Time) < tokenTouchInterval {
+		return nil
+	}
+
+	expiresAt := t.ExpiresAt
+
+	if t.Kind != TokenKindPersonal {
+		expiresAt.Time = now.Add(tokenLifetime())
+
+		if expiresAt.After(t.RefreshExpiresAt.Time) {
+			expiresAt.Time = t.RefreshExpiresAt.Time
+		}
+	}
+
+	data := map[string]interface{}{
+		"last_used_at": now,
+		"expires_at":   expiresAt,
+	}
+
+	if err := db.Table("tokens").Where("id = ?", t.ID.String()).UpdateColumns(data).Error; err != nil {
 		return err
 	}
 
-	t.Secret = types.Base64Hash{hash}
+	t.LastUsedAt = types.Time{Time: now}
+	t.ExpiresAt = expiresAt
 
 	return nil
 }
 
+// Refresh rotates the secrets of the token. The old secret and refresh secret
+// become invalid.
+func (t *Token) Refresh() error {
+	if err := t.renew(); err != nil {
+		return err
+	}
+
+	return t.Save()
+}
+
 // Save creates or updates data in the database.
 func (t *Token) Save() error {
+	if err := t.validate(); err != nil {
+		return err
+	}
+
 	return db.Save(t).Error
 }
 
@@ -64,18 +301,97 @@ func GetToken(id types.UUID) (*Token, error) {
 	return token, nil
 }
 
-func GetTokenBySecret(str string) (*Token, error) {
-	hash, err := types.DecodeBase64(str)
+// GetUserTokens gets the session tokens of the user which can still be
+// refreshed. The most recently used one comes first.
+func GetUserTokens(userID types.UUID) ([]*Token, error) {
+	var list []*Token
+
+	err := db.Where("user_id = ? AND kind = ? AND refresh_expires_at > ?", userID.String(), TokenKindSession, time.Now()).
+		Order("last_used_at desc").
+		Find(&list).Error
 
 	if err != nil {
 		return nil, err
 	}
 
+	return list, nil
+}
+
+// GetPersonalTokens gets the personal tokens of the user. The newest one comes
+// first.
+func GetPersonalTokens(userID types.UUID) ([]*Token, error) {
+	var list []*Token
+
+	err := db.Where("user_id = ? AND kind = ?", userID.String(), TokenKindPersonal).
+		Order("created_at desc").
+		Find(&list).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func deleteUserTokens(userID, except types.UUID, kind string) error {
+	scope := db.Where("user_id = ?", userID.String())
+
+	if except.Valid() {
+		scope = scope.Where("id != ?", except.String())
+	}
+
+	if kind != "" {
+		scope = scope.Where("kind = ?", kind)
+	}
+
+	return scope.Delete(&Token{}).Error
+}
+
+// DeleteUserTokens deletes all tokens of the user, including personal tokens,
+// except the given one. All tokens are deleted if the exception is not valid.
+func DeleteUserTokens(userID, except types.UUID) error {
+	return deleteUserTokens(userID, except, "")
+}
+
+// DeleteUserSessions deletes the session tokens of the user except the given
+// one.
+func DeleteUserSessions(userID, except types.UUID) error {
+	return deleteUserTokens(userID, except, TokenKindSession)
+}
+
+// getTokenBy finds the token by the hash of the secret. The hash is compared
+// again in constant time.
+func getTokenBy(column, str string, field func(t *Token) types.Hash) (*Token, error) {
+	secret, err := types.DecodeBase64(str)
+
+	if err != nil {
+		return nil, err
+	}
+
+	hash := hashSecret(*secret)
 	token := new(Token)
 
-	if err := db.Where("secret = ?", hash.HexString()).First(token).Error; err != nil {
+	if err := db.Where(column+" = ?", hash.String()).First(token).Error; err != nil {
 		return nil, err
 	}
 
+	if subtle.ConstantTimeCompare(field(token), hash) != 1 {
+		return nil, errTokenMismatch
+	}
+
 	return token, nil
 }
+
+// GetTokenBySecret returns the token with the secret.
+func GetTokenBySecret(str string) (*Token, error) {
+	return getTokenBy("secret_hash", str, func(t *Token) types.Hash {
+		return t.SecretHash
+	})
+}
+
+// GetTokenByRefreshSecret returns the token with the refresh secret.
+func GetTokenByRefreshSecret(str string) (*Token, error) {
+	return getTokenBy("refresh_secret_hash", str, func(t *Token) types.Hash {
+		return t.RefreshSecretHash
+	})
+}
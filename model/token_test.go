@@ -2,10 +2,13 @@ package model
 
 import (
 	"testing"
+	"time"
 
 	"log"
 
 	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tkusd/server/model/types"
+	"github.com/tkusd/server/util"
 )
 
 func createTestToken(u *User) (*Token, error) {
@@ -38,6 +41,102 @@ func TestToken(t *testing.T) {
 		So(token.UserID, ShouldResemble, user.ID)
 	})
 
+	Convey("Secrets are hashed", t, func() {
+		token, err := createTestToken(user)
+		defer token.Delete()
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		So(token.Secret.IsEmpty(), ShouldBeFalse)
+
+		t, _ := GetToken(token.ID)
+		So(t.Secret.IsEmpty(), ShouldBeTrue)
+		So(t.SecretHash, ShouldResemble, types.SHA256(string(token.Secret.Hash)))
+		So(t.RefreshSecretHash, ShouldResemble, types.SHA256(string(token.RefreshSecret.Hash)))
+
+		t, _ = GetTokenBySecret(token.Secret.String())
+		So(t.ID, ShouldResemble, token.ID)
+
+		// The hash can't be used as the secret
+		t, _ = GetTokenBySecret(types.Base64Hash{t.SecretHash}.String())
+		So(t, ShouldBeNil)
+	})
+
+	Convey("Expiry", t, func() {
+		token, err := createTestToken(user)
+		defer token.Delete()
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		So(token.IsExpired(), ShouldBeFalse)
+		So(token.ExpiresAt.After(time.Now()), ShouldBeTrue)
+		So(token.RefreshExpiresAt.After(token.ExpiresAt.Time), ShouldBeTrue)
+
+		token.ExpiresAt = types.NullTime{Time: time.Now().Add(-time.Second)}
+		So(token.IsExpired(), ShouldBeTrue)
+	})
+
+	Convey("Touch", t, func() {
+		token, err := createTestToken(user)
+		defer token.Delete()
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		Convey("Skip recently used tokens", func() {
+			expiresAt := token.ExpiresAt
+			So(token.Touch(), ShouldBeNil)
+			So(token.ExpiresAt, ShouldResemble, expiresAt)
+		})
+
+		Convey("Extend the expiry time", func() {
+			token.LastUsedAt = types.Time{Time: time.Now().Add(-time.Hour)}
+			token.ExpiresAt = types.NullTime{Time: time.Now().Add(time.Minute)}
+			So(token.Touch(), ShouldBeNil)
+			So(token.ExpiresAt.After(time.Now().Add(time.Minute)), ShouldBeTrue)
+
+			t, _ := GetToken(token.ID)
+			So(t.LastUsedAt.Unix(), ShouldEqual, token.LastUsedAt.Unix())
+		})
+
+		Convey("Don't exceed the refresh expiry time", func() {
+			token.LastUsedAt = types.Time{Time: time.Now().Add(-time.Hour)}
+			token.RefreshExpiresAt = types.Time{Time: time.Now().Add(time.Minute)}
+			So(token.Touch(), ShouldBeNil)
+			So(token.ExpiresAt.Time, ShouldResemble, token.RefreshExpiresAt.Time)
+		})
+	})
+
+	Convey("Refresh", t, func() {
+		token, err := createTestToken(user)
+		defer token.Delete()
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		secret := token.Secret.String()
+		refreshSecret := token.RefreshSecret.String()
+
+		So(token.Refresh(), ShouldBeNil)
+		So(token.Secret.String(), ShouldNotEqual, secret)
+		So(token.RefreshSecret.String(), ShouldNotEqual, refreshSecret)
+
+		t, _ := GetTokenBySecret(secret)
+		So(t, ShouldBeNil)
+
+		t, _ = GetTokenByRefreshSecret(refreshSecret)
+		So(t, ShouldBeNil)
+
+		t, _ = GetTokenByRefreshSecret(token.RefreshSecret.String())
+		So(t.ID, ShouldResemble, token.ID)
+	})
+
 	Convey("Delete", t, func() {
 		token, err := createTestToken(user)
 
@@ -52,6 +151,135 @@ func TestToken(t *testing.T) {
 	})
 }
 
+func TestUserTokens(t *testing.T) {
+	user, err := createTestUser(fixtureUsers[0])
+	defer user.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	Convey("GetUserTokens", t, func() {
+		a, _ := createTestToken(user)
+		defer a.Delete()
+
+		b, _ := createTestToken(user)
+		defer b.Delete()
+
+		b.LastUsedAt = types.Time{Time: time.Now().Add(-time.Hour)}
+		So(b.Touch(), ShouldBeNil)
+
+		expired, _ := createTestToken(user)
+		defer expired.Delete()
+
+		expired.RefreshExpiresAt = types.Time{Time: time.Now().Add(-time.Second)}
+		So(expired.Save(), ShouldBeNil)
+
+		list, err := GetUserTokens(user.ID)
+		So(err, ShouldBeNil)
+		So(len(list), ShouldEqual, 2)
+		So(list[0].ID, ShouldResemble, b.ID)
+		So(list[1].ID, ShouldResemble, a.ID)
+	})
+
+	Convey("DeleteUserTokens", t, func() {
+		a, _ := createTestToken(user)
+		defer a.Delete()
+
+		b, _ := createTestToken(user)
+		defer b.Delete()
+
+		Convey("Keep the given token", func() {
+			So(DeleteUserTokens(user.ID, a.ID), ShouldBeNil)
+
+			t, _ := GetToken(a.ID)
+			So(t, ShouldNotBeNil)
+
+			t, _ = GetToken(b.ID)
+			So(t, ShouldBeNil)
+		})
+
+		Convey("Delete all tokens", func() {
+			So(DeleteUserTokens(user.ID, types.UUID{}), ShouldBeNil)
+
+			list, _ := GetUserTokens(user.ID)
+			So(list, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestPersonalToken(t *testing.T) {
+	user, err := createTestUser(fixtureUsers[0])
+	defer user.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	project, err := createTestProject(user)
+	defer project.Delete()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	Convey("Save", t, func() {
+		Convey("Name is required", func() {
+			token := &Token{UserID: user.ID, Kind: TokenKindPersonal, Scopes: types.Scopes{types.ScopeRead}}
+			err := token.Save()
+			So(err.(*util.APIError).Field, ShouldEqual, "name")
+		})
+
+		Convey("Unknown scope", func() {
+			token := &Token{UserID: user.ID, Kind: TokenKindPersonal, Name: "CI", Scopes: types.Scopes{"admin"}}
+			err := token.Save()
+			So(err.(*util.APIError).Code, ShouldEqual, util.ValueError)
+		})
+
+		Convey("No expiry time", func() {
+			token := &Token{UserID: user.ID, Kind: TokenKindPersonal, Name: "CI", Scopes: types.Scopes{types.ScopeRead}}
+			So(token.Save(), ShouldBeNil)
+			defer token.Delete()
+
+			So(token.ExpiresAt.IsZero(), ShouldBeTrue)
+			So(token.IsExpired(), ShouldBeFalse)
+
+			t, _ := GetToken(token.ID)
+			So(t.Scopes, ShouldResemble, types.Scopes{types.ScopeRead})
+			So(t.ExpiresAt.IsZero(), ShouldBeTrue)
+
+			list, _ := GetPersonalTokens(user.ID)
+			So(len(list), ShouldEqual, 1)
+
+			sessions, _ := GetUserTokens(user.ID)
+			So(sessions, ShouldBeEmpty)
+		})
+	})
+
+	Convey("Scopes", t, func() {
+		session := &Token{Scopes: types.Scopes{types.ScopeAll}}
+		read := &Token{Scopes: types.Scopes{types.ScopeRead}}
+		write := &Token{Scopes: types.Scopes{types.ScopeWrite}}
+		assets := &Token{Scopes: types.Scopes{types.ScopeAssets}}
+		scoped := &Token{Scopes: types.Scopes{types.ScopeAll}, ProjectID: project.ID}
+		other := types.NewRandomUUID()
+
+		So(session.AllowsUser(), ShouldBeTrue)
+		So(read.AllowsUser(), ShouldBeFalse)
+		So(scoped.AllowsUser(), ShouldBeFalse)
+
+		So(session.AllowsProject(project.ID, types.PermissionAdmin, false), ShouldBeTrue)
+		So(read.AllowsProject(project.ID, types.PermissionRead, false), ShouldBeTrue)
+		So(read.AllowsProject(project.ID, types.PermissionWrite, true), ShouldBeFalse)
+		So(write.AllowsProject(project.ID, types.PermissionWrite, false), ShouldBeTrue)
+		So(write.AllowsProject(project.ID, types.PermissionAdmin, false), ShouldBeFalse)
+		So(assets.AllowsProject(project.ID, types.PermissionWrite, true), ShouldBeTrue)
+		So(assets.AllowsProject(project.ID, types.PermissionRead, false), ShouldBeFalse)
+		So(scoped.AllowsProject(project.ID, types.PermissionAdmin, false), ShouldBeTrue)
+		So(scoped.AllowsProject(other, types.PermissionRead, false), ShouldBeFalse)
+	})
+}
+
 func TestGetToken(t *testing.T) {
 	user, err := createTestUser(fixtureUsers[0])
 	defer user.Delete()
@@ -77,8 +305,8 @@ func TestGetToken(t *testing.T) {
 		So(t.ID, ShouldResemble, token.ID)
 	})
 
-	Convey("Base64 ID", t, func() {
-		t, err := GetTokenBase64(token.ID.String())
+	Convey("Secret", t, func() {
+		t, err := GetTokenBySecret(token.Secret.String())
 
 		if err != nil {
 			log.Fatal(err)
@@ -0,0 +1,12 @@
+package types
+
+// Names of element types.
+const (
+	ElementTypeScreen = "screen"
+	ElementTypeView   = "view"
+	ElementTypeList   = "list"
+	ElementTypeText   = "text"
+	ElementTypeButton = "button"
+	ElementTypeImage  = "image"
+	ElementTypeInput  = "input"
+)
@@ -0,0 +1,108 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Permission represents the access rights on a project. It is stored as
+// BIT(3) in the database, from left to right: read, write and admin.
+type Permission uint8
+
+const (
+	// PermissionAdmin allows to manage collaborators and project settings.
+	PermissionAdmin Permission = 1 << iota
+	// PermissionWrite allows to edit elements, events and assets.
+	PermissionWrite
+	// PermissionRead allows to view a private project.
+	PermissionRead
+
+	// PermissionAll contains all permissions.
+	PermissionAll = PermissionRead | PermissionWrite | PermissionAdmin
+)
+
+type permissionJSON struct {
+	Read  bool `json:"read"`
+	Write bool `json:"write"`
+	Admin bool `json:"admin"`
+}
+
+// Has returns true if all bits of p are set.
+func (perm Permission) Has(p Permission) bool {
+	return perm&p == p
+}
+
+// Normalize returns the permission with implied bits. Admins can write and
+// writers can read.
+func (perm Permission) Normalize() Permission {
+	if perm.Has(PermissionAdmin) {
+		perm |= PermissionWrite
+	}
+
+	if perm.Has(PermissionWrite) {
+		perm |= PermissionRead
+	}
+
+	return perm & PermissionAll
+}
+
+// String implements the fmt.Stringer interface. It returns a bit string.
+func (perm Permission) String() string {
+	return fmt.Sprintf("%03b", uint8(perm&PermissionAll))
+}
+
+// Scan implements the sql.Scanner interface.
+func (perm *Permission) Scan(val interface{}) error {
+	if b, ok := val.([]byte); ok {
+		i, err := strconv.ParseUint(string(b), 2, 8)
+
+		if err != nil {
+			return err
+		}
+
+		*perm = Permission(i)
+	}
+
+	return nil
+}
+
+// Value implements the driver.Valuer interface.
+func (perm Permission) Value() (driver.Value, error) {
+	return perm.String(), nil
+}
+
+// MarshalJSON implements json.Marshaler interface.
+func (perm Permission) MarshalJSON() ([]byte, error) {
+	return json.Marshal(permissionJSON{
+		Read:  perm.Has(PermissionRead),
+		Write: perm.Has(PermissionWrite),
+		Admin: perm.Has(PermissionAdmin),
+	})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (perm *Permission) UnmarshalJSON(data []byte) error {
+	var obj permissionJSON
+
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+
+	*perm = 0
+
+	if obj.Read {
+		*perm |= PermissionRead
+	}
+
+	if obj.Write {
+		*perm |= PermissionWrite
+	}
+
+	if obj.Admin {
+		*perm |= PermissionAdmin
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package types
+
+import (
+	"database/sql/driver"
+	"strings"
+)
+
+// Token scopes
+const (
+	// ScopeAll allows everything the user can do, including managing the
+	// account.
+	ScopeAll = "all"
+	// ScopeRead allows to view projects, elements, events and assets.
+	ScopeRead = "read"
+	// ScopeWrite allows to view and edit projects, elements, events and assets.
+	ScopeWrite = "write"
+	// ScopeAssets allows to view and edit assets.
+	ScopeAssets = "assets"
+)
+
+// Scopes is a list of token scopes. It is stored as a comma-separated string
+// in the database.
+type Scopes []string
+
+// Scan implements the sql.Scanner interface.
+func (s *Scopes) Scan(val interface{}) error {
+	var str string
+
+	switch v := val.(type) {
+	case []byte:
+		str = string(v)
+	case string:
+		str = v
+	}
+
+	*s = Scopes{}
+
+	for _, scope := range strings.Split(str, ",") {
+		if scope != "" {
+			*s = append(*s, scope)
+		}
+	}
+
+	return nil
+}
+
+// Value implements the driver.Valuer interface.
+func (s Scopes) Value() (driver.Value, error) {
+	return strings.Join(s, ","), nil
+}
+
+// Has returns true if the scope is in the list.
+func (s Scopes) Has(scope string) bool {
+	for _, item := range s {
+		if item == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsValidScope returns true if the scope is known.
+func IsValidScope(scope string) bool {
+	switch scope {
+	case ScopeAll, ScopeRead, ScopeWrite, ScopeAssets:
+		return true
+	}
+
+	return false
+}
@@ -53,3 +53,37 @@ func ParseISOTime(str string) (time.Time, error) {
 func Now() Time {
 	return Time{time.Now().UTC()}
 }
+
+// NullTime is a Time which is stored and printed as null when it's zero.
+type NullTime struct {
+	time.Time
+}
+
+// Scan implements the sql.Scanner interface.
+func (t *NullTime) Scan(data interface{}) error {
+	if val, ok := data.(time.Time); ok {
+		t.Time = val
+	} else {
+		t.Time = time.Time{}
+	}
+
+	return nil
+}
+
+// Value implements the driver.Valuer interface.
+func (t NullTime) Value() (driver.Value, error) {
+	if t.IsZero() {
+		return nil, nil
+	}
+
+	return t.Time.Format(time.RFC3339Nano), nil
+}
+
+// MarshalJSON implements json.Marshaler interface.
+func (t NullTime) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+
+	return []byte(`"` + ISOTime(t.Time) + `"`), nil
+}
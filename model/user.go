@@ -1,6 +1,10 @@
 package model
 
 import (
+	"database/sql"
+	"net/http"
+	"time"
+
 	"github.com/asaskevich/govalidator"
 	"github.com/lib/pq"
 	"github.com/tkusd/server/config"
@@ -10,23 +14,29 @@ import (
 
 // User represents the data structure of a user.
 type User struct {
-	ID                 types.UUID `json:"id"`
-	Name               string     `json:"name"`
-	Password           []byte     `json:"-"`
-	Email              string     `json:"email"`
-	Avatar             string     `json:"avatar"`
-	CreatedAt          types.Time `json:"created_at"`
-	UpdatedAt          types.Time `json:"updated_at"`
-	IsActivated        bool       `json:"is_activated"`
-	ActivationToken    types.UUID `json:"-"`
-	Language           string     `json:"language"`
-	PasswordResetToken types.UUID `json:"-"`
-	PasswordResetAt    types.Time `json:"-"`
+	ID                 types.UUID     `json:"id"`
+	Name               string         `json:"name"`
+	Password           []byte         `json:"-"`
+	Email              string         `json:"email"`
+	Avatar             string         `json:"avatar"`
+	CreatedAt          types.Time     `json:"created_at"`
+	UpdatedAt          types.Time     `json:"updated_at"`
+	IsActivated        bool           `json:"is_activated"`
+	ActivationToken    types.UUID     `json:"-"`
+	Language           string         `json:"language"`
+	PasswordResetToken types.UUID     `json:"-"`
+	PasswordResetAt    types.Time     `json:"-"`
+	FailedLoginCount   int            `json:"-"`
+	LockedUntil        types.NullTime `json:"-"`
+
+	// Virtual attributes
+	Stats *UserStats `json:"stats,omitempty" sql:"-"`
+	Usage *UserUsage `json:"usage,omitempty" sql:"-"`
 }
 
 // PublicProfile returns the data for public display.
 func (u *User) PublicProfile() map[string]interface{} {
-	return map[string]interface{}{
+	profile := map[string]interface{}{
 		"id":         u.ID,
 		"name":       u.Name,
 		"avatar":     u.Avatar,
@@ -34,6 +44,15 @@ func (u *User) PublicProfile() map[string]interface{} {
 		"updated_at": u.UpdatedAt,
 		"language":   u.Language,
 	}
+
+	// The number of private projects is hidden
+	if u.Stats != nil {
+		profile["stats"] = map[string]interface{}{
+			"public_project_count": u.Stats.PublicProjectCount,
+		}
+	}
+
+	return profile
 }
 
 // Save creates or updates data in the database.
@@ -91,7 +110,25 @@ func (u *User) Save() error {
 		}
 	}
 
-	if err := db.Save(u).Error; err != nil {
+	tx := db.Begin()
+
+	// Lockout columns are only changed by Authenticate and Unlock. They are
+	// reloaded in the locked row, so failed attempts recorded meanwhile won't
+	// be overwritten.
+	if u.ID.Valid() {
+		err := tx.Raw("SELECT failed_login_count, locked_until FROM users WHERE id = ? FOR UPDATE", u.ID.String()).
+			Row().
+			Scan(&u.FailedLoginCount, &u.LockedUntil)
+
+		if err != nil && err != sql.ErrNoRows {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Save(u).Error; err != nil {
+		tx.Rollback()
+
 		switch e := err.(type) {
 		case *pq.Error:
 			switch e.Code.Name() {
@@ -107,7 +144,7 @@ func (u *User) Save() error {
 		return err
 	}
 
-	return nil
+	return tx.Commit().Error
 }
 
 func (u *User) AfterCreate() error {
@@ -185,11 +222,23 @@ func (u *User) GeneratePassword(password string) error {
 
 // Authenticate authenticates a user.
 func (u *User) Authenticate(password string) error {
+	if u.IsLocked() {
+		return &util.APIError{
+			Code:    util.UserLockedError,
+			Message: "The account is locked until " + types.ISOTime(u.LockedUntil.Time) + ".",
+			Status:  http.StatusForbidden,
+		}
+	}
+
 	if err := validatePassword(password); err != nil {
 		return err
 	}
 
 	if err := util.CompareBcryptHash(u.Password, password); err != nil {
+		if err := u.recordFailedLogin(); err != nil {
+			return err
+		}
+
 		return &util.APIError{
 			Field:   "password",
 			Code:    util.WrongPasswordError,
@@ -197,9 +246,65 @@ func (u *User) Authenticate(password string) error {
 		}
 	}
 
+	if u.FailedLoginCount > 0 {
+		return u.updateLockout(0, types.NullTime{})
+	}
+
 	return nil
 }
 
+// Unlock resets the failed login attempts and unlocks the account.
+func (u *User) Unlock() error {
+	return u.updateLockout(0, types.NullTime{})
+}
+
+// IsLocked returns true if the account is locked because of failed login
+// attempts.
+func (u *User) IsLocked() bool {
+	return !u.LockedUntil.IsZero() && time.Now().Before(u.LockedUntil.Time)
+}
+
+func (u *User) updateLockout(count int, lockedUntil types.NullTime) error {
+	data := map[string]interface{}{
+		"failed_login_count": count,
+		"locked_until":       lockedUntil,
+	}
+
+	if err := db.Table("users").Where("id = ?", u.ID.String()).UpdateColumns(data).Error; err != nil {
+		return err
+	}
+
+	u.FailedLoginCount = count
+	u.LockedUntil = lockedUntil
+
+	return nil
+}
+
+// recordFailedLogin increases the number of failed login attempts. The account
+// is locked when the number reaches the limit.
+func (u *User) recordFailedLogin() error {
+	lockout := config.Config.Lockout
+
+	if lockout.Attempts <= 0 {
+		return nil
+	}
+
+	err := db.Raw("UPDATE users SET failed_login_count = failed_login_count + 1 WHERE id = ? RETURNING failed_login_count", u.ID.String()).
+		Row().
+		Scan(&u.FailedLoginCount)
+
+	if err != nil {
+		return err
+	}
+
+	if u.FailedLoginCount < lockout.Attempts {
+		return nil
+	}
+
+	lockedUntil := time.Now().Add(time.Duration(lockout.Duration) * time.Second)
+	return u.updateLockout(0, types.NullTime{Time: lockedUntil})
+}
+
 // GetUser returns the user data.
 func GetUser(id types.UUID) (*User, error) {
 	user := new(User)
@@ -9,6 +9,7 @@ import (
 	"log"
 
 	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tkusd/server/config"
 	"github.com/tkusd/server/model/types"
 	"github.com/tkusd/server/util"
 )
@@ -332,3 +333,80 @@ func TestGetUserByEmail(t *testing.T) {
 		So(err, ShouldNotBeNil)
 	})
 }
+
+func TestLockout(t *testing.T) {
+	lockout := config.Config.Lockout
+	defer func() { config.Config.Lockout = lockout }()
+
+	config.Config.Lockout.Attempts = 3
+	config.Config.Lockout.Duration = 60
+
+	Convey("Lock after failed attempts", t, func() {
+		user, err := createTestUser(fixtureUsers[0])
+		defer user.Delete()
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		So(user.Authenticate("wrong password"), ShouldNotBeNil)
+		So(user.Authenticate("wrong password"), ShouldNotBeNil)
+		So(user.IsLocked(), ShouldBeFalse)
+
+		err = user.Authenticate("wrong password")
+		So(err.(*util.APIError).Code, ShouldEqual, util.WrongPasswordError)
+		So(user.IsLocked(), ShouldBeTrue)
+
+		// Locked even if the password is correct
+		err = user.Authenticate(fixtureUsers[0].Password)
+		So(err.(*util.APIError).Code, ShouldEqual, util.UserLockedError)
+
+		u, _ := GetUser(user.ID)
+		So(u.IsLocked(), ShouldBeTrue)
+	})
+
+	Convey("Reset after successful login", t, func() {
+		user, err := createTestUser(fixtureUsers[0])
+		defer user.Delete()
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		So(user.Authenticate("wrong password"), ShouldNotBeNil)
+		So(user.FailedLoginCount, ShouldEqual, 1)
+		So(user.Authenticate(fixtureUsers[0].Password), ShouldBeNil)
+
+		u, _ := GetUser(user.ID)
+		So(u.FailedLoginCount, ShouldEqual, 0)
+	})
+
+	Convey("Saving a user doesn't change the lockout", t, func() {
+		user, err := createTestUser(fixtureUsers[0])
+		defer user.Delete()
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		stale, _ := GetUser(user.ID)
+
+		for i := 0; i < 3; i++ {
+			user.Authenticate("wrong password")
+		}
+
+		stale.Name = "Stale"
+		So(stale.Save(), ShouldBeNil)
+		So(stale.IsLocked(), ShouldBeTrue)
+
+		u, _ := GetUser(user.ID)
+		So(u.Name, ShouldEqual, "Stale")
+		So(u.IsLocked(), ShouldBeTrue)
+
+		So(u.Unlock(), ShouldBeNil)
+		So(u.IsLocked(), ShouldBeFalse)
+
+		u, _ = GetUser(user.ID)
+		So(u.IsLocked(), ShouldBeFalse)
+	})
+}
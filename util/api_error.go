@@ -2,22 +2,26 @@ package util
 
 // 1000: Generic error
 const (
-	UnknownError           = 1000
-	ServerError            = 1001
-	NotFoundError          = 1002
-	RateLimitExceededError = 1003
+	UnknownError            = 1000
+	ServerError             = 1001
+	NotFoundError           = 1002
+	RateLimitExceededError  = 1003
+	PreconditionFailedError = 1004
 )
 
 // 1100: Validation error
 const (
-	RequiredError        = 1100
-	ContentTypeError     = 1101
-	DeserializationError = 1102
-	TypeError            = 1103
-	EmailError           = 1104
-	LengthError          = 1105
-	URLError             = 1106
-	UUIDError            = 1108
+	RequiredError               = 1100
+	ContentTypeError            = 1101
+	DeserializationError        = 1102
+	TypeError                   = 1103
+	EmailError                  = 1104
+	LengthError                 = 1105
+	URLError                    = 1106
+	UnsupportedElementTypeError = 1107
+	UUIDError                   = 1108
+	PatchError                  = 1109
+	ValueError                  = 1110
 )
 
 // 1200: Resource error
@@ -28,6 +32,8 @@ const (
 	ElementNotFoundError = 1203
 	AssetNotFound        = 1204
 	EventNotFound        = 1206
+	CollaboratorNotFound = 1207
+	RevisionNotFound     = 1208
 )
 
 // 1300: Data error
@@ -42,6 +48,16 @@ const (
 	PasswordResetTokenExpiredError   = 1310
 	UserAlreadyActivatedError        = 1311
 	UserActivationTokenMismatchError = 1312
+	CollaboratorExistError           = 1313
+	CollaboratorIsOwnerError         = 1314
+	ElementCircularError             = 1315
+	ElementChildTypeError            = 1316
+	AssetNotOwnedByProjectError      = 1317
+	AssetInUseError                  = 1318
+	QuotaExceededError               = 1319
+	TokenExpiredError                = 1320
+	TokenScopeError                  = 1321
+	UserLockedError                  = 1322
 )
 
 // APIError represents an API error.
@@ -0,0 +1,72 @@
+package util
+
+import (
+	"image"
+	"image/color"
+)
+
+// ResizeImage scales down the image to fit in a square of the given size.
+// The aspect ratio is preserved and each pixel of the result is the average
+// of the source pixels it covers. Images smaller than size are returned as is.
+func ResizeImage(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+
+	if size <= 0 || (srcW <= size && srcH <= size) {
+		return src
+	}
+
+	dstW, dstH := size, size
+
+	if srcW > srcH {
+		dstH = srcH * size / srcW
+	} else {
+		dstW = srcW * size / srcH
+	}
+
+	if dstW < 1 {
+		dstW = 1
+	}
+
+	if dstH < 1 {
+		dstH = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+
+	for y := 0; y < dstH; y++ {
+		y0 := bounds.Min.Y + y*srcH/dstH
+		y1 := bounds.Min.Y + (y+1)*srcH/dstH
+
+		for x := 0; x < dstW; x++ {
+			x0 := bounds.Min.X + x*srcW/dstW
+			x1 := bounds.Min.X + (x+1)*srcW/dstW
+
+			var r, g, b, a, n uint64
+
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					b += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+
+			if n == 0 {
+				continue
+			}
+
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,328 @@
+package util
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// PatchOperation is an operation of JSON Patch (RFC 6902).
+type PatchOperation struct {
+	Op    string           `json:"op"`
+	Path  string           `json:"path"`
+	From  string           `json:"from"`
+	Value *json.RawMessage `json:"value"`
+}
+
+func patchError(path, message string) error {
+	return &APIError{
+		Code:    PatchError,
+		Field:   path,
+		Message: message,
+	}
+}
+
+// parsePointer parses a JSON Pointer (RFC 6901).
+func parsePointer(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	if path[0] != '/' {
+		return nil, patchError(path, "Path must start with /.")
+	}
+
+	tokens := strings.Split(path[1:], "/")
+
+	for i, token := range tokens {
+		token = strings.Replace(token, "~1", "/", -1)
+		tokens[i] = strings.Replace(token, "~0", "~", -1)
+	}
+
+	return tokens, nil
+}
+
+// arrayIndex parses the index of an array. "-" refers to the end of the array
+// if allowEnd is true.
+func arrayIndex(token string, length int, allowEnd bool) (int, bool) {
+	if allowEnd && token == "-" {
+		return length, true
+	}
+
+	// Leading zeros are not allowed
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, false
+	}
+
+	i, err := strconv.Atoi(token)
+
+	if err != nil || i < 0 {
+		return 0, false
+	}
+
+	if allowEnd {
+		return i, i <= length
+	}
+
+	return i, i < length
+}
+
+func patchGet(doc interface{}, tokens []string, path string) (interface{}, error) {
+	for _, token := range tokens {
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			val, ok := v[token]
+
+			if !ok {
+				return nil, patchError(path, "Path does not exist.")
+			}
+
+			doc = val
+
+		case []interface{}:
+			i, ok := arrayIndex(token, len(v), false)
+
+			if !ok {
+				return nil, patchError(path, "Array index is invalid.")
+			}
+
+			doc = v[i]
+
+		default:
+			return nil, patchError(path, "Path does not exist.")
+		}
+	}
+
+	return doc, nil
+}
+
+// patchSet sets the value at the path and returns the new document. The
+// value is inserted if insert is true, or replaced otherwise.
+func patchSet(doc interface{}, tokens []string, path string, value interface{}, insert bool) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	last := len(tokens) - 1
+	parent, err := patchGet(doc, tokens[:last], path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	token := tokens[last]
+
+	switch v := parent.(type) {
+	case map[string]interface{}:
+		if _, ok := v[token]; !ok && !insert {
+			return nil, patchError(path, "Path does not exist.")
+		}
+
+		v[token] = value
+
+	case []interface{}:
+		i, ok := arrayIndex(token, len(v), insert)
+
+		if !ok {
+			return nil, patchError(path, "Array index is invalid.")
+		}
+
+		if insert {
+			v = append(v, nil)
+			copy(v[i+1:], v[i:])
+		}
+
+		v[i] = value
+
+		// The slice may be reallocated
+		return patchSet(doc, tokens[:last], path, v, false)
+
+	default:
+		return nil, patchError(path, "Path does not exist.")
+	}
+
+	return doc, nil
+}
+
+func patchRemove(doc interface{}, tokens []string, path string) (interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, patchError(path, "The root can't be removed.")
+	}
+
+	last := len(tokens) - 1
+	parent, err := patchGet(doc, tokens[:last], path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	token := tokens[last]
+
+	switch v := parent.(type) {
+	case map[string]interface{}:
+		if _, ok := v[token]; !ok {
+			return nil, patchError(path, "Path does not exist.")
+		}
+
+		delete(v, token)
+
+	case []interface{}:
+		i, ok := arrayIndex(token, len(v), false)
+
+		if !ok {
+			return nil, patchError(path, "Array index is invalid.")
+		}
+
+		v = append(v[:i], v[i+1:]...)
+		return patchSet(doc, tokens[:last], path, v, false)
+
+	default:
+		return nil, patchError(path, "Path does not exist.")
+	}
+
+	return doc, nil
+}
+
+func deepCopyJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func applyPatchOperation(doc interface{}, op *PatchOperation) (interface{}, error) {
+	var value interface{}
+
+	tokens, err := parsePointer(op.Path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, patchError(op.Path, "Value is required.")
+		}
+
+		if err := json.Unmarshal(*op.Value, &value); err != nil {
+			return nil, patchError(op.Path, "Value is invalid.")
+		}
+	}
+
+	switch op.Op {
+	case "add":
+		return patchSet(doc, tokens, op.Path, value, true)
+
+	case "remove":
+		return patchRemove(doc, tokens, op.Path)
+
+	case "replace":
+		return patchSet(doc, tokens, op.Path, value, false)
+
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if value, err = patchGet(doc, from, op.From); err != nil {
+			return nil, err
+		}
+
+		if op.Op == "move" {
+			if strings.HasPrefix(op.Path+"/", op.From+"/") {
+				return nil, patchError(op.Path, "A value can't be moved into itself.")
+			}
+
+			if doc, err = patchRemove(doc, from, op.From); err != nil {
+				return nil, err
+			}
+		} else if value, err = deepCopyJSON(value); err != nil {
+			return nil, err
+		}
+
+		return patchSet(doc, tokens, op.Path, value, true)
+
+	case "test":
+		current, err := patchGet(doc, tokens, op.Path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !reflect.DeepEqual(current, value) {
+			return nil, patchError(op.Path, "Test failed.")
+		}
+
+		return doc, nil
+	}
+
+	return nil, patchError(op.Path, "Operation "+op.Op+" is not supported.")
+}
+
+// ApplyJSONPatch applies the JSON Patch (RFC 6902) to the document and returns
+// the result. The document is not modified. The field of the returned error
+// is the path of the failed operation.
+func ApplyJSONPatch(doc interface{}, ops []PatchOperation) (interface{}, error) {
+	result, err := deepCopyJSON(doc)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range ops {
+		if result, err = applyPatchOperation(result, &ops[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// ApplyMergePatch applies the JSON Merge Patch (RFC 7386) to the document and
+// returns the result. The document is not modified.
+func ApplyMergePatch(doc interface{}, patch interface{}) (interface{}, error) {
+	result, err := deepCopyJSON(doc)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return mergePatch(result, patch), nil
+}
+
+func mergePatch(doc interface{}, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+
+	if !ok {
+		return patch
+	}
+
+	target, ok := doc.(map[string]interface{})
+
+	if !ok {
+		target = map[string]interface{}{}
+	}
+
+	for key, value := range p {
+		if value == nil {
+			delete(target, key)
+		} else {
+			target[key] = mergePatch(target[key], value)
+		}
+	}
+
+	return target
+}
@@ -0,0 +1,124 @@
+package util
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimitResult is the state of a bucket after taking a token.
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Time
+	RetryAfter time.Duration
+}
+
+// RateLimitStore stores token buckets.
+type RateLimitStore interface {
+	// Take takes a token from the bucket of the key. The bucket holds at most
+	// limit tokens and is refilled in the window.
+	Take(key string, limit int, window time.Duration) (*RateLimitResult, error)
+}
+
+// TokenBucket is a bucket of tokens which is refilled continuously. A new
+// bucket is full.
+type TokenBucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// Take refills the bucket and takes a token if there is any.
+func (b *TokenBucket) Take(now time.Time, limit int, window time.Duration) *RateLimitResult {
+	capacity := float64(limit)
+	rate := capacity / window.Seconds()
+
+	if b.UpdatedAt.IsZero() {
+		b.Tokens = capacity
+	} else {
+		b.Tokens = math.Min(capacity, b.Tokens+now.Sub(b.UpdatedAt).Seconds()*rate)
+	}
+
+	b.UpdatedAt = now
+
+	result := &RateLimitResult{
+		Allowed: b.Tokens >= 1,
+		Limit:   limit,
+	}
+
+	if result.Allowed {
+		b.Tokens--
+	} else {
+		result.RetryAfter = secondsToDuration((1 - b.Tokens) / rate)
+	}
+
+	result.Remaining = int(b.Tokens)
+	result.Reset = now.Add(secondsToDuration((capacity - b.Tokens) / rate))
+
+	return result
+}
+
+// IsFull returns true if the bucket has been refilled completely, so it can
+// be forgotten.
+func (b *TokenBucket) IsFull(now time.Time, limit int, window time.Duration) bool {
+	rate := float64(limit) / window.Seconds()
+	return b.Tokens+now.Sub(b.UpdatedAt).Seconds()*rate >= float64(limit)
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+type memoryBucket struct {
+	TokenBucket
+	limit  int
+	window time.Duration
+}
+
+// MemoryRateLimitStore stores buckets in memory. Buckets are not shared
+// between processes.
+type MemoryRateLimitStore struct {
+	sync.Mutex
+	buckets map[string]*memoryBucket
+	takes   int
+}
+
+// memoryRateLimitCleanInterval is the number of takes between cleanups.
+const memoryRateLimitCleanInterval = 1000
+
+// NewMemoryRateLimitStore creates a new memory store.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: map[string]*memoryBucket{},
+	}
+}
+
+// Take implements RateLimitStore.
+func (s *MemoryRateLimitStore) Take(key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now()
+	s.takes++
+
+	// Full buckets are removed to free memory
+	if s.takes >= memoryRateLimitCleanInterval {
+		s.takes = 0
+
+		for k, b := range s.buckets {
+			if b.IsFull(now, b.limit, b.window) {
+				delete(s.buckets, k)
+			}
+		}
+	}
+
+	b, ok := s.buckets[key]
+
+	if !ok {
+		b = &memoryBucket{limit: limit, window: window}
+		s.buckets[key] = b
+	}
+
+	return b.Take(now, limit, window), nil
+}
@@ -0,0 +1,259 @@
+package util
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/tkusd/server/model/types"
+)
+
+// Schema is a subset of JSON Schema used to validate JSON documents.
+// Properties not listed in Properties are allowed unless
+// AdditionalProperties is false.
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Format               string             `json:"format,omitempty"`
+}
+
+func schemaError(code int, field, message string) error {
+	return &APIError{
+		Code:    code,
+		Field:   field,
+		Message: message,
+	}
+}
+
+// toNumber converts the value to float64. JSON numbers are decoded as float64
+// but values set in Go may be integers.
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+
+	return 0, false
+}
+
+func (s *Schema) checkType(v interface{}, field string) error {
+	var ok bool
+
+	switch s.Type {
+	case "":
+		return nil
+	case "object":
+		_, ok = v.(map[string]interface{})
+	case "array":
+		_, ok = v.([]interface{})
+	case "string":
+		_, ok = v.(string)
+	case "boolean":
+		_, ok = v.(bool)
+	case "number":
+		_, ok = toNumber(v)
+	case "integer":
+		var n float64
+
+		if n, ok = toNumber(v); ok {
+			ok = n == math.Trunc(n)
+		}
+	}
+
+	if !ok {
+		return schemaError(TypeError, field, field+" must be a "+s.Type+".")
+	}
+
+	return nil
+}
+
+func (s *Schema) checkFormat(str string, field string) error {
+	switch s.Format {
+	case "uuid", "asset":
+		if !types.ParseUUID(str).Valid() {
+			return schemaError(UUIDError, field, "UUID is invalid.")
+		}
+
+	case "uri":
+		if !govalidator.IsURL(str) {
+			return schemaError(URLError, field, "URL is invalid.")
+		}
+	}
+
+	return nil
+}
+
+func (s *Schema) checkEnum(v interface{}, field string) error {
+	if len(s.Enum) == 0 {
+		return nil
+	}
+
+	var values []string
+
+	for _, item := range s.Enum {
+		if item == v {
+			return nil
+		}
+
+		values = append(values, fmt.Sprint(item))
+	}
+
+	return schemaError(ValueError, field, field+" must be one of "+strings.Join(values, ", ")+".")
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// sortedKeys returns the keys of the object in order, so the first invalid
+// property is always the same one.
+func sortedKeys(obj map[string]interface{}) []string {
+	keys := make([]string, 0, len(obj))
+
+	for key := range obj {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// Validate validates the value against the schema. The field of the returned
+// error is the path of the invalid value joined with dots, e.g.
+// "attributes.items.2".
+func (s *Schema) Validate(v interface{}, field string) error {
+	return s.validate(v, nil, false, field)
+}
+
+// ValidateChanges is like Validate but values which are equal to the ones at
+// the same path in prev are not validated, so existing data which doesn't
+// match the schema can be kept.
+func (s *Schema) ValidateChanges(v, prev interface{}, field string) error {
+	return s.validate(v, prev, true, field)
+}
+
+func (s *Schema) validate(v, prev interface{}, hasPrev bool, field string) error {
+	if s == nil {
+		return nil
+	}
+
+	if hasPrev && reflect.DeepEqual(v, prev) {
+		return nil
+	}
+
+	if err := s.checkType(v, field); err != nil {
+		return err
+	}
+
+	if err := s.checkEnum(v, field); err != nil {
+		return err
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for _, key := range s.Required {
+			if _, ok := val[key]; !ok {
+				return schemaError(RequiredError, field+"."+key, field+"."+key+" is required.")
+			}
+		}
+
+		prevObj, _ := prev.(map[string]interface{})
+
+		for _, key := range sortedKeys(val) {
+			item := val[key]
+			prevItem, hasPrevItem := prevObj[key]
+			prop, ok := s.Properties[key]
+
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties && !(hasPrevItem && reflect.DeepEqual(item, prevItem)) {
+					return schemaError(ValueError, field+"."+key, field+"."+key+" is not allowed.")
+				}
+
+				continue
+			}
+
+			if err := prop.validate(item, prevItem, hasPrevItem, field+"."+key); err != nil {
+				return err
+			}
+		}
+
+	case []interface{}:
+		prevArr, _ := prev.([]interface{})
+
+		for i, item := range val {
+			var prevItem interface{}
+			hasPrevItem := i < len(prevArr)
+
+			if hasPrevItem {
+				prevItem = prevArr[i]
+			}
+
+			if err := s.Items.validate(item, prevItem, hasPrevItem, field+"."+strconv.Itoa(i)); err != nil {
+				return err
+			}
+		}
+
+	case string:
+		if s.MaxLength != nil && len([]rune(val)) > *s.MaxLength {
+			return schemaError(LengthError, field, "Maximum length of "+field+" is "+strconv.Itoa(*s.MaxLength)+".")
+		}
+
+		if err := s.checkFormat(val, field); err != nil {
+			return err
+		}
+	}
+
+	if n, ok := toNumber(v); ok {
+		if s.Minimum != nil && n < *s.Minimum {
+			return schemaError(ValueError, field, field+" must be greater than or equal to "+formatNumber(*s.Minimum)+".")
+		}
+
+		if s.Maximum != nil && n > *s.Maximum {
+			return schemaError(ValueError, field, field+" must be less than or equal to "+formatNumber(*s.Maximum)+".")
+		}
+	}
+
+	return nil
+}
+
+// FindFormat calls fn with each string in the value whose schema has the
+// format. The field passed to fn is the path of the string joined with dots.
+func (s *Schema) FindFormat(v interface{}, field, format string, fn func(value, field string)) {
+	if s == nil {
+		return
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for key, item := range val {
+			s.Properties[key].FindFormat(item, field+"."+key, format, fn)
+		}
+
+	case []interface{}:
+		for i, item := range val {
+			s.Items.FindFormat(item, field+"."+strconv.Itoa(i), format, fn)
+		}
+
+	case string:
+		if s.Format == format {
+			fn(val, field)
+		}
+	}
+}